@@ -0,0 +1,86 @@
+// Container ACL manipulation
+
+package swift
+
+import (
+	"context"
+	"strings"
+)
+
+// Common elements of container ACLs
+const (
+	ACLPublicRead = ".r:*"       // Anyone may read objects
+	ACLListings   = ".rlistings" // Anyone allowed to read may also list the container
+)
+
+// ACLReferrer returns the ACL element allowing reads from requests
+// with a Referer header matching host, eg ".example.com".
+func ACLReferrer(host string) string {
+	return ".r:" + host
+}
+
+// ACLUser returns the ACL element granting access to user in account.
+//
+// Pass "*" as either to mean any account or any user.
+func ACLUser(account string, user string) string {
+	return account + ":" + user
+}
+
+// JoinACL joins ACL elements into an ACL suitable for
+// ContainerSetReadACL or ContainerSetWriteACL.
+func JoinACL(elements ...string) string {
+	return strings.Join(elements, ",")
+}
+
+// parseACL splits an ACL into its elements
+func parseACL(acl string) (elements []string) {
+	for _, element := range strings.Split(acl, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// ContainerReadACL returns the elements of the X-Container-Read ACL
+// from the Headers returned by Container().
+func (h Headers) ContainerReadACL() []string {
+	return parseACL(h["X-Container-Read"])
+}
+
+// ContainerWriteACL returns the elements of the X-Container-Write ACL
+// from the Headers returned by Container().
+func (h Headers) ContainerWriteACL() []string {
+	return parseACL(h["X-Container-Write"])
+}
+
+// ContainerSetReadACL sets who may read the container.
+//
+// Build acl with the ACL helpers, eg
+//
+//	c.ContainerSetReadACL(ctx, container, JoinACL(ACLPublicRead, ACLListings))
+//
+// Pass an empty acl to remove it.
+func (c *Connection) ContainerSetReadACL(ctx context.Context, container string, acl string) error {
+	return c.ContainerUpdate(ctx, container, Headers{"X-Container-Read": acl})
+}
+
+// ContainerSetWriteACL sets who may write to the container.
+//
+// Pass an empty acl to remove it.
+func (c *Connection) ContainerSetWriteACL(ctx context.Context, container string, acl string) error {
+	return c.ContainerUpdate(ctx, container, Headers{"X-Container-Write": acl})
+}
+
+// ContainerACLs returns the elements of the read and write ACLs of
+// the container.
+//
+// Swift only returns these to users who are allowed to change them.
+func (c *Connection) ContainerACLs(ctx context.Context, container string) (read []string, write []string, err error) {
+	_, headers, err := c.Container(ctx, container)
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers.ContainerReadACL(), headers.ContainerWriteACL(), nil
+}
@@ -36,6 +36,12 @@ type CustomEndpointAuthenticator interface {
 	StorageUrlForEndpoint(endpointType EndpointType) string
 }
 
+// EndpointUrler is an optional interface to read the URL of any
+// service in the catalog returned by the auth server
+type EndpointUrler interface {
+	EndpointUrl(serviceType string, endpointType EndpointType) string
+}
+
 type EndpointType string
 
 const (
@@ -256,6 +262,11 @@ func (auth *v2Auth) Expires() time.Time {
 	return t
 }
 
+// v2 Authentication - read the url of serviceType from the catalog
+func (auth *v2Auth) EndpointUrl(serviceType string, endpointType EndpointType) string {
+	return auth.endpointUrl(serviceType, endpointType)
+}
+
 // v2 Authentication - read cdn url
 func (auth *v2Auth) CdnUrl() string {
 	return auth.endpointUrl("rax:object-cdn", EndpointTypePublic)
@@ -283,6 +283,10 @@ func (auth *v3Auth) StorageUrlForEndpoint(endpointType EndpointType) string {
 	return auth.endpointUrl("object-store", endpointType)
 }
 
+func (auth *v3Auth) EndpointUrl(serviceType string, endpointType EndpointType) string {
+	return auth.endpointUrl(serviceType, endpointType)
+}
+
 func (auth *v3Auth) Token() string {
 	return auth.Headers.Get("X-Subject-Token")
 }
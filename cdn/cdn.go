@@ -0,0 +1,91 @@
+// Package cdn manages the CDN service which some OpenStack clouds
+// list in their service catalog alongside the object store.
+//
+// It is the generic counterpart of the rs package, finding the CDN
+// management URL by service type in the v2 or v3 auth catalog rather
+// than relying on Rackspace specific headers.
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ncw/swift/v2"
+)
+
+// DefaultServiceType is the catalog type of the CDN service used if
+// CdnConnection.ServiceType isn't set.
+const DefaultServiceType = "object-cdn"
+
+// CdnConnection is a wrapper to the core swift library which exposes
+// the CDN commands via the CDN service from the auth catalog.
+type CdnConnection struct {
+	swift.Connection
+	ServiceType string // Catalog type of the CDN service (default "object-cdn")
+}
+
+// manage is similar to the swift storage method, but uses the CDN service URL for CDN specific calls.
+func (c *CdnConnection) manage(ctx context.Context, p swift.RequestOpts) (resp *http.Response, headers swift.Headers, err error) {
+	serviceType := c.ServiceType
+	if serviceType == "" {
+		serviceType = DefaultServiceType
+	}
+	cdnUrl, err := c.EndpointUrl(ctx, serviceType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cdnUrl == "" {
+		return nil, nil, fmt.Errorf("no %q service in the catalog of the authenticated platform", serviceType)
+	}
+	return c.Connection.Call(ctx, cdnUrl, p)
+}
+
+// ContainerCDNEnable enables a container for public CDN usage.
+//
+// Pass a ttl in seconds greater than 0 to change how long objects
+// are cached for from the service's default.
+//
+// This method can be called again to change the TTL.
+func (c *CdnConnection) ContainerCDNEnable(ctx context.Context, container string, ttl int) (swift.Headers, error) {
+	h := swift.Headers{"X-CDN-Enabled": "true"}
+	if ttl > 0 {
+		h["X-TTL"] = strconv.Itoa(ttl)
+	}
+
+	_, headers, err := c.manage(ctx, swift.RequestOpts{
+		Container:  container,
+		Operation:  "PUT",
+		ErrorMap:   swift.ContainerErrorMap,
+		NoResponse: true,
+		Headers:    h,
+	})
+	return headers, err
+}
+
+// ContainerCDNDisable disables CDN access to a container.
+func (c *CdnConnection) ContainerCDNDisable(ctx context.Context, container string) error {
+	h := swift.Headers{"X-CDN-Enabled": "false"}
+
+	_, _, err := c.manage(ctx, swift.RequestOpts{
+		Container:  container,
+		Operation:  "PUT",
+		ErrorMap:   swift.ContainerErrorMap,
+		NoResponse: true,
+		Headers:    h,
+	})
+	return err
+}
+
+// ContainerCDNMeta returns the CDN metadata for a container.
+func (c *CdnConnection) ContainerCDNMeta(ctx context.Context, container string) (swift.Headers, error) {
+	_, headers, err := c.manage(ctx, swift.RequestOpts{
+		Container:  container,
+		Operation:  "HEAD",
+		ErrorMap:   swift.ContainerErrorMap,
+		NoResponse: true,
+		Headers:    swift.Headers{},
+	})
+	return headers, err
+}
@@ -0,0 +1,135 @@
+// This tests the cdn package against a fake auth and CDN server
+//
+// It does not require access to a swift server
+package cdn_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ncw/swift/v2"
+	"github.com/ncw/swift/v2/cdn"
+)
+
+// cdnServer is a fake v3 auth server whose catalog lists a CDN
+// service which records the requests made to it.
+type cdnServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newCdnServer(t *testing.T, serviceType string) *cdnServer {
+	s := &cdnServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/auth/tokens" {
+			w.Header().Set("X-Subject-Token", "token")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"token":{"catalog":[
+				{"type":"object-store","endpoints":[
+					{"interface":"internal","url":"%[1]s/v1/AUTH_test"},
+					{"interface":"public","url":"%[1]s/v1/AUTH_test"}
+				]},
+				{"type":%[2]q,"endpoints":[
+					{"interface":"internal","url":"%[1]s/internal"},
+					{"interface":"public","url":"%[1]s/cdn"}
+				]}
+			]}}`, s.URL, serviceType)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Cdn-Enabled", "True")
+		w.Header().Set("X-Cdn-Uri", "http://cdn.example.com/container")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newConnection(s *cdnServer) *cdn.CdnConnection {
+	c := &cdn.CdnConnection{}
+	c.UserName = "user"
+	c.ApiKey = "key"
+	c.AuthUrl = s.URL + "/v3"
+	return c
+}
+
+func TestCDN(t *testing.T) {
+	ctx := context.Background()
+	s := newCdnServer(t, cdn.DefaultServiceType)
+	c := newConnection(s)
+
+	headers, err := c.ContainerCDNEnable(ctx, "container", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Cdn-Uri"] != "http://cdn.example.com/container" {
+		t.Errorf("Bad headers %v", headers)
+	}
+	headers, err = c.ContainerCDNMeta(ctx, "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Cdn-Enabled"] != "True" {
+		t.Errorf("Bad headers %v", headers)
+	}
+	err = c.ContainerCDNDisable(ctx, "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []struct {
+		method, enabled, ttl string
+	}{
+		{"PUT", "true", "3600"},
+		{"HEAD", "", ""},
+		{"PUT", "false", ""},
+	} {
+		r := s.requests[i]
+		if r.Method != want.method || r.URL.Path != "/cdn/container" {
+			t.Errorf("%d: want %s /cdn/container got %s %s", i, want.method, r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("X-Cdn-Enabled"); got != want.enabled {
+			t.Errorf("%d: want X-CDN-Enabled %q got %q", i, want.enabled, got)
+		}
+		if got := r.Header.Get("X-Ttl"); got != want.ttl {
+			t.Errorf("%d: want X-TTL %q got %q", i, want.ttl, got)
+		}
+	}
+}
+
+func TestCDNServiceType(t *testing.T) {
+	s := newCdnServer(t, "cdn")
+	c := newConnection(s)
+	c.ServiceType = "cdn"
+	c.Internal = true
+	_, err := c.ContainerCDNMeta(context.Background(), "container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.requests[0].URL.Path; got != "/internal/container" {
+		t.Errorf("Expecting request to /internal/container got %s", got)
+	}
+}
+
+func TestCDNNotInCatalog(t *testing.T) {
+	s := newCdnServer(t, "something-else")
+	c := newConnection(s)
+	_, err := c.ContainerCDNMeta(context.Background(), "container")
+	if err == nil {
+		t.Fatal("Expecting error")
+	}
+	if _, ok := err.(*swift.Error); ok {
+		t.Errorf("Expecting catalog error got %v", err)
+	}
+}
@@ -102,7 +102,7 @@ func (file *DynamicLargeObjectCreateFile) CloseWithContext(ctx context.Context)
 func (file *DynamicLargeObjectCreateFile) Flush(ctx context.Context) error {
 	err := file.conn.createDLOManifest(ctx, file.container, file.objectName, file.segmentContainer+"/"+file.prefix, file.contentType, file.headers)
 	if err != nil {
-		return err
+		return file.cleanupSegments(ctx, err)
 	}
 	return file.conn.waitForSegmentsToShowUp(ctx, file.container, file.objectName, file.Size())
 }
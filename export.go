@@ -0,0 +1,94 @@
+// Export and import of container and object metadata
+
+package swift
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// metadataExport is the JSON document written by ExportMetadata and
+// read by ImportMetadata
+type metadataExport struct {
+	Container Metadata            `json:"container"`
+	Objects   map[string]Metadata `json:"objects"`
+}
+
+// ExportMetadata writes the metadata of container and of every object
+// in it to w as JSON so it can be reapplied with ImportMetadata, for
+// instance on another cluster.
+//
+// The document looks like this, with the keys in lower case as
+// returned by Headers.Metadata
+//
+//	{
+//		"container": {"colour": "blue"},
+//		"objects": {
+//			"object1": {"author": "nick"},
+//			"object2": {}
+//		}
+//	}
+//
+// Only user metadata (X-Container-Meta-* and X-Object-Meta-*) is
+// exported. This does a HEAD for every object in the container.
+func (c *Connection) ExportMetadata(ctx context.Context, container string, w io.Writer) error {
+	_, headers, err := c.Container(ctx, container)
+	if err != nil {
+		return err
+	}
+	export := metadataExport{
+		Container: headers.ContainerMetadata(),
+		Objects:   map[string]Metadata{},
+	}
+	err = c.ObjectsWalk(ctx, container, nil, func(ctx context.Context, opts *ObjectsOpts) (interface{}, error) {
+		names, err := c.ObjectNames(ctx, container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			_, headers, err := c.Object(ctx, container, name)
+			if err != nil {
+				return nil, err
+			}
+			export.Objects[name] = headers.ObjectMetadata()
+		}
+		return names, nil
+	})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(&export)
+}
+
+// ImportMetadata reads metadata written by ExportMetadata from r and
+// applies it to container and the objects in it.
+//
+// Container metadata is added to any already on the container. Each
+// object's metadata is replaced with that imported as ObjectUpdate
+// does.
+//
+// The objects must already exist otherwise ObjectNotFound is
+// returned.
+func (c *Connection) ImportMetadata(ctx context.Context, container string, r io.Reader) error {
+	var export metadataExport
+	err := json.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return err
+	}
+	if len(export.Container) > 0 {
+		err = c.ContainerUpdate(ctx, container, export.Container.ContainerHeaders())
+		if err != nil {
+			return err
+		}
+	}
+	for name, metadata := range export.Objects {
+		err = c.ObjectUpdate(ctx, container, name, metadata.ObjectHeaders())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
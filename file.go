@@ -0,0 +1,94 @@
+// Uploading and downloading local files
+
+package swift
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ObjectGetFile downloads the object to the file localPath, creating
+// or replacing it, and returns the headers of the response.
+//
+// The MD5 of the object is checked as it is downloaded and if it is
+// wrong ObjectCorrupted is returned. The modification time of the file
+// is set from the Last-Modified of the object.
+//
+// The object is downloaded to a temporary file in the same directory
+// which is renamed to localPath once the download has succeeded, so
+// if it fails any existing file at localPath is left alone.  A
+// replaced file keeps its permissions, otherwise the file is created
+// with 0644.
+func (c *Connection) ObjectGetFile(ctx context.Context, container string, objectName string, localPath string) (headers Headers, err error) {
+	perm := os.FileMode(0644)
+	if fi, statErr := os.Stat(localPath); statErr == nil {
+		perm = fi.Mode().Perm()
+	}
+	out, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.partial")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := out.Name()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	headers, err = c.ObjectGet(ctx, container, objectName, out, true, nil)
+	if err == nil {
+		err = out.Chmod(perm)
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastModified := headers["Last-Modified"]; lastModified != "" {
+		modTime, parseErr := time.Parse(http.TimeFormat, lastModified)
+		if parseErr == nil {
+			err = os.Chtimes(tmpPath, modTime, modTime)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = os.Rename(tmpPath, localPath)
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// ObjectPutFile uploads the file localPath to the object and returns
+// the headers of the response.
+//
+// If contentType is empty it is guessed from the extension of
+// localPath using mime.TypeByExtension. The Content-Length is sent and
+// the MD5 is calculated as the file is uploaded and checked against
+// that returned by the server, returning ObjectCorrupted if it is
+// wrong.
+//
+// The file is streamed so it isn't read into memory.
+func (c *Connection) ObjectPutFile(ctx context.Context, container string, objectName string, localPath string, contentType string) (headers Headers, err error) {
+	in, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer checkClose(in, &err)
+	fi, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(localPath))
+	}
+	h := Headers{"Content-Length": strconv.FormatInt(fi.Size(), 10)}
+	return c.ObjectPut(ctx, container, objectName, in, true, "", contentType, h)
+}
@@ -0,0 +1,93 @@
+// Go 1.23 and later iterator functions
+
+//go:build go1.23
+// +build go1.23
+
+package swift
+
+import (
+	"context"
+	"iter"
+)
+
+// stopIteration is returned from the walk functions to stop paging
+// when the consumer of an iterator breaks out of the loop
+var stopIteration = newError(0, "iteration stopped")
+
+// ObjectsIter returns an iterator over all the objects in container,
+// fetching them a page at a time using the Marker parameter exactly
+// like ObjectsWalk.
+//
+// Unlike ObjectsAll the objects are yielded as each page is decoded
+// so the listing is never held in memory, and breaking out of the
+// loop stops the listing early.
+//
+// This means the loop body runs while the listing is still being
+// read from the server.  If it is slow, for instance if it downloads
+// each object, the server, a proxy or Connection.Timeout may close
+// the idle listing, which then yields an error after some of the
+// objects.  Use ObjectsWalk to fetch a whole page at a time instead.
+//
+//	for object, err := range c.ObjectsIter(ctx, container, nil) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// If an error occurs it is yielded once and the iteration stops.
+func (c *Connection) ObjectsIter(ctx context.Context, container string, opts *ObjectsOpts) iter.Seq2[Object, error] {
+	return func(yield func(Object, error) bool) {
+		opts := objectsAllOpts(opts, allObjectsChanLimit)
+		for {
+			// Objects are yielded as they are decoded so not even a
+			// whole page is held in memory
+			n, last, err := c.objectsStream(ctx, container, opts, func(object Object) error {
+				if !yield(object, nil) {
+					return stopIteration
+				}
+				return nil
+			})
+			if err == stopIteration {
+				return
+			}
+			if err != nil {
+				yield(Object{}, err)
+				return
+			}
+			if c.isLastPage(n, opts.Limit) || opts.pastEndMarker(last) {
+				return
+			}
+			opts.Marker = last
+		}
+	}
+}
+
+// ContainersIter returns an iterator over all the containers, fetching
+// them a page at a time using the Marker parameter like ContainersAll.
+//
+// Unlike ContainersAll only one page of containers is held in memory
+// at once and breaking out of the loop stops the listing early.
+//
+// If an error occurs it is yielded once and the iteration stops.
+func (c *Connection) ContainersIter(ctx context.Context, opts *ContainersOpts) iter.Seq2[Container, error] {
+	return func(yield func(Container, error) bool) {
+		opts := containersAllOpts(opts)
+		for {
+			containers, err := c.Containers(ctx, opts)
+			if err != nil {
+				yield(Container{}, err)
+				return
+			}
+			for _, container := range containers {
+				if !yield(container, nil) {
+					return
+				}
+			}
+			if c.isLastPage(len(containers), opts.Limit) {
+				return
+			}
+			opts.Marker = containers[len(containers)-1].Name
+		}
+	}
+}
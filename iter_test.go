@@ -0,0 +1,94 @@
+// Tests for the Go 1.23 iterator functions
+
+//go:build go1.23
+// +build go1.23
+
+package swift_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ncw/swift/v2"
+)
+
+func TestObjectsIter(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{OBJECT, OBJECT2, OBJECT + "3"}
+	for _, name := range names {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			err := c.ObjectDelete(ctx, CONTAINER, name)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	// Use a small limit so the iterator has to page
+	var got []string
+	for object, err := range c.ObjectsIter(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, object.Name)
+	}
+	want, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect listing want %v got %v", want, got)
+	}
+
+	// Check breaking out of the loop stops the iteration
+	got = nil
+	for object, err := range c.ObjectsIter(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, object.Name)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("Incorrect listing after break want %v got %v", want[:2], got)
+	}
+
+	var errs []error
+	for _, err := range c.ObjectsIter(ctx, "notfound", nil) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0] != swift.ContainerNotFound {
+		t.Errorf("Expecting one ContainerNotFound got %v", errs)
+	}
+}
+
+func TestContainersIter(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	var got []string
+	for container, err := range c.ContainersIter(ctx, &swift.ContainersOpts{Limit: 1}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, container.Name)
+	}
+	want, err := c.ContainerNamesAll(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect listing want %v got %v", want, got)
+	}
+}
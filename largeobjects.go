@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
@@ -15,6 +16,7 @@ import (
 	gopath "path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,6 +25,48 @@ import (
 //nolint:stylecheck
 var NotLargeObject = errors.New("not a large object")
 
+// SegmentContainerForbidden is returned if Connection.ProtectSegmentContainers
+// is set and an object operation targets a segments container directly.
+//
+//nolint:stylecheck
+var SegmentContainerForbidden = errors.New("object operation on segments container")
+
+// LargeObjectNoSegmentPrefix is returned by LargeObjectResume if
+// LargeObjectOpts.SegmentPrefix isn't set.
+//
+//nolint:stylecheck
+var LargeObjectNoSegmentPrefix = errors.New("segment prefix needed to resume large object")
+
+// segmentContainerSuffix is the suffix of the default segments container
+const segmentContainerSuffix = "_segments"
+
+// defaultChunkSize is the size of large object segments if
+// LargeObjectOpts.ChunkSize isn't set
+const defaultChunkSize = 10 * 1024 * 1024
+
+// segmentAccessKey marks a context as belonging to a large object
+// operation which is allowed to touch the segments container
+type segmentAccessKey struct{}
+
+// withSegmentAccess returns a context which allows object operations
+// on segments containers
+func withSegmentAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, segmentAccessKey{}, true)
+}
+
+// checkSegmentContainer returns SegmentContainerForbidden if
+// ProtectSegmentContainers is set and objectName is in what looks
+// like a segments container, unless ctx came from withSegmentAccess.
+func (c *Connection) checkSegmentContainer(ctx context.Context, container string, objectName string) error {
+	if !c.ProtectSegmentContainers || objectName == "" || !strings.HasSuffix(container, segmentContainerSuffix) {
+		return nil
+	}
+	if allowed, _ := ctx.Value(segmentAccessKey{}).(bool); allowed {
+		return nil
+	}
+	return SegmentContainerForbidden
+}
+
 // readAfterWriteTimeout defines the time we wait before an object appears after having been uploaded
 var readAfterWriteTimeout = 15 * time.Second
 
@@ -44,6 +88,35 @@ type largeObjectCreateFile struct {
 	segments         []Object
 	headers          Headers
 	minChunkSize     int64
+	chunkSizeFn      func(segment int) int64 // if set, size of each segment
+	deleteAt         time.Time               // if set, expiry time for the segments and manifest
+	cleanupOnFailure bool                    // if set, delete new segments if the manifest can't be written
+	existingSegments int                     // number of segments which existed before this writer
+	progress         ProgressFunc            // if set, called as segments are uploaded
+	cut              bool                    // if set, the next write starts a new segment
+}
+
+// LargeObjectCleanupError is returned when closing a large object
+// created with LargeObjectOpts.CleanupOnFailure if the manifest
+// couldn't be written.  It reports which segments were deleted.
+type LargeObjectCleanupError struct {
+	Err        error    // Error writing the manifest
+	Container  string   // Container the segments were deleted from
+	Deleted    []string // Names of the segments which were deleted
+	CleanupErr error    // First error deleting the segments if any
+}
+
+func (e *LargeObjectCleanupError) Error() string {
+	msg := fmt.Sprintf("%v: deleted %d segments from %q", e.Err, len(e.Deleted), e.Container)
+	if e.CleanupErr != nil {
+		msg += fmt.Sprintf(": cleanup failed: %v", e.CleanupErr)
+	}
+	return msg
+}
+
+// Unwrap returns the error which caused the cleanup
+func (e *LargeObjectCleanupError) Unwrap() error {
+	return e.Err
 }
 
 func swiftSegmentPath(path string) (string, error) {
@@ -56,6 +129,22 @@ func swiftSegmentPath(path string) (string, error) {
 	return strings.TrimLeft(strings.TrimRight("segments/"+path[0:3]+"/"+path[3:], "/"), "/"), nil
 }
 
+// objectSegmentDir returns the pseudo-directory used for the segments
+// of container, objectName if LargeObjectOpts.ObjectSegmentDir is set
+func objectSegmentDir(container string, objectName string) string {
+	return "segments/" + container + "/" + objectName
+}
+
+// objectSegmentPath returns a new segment path in the pseudo-directory
+// for container, objectName
+func objectSegmentPath(container string, objectName string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return objectSegmentDir(container, objectName) + "/" + hex.EncodeToString(random), nil
+}
+
 func getSegment(segmentPath string, partNumber int) string {
 	return fmt.Sprintf("%s/%016d", segmentPath, partNumber)
 }
@@ -87,7 +176,25 @@ func (headers Headers) IsLargeObject() bool {
 	return headers.IsLargeObjectSLO() || headers.IsLargeObjectDLO()
 }
 
+// ObjectType returns the type of the object the headers describe.
+//
+// Swift follows symlinks unless asked not to so SymlinkObjectType is
+// only returned for the headers from ObjectNoFollow or a request with
+// "?symlink=get"; otherwise the type of the target is returned.
+func (headers Headers) ObjectType() ObjectType {
+	switch {
+	case headers["X-Symlink-Target"] != "":
+		return SymlinkObjectType
+	case headers.IsLargeObjectDLO():
+		return DynamicLargeObjectType
+	case headers.IsLargeObjectSLO():
+		return StaticLargeObjectType
+	}
+	return RegularObjectType
+}
+
 func (c *Connection) getAllSegments(ctx context.Context, container string, path string, headers Headers) (string, []Object, error) {
+	ctx = withSegmentAccess(ctx)
 	if manifest, isDLO := headers["X-Object-Manifest"]; isDLO {
 		segmentContainer, segmentPath, err := parseFullPath(manifest)
 		if err != nil {
@@ -104,18 +211,26 @@ func (c *Connection) getAllSegments(ctx context.Context, container string, path
 
 // LargeObjectOpts describes how a large object should be created
 type LargeObjectOpts struct {
-	Container        string  // Name of container to place object
-	ObjectName       string  // Name of object
-	Flags            int     // Creation flags
-	CheckHash        bool    // If set Check the hash
-	Hash             string  // If set use this hash to check
-	ContentType      string  // Content-Type of the object
-	Headers          Headers // Additional headers to upload the object with
-	ChunkSize        int64   // Size of chunks of the object, defaults to 10MB if not set
-	MinChunkSize     int64   // Minimum chunk size, automatically set for SLO's based on info
-	SegmentContainer string  // Name of the container to place segments
-	SegmentPrefix    string  // Prefix to use for the segments
-	NoBuffer         bool    // Prevents using a bufio.Writer to write segments
+	Container        string                  // Name of container to place object
+	ObjectName       string                  // Name of object
+	Flags            int                     // Creation flags
+	CheckHash        bool                    // If set Check the hash
+	Hash             string                  // If set use this hash to check
+	ContentType      string                  // Content-Type of the object
+	Headers          Headers                 // Additional headers to upload the object with
+	ChunkSize        int64                   // Size of chunks of the object, defaults to 10MB if not set
+	ChunkSizeFn      func(segment int) int64 // If set returns the size of each segment (numbered from 0), ChunkSize is used if it returns 0
+	MinChunkSize     int64                   // Minimum chunk size, automatically set for SLO's based on info
+	SegmentContainer string                  // Name of the container to place segments
+	SegmentPrefix    string                  // Prefix to use for the segments
+	NoBuffer         bool                    // Prevents using a bufio.Writer to write segments
+	DeleteAt         time.Time               // If set the object and its segments are deleted by the server at this time
+	DeleteAfter      time.Duration           // If set the object and its segments are deleted by the server after this long
+	CleanupOnFailure bool                    // If set delete the segments uploaded by this writer if the manifest can't be written
+	ResumeSource     io.ReaderAt             // If set LargeObjectResume checks the existing segments against this data
+	ObjectSegmentDir bool                    // If set put the segments in a pseudo-directory named after the object so LargeObjectCleanupSegments can find old ones
+	Threshold        int64                   // ObjectPutAuto makes a large object if the upload is bigger than this, defaults to ChunkSize
+	Progress         ProgressFunc            // If set called with the number of bytes written as each segment is uploaded
 }
 
 type LargeObjectFile interface {
@@ -129,6 +244,16 @@ type LargeObjectFile interface {
 	Flush(ctx context.Context) error
 }
 
+// SegmentCutter is implemented by the LargeObjectFile returned by the
+// large object create functions so the caller can choose where the
+// segments end.
+type SegmentCutter interface {
+	// CutSegment uploads anything buffered as the end of the
+	// current segment so the next write starts a new one whatever
+	// the ChunkSize.  Segments after the cut are ChunkSize again.
+	CutSegment(ctx context.Context) error
+}
+
 // largeObjectCreate creates a large object at opts.Container, opts.ObjectName.
 //
 // opts.Flags can have the following bits set
@@ -146,6 +271,10 @@ func (c *Connection) largeObjectCreate(ctx context.Context, opts *LargeObjectOpt
 
 	if opts.SegmentPrefix != "" {
 		segmentPath = opts.SegmentPrefix
+	} else if opts.ObjectSegmentDir {
+		if segmentPath, err = objectSegmentPath(opts.Container, opts.ObjectName); err != nil {
+			return nil, err
+		}
 	} else if segmentPath, err = swiftSegmentPath(opts.ObjectName); err != nil {
 		return nil, err
 	}
@@ -179,13 +308,31 @@ func (c *Connection) largeObjectCreate(ctx context.Context, opts *LargeObjectOpt
 
 	// segmentContainer is not empty when the manifest already existed
 	if segmentContainer == "" {
-		if opts.SegmentContainer != "" {
-			segmentContainer = opts.SegmentContainer
-		} else {
-			segmentContainer = opts.Container + "_segments"
-		}
+		segmentContainer = opts.segmentContainer()
 	}
 
+	file := c.newLargeObjectCreateFile(opts, segmentContainer, segmentPath, segments, currentLength)
+
+	if opts.Flags&os.O_APPEND != 0 {
+		file.filePos = currentLength
+	}
+
+	return file, nil
+}
+
+// segmentContainer returns the container the segments should go in
+// if it isn't set by an existing manifest
+func (opts *LargeObjectOpts) segmentContainer() string {
+	if opts.SegmentContainer != "" {
+		return opts.SegmentContainer
+	}
+	return opts.Container + segmentContainerSuffix
+}
+
+// newLargeObjectCreateFile makes a largeObjectCreateFile from opts
+// which writes segments to segmentContainer, segmentPath and which
+// already has the segments passed in.
+func (c *Connection) newLargeObjectCreateFile(opts *LargeObjectOpts, segmentContainer string, segmentPath string, segments []Object, currentLength int64) *largeObjectCreateFile {
 	file := &largeObjectCreateFile{
 		conn:             c,
 		checkHash:        opts.CheckHash,
@@ -193,30 +340,138 @@ func (c *Connection) largeObjectCreate(ctx context.Context, opts *LargeObjectOpt
 		objectName:       opts.ObjectName,
 		chunkSize:        opts.ChunkSize,
 		minChunkSize:     opts.MinChunkSize,
+		chunkSizeFn:      opts.ChunkSizeFn,
 		headers:          opts.Headers,
 		segmentContainer: segmentContainer,
 		prefix:           segmentPath,
 		segments:         segments,
 		currentLength:    currentLength,
+		deleteAt:         opts.DeleteAt,
+		cleanupOnFailure: opts.CleanupOnFailure,
+		existingSegments: len(segments),
+		progress:         opts.Progress,
+	}
+
+	// Use an absolute time so the segments expire with the manifest
+	if opts.DeleteAfter > 0 {
+		file.deleteAt = time.Now().Add(opts.DeleteAfter)
+	}
+	if !file.deleteAt.IsZero() {
+		file.headers = Headers{}
+		for key, value := range opts.Headers {
+			file.headers[key] = value
+		}
+		file.headers.SetDeleteAt(file.deleteAt)
 	}
 
 	if file.chunkSize == 0 {
-		file.chunkSize = 10 * 1024 * 1024
+		file.chunkSize = defaultChunkSize
 	}
 
 	if file.minChunkSize > file.chunkSize {
 		file.chunkSize = file.minChunkSize
 	}
 
-	if opts.Flags&os.O_APPEND != 0 {
-		file.filePos = currentLength
+	return file
+}
+
+// LargeObjectResume carries on an upload of a static large object
+// which was interrupted before its manifest was written, for instance
+// because the process died.
+//
+// opts.SegmentPrefix must be set to the prefix used by the interrupted
+// upload, since without it the segments get a random prefix which
+// can't be found again, and opts.ChunkSize (or opts.ChunkSizeFn) must
+// be the same as it was.
+//
+// The segments already uploaded under the prefix are kept up to the
+// first one which is missing, isn't a full segment or, if
+// opts.ResumeSource is set, doesn't have the MD5 hash of the
+// corresponding part of the source.  The segments from that one on
+// are deleted.
+//
+// The returned LargeObjectFile is positioned at the end of the kept
+// segments, so the rest of the data should be written starting from
+// Size(), then it should be closed to write the manifest.
+func (c *Connection) LargeObjectResume(ctx context.Context, opts *LargeObjectOpts) (LargeObjectFile, error) {
+	if opts.SegmentPrefix == "" {
+		return nil, LargeObjectNoSegmentPrefix
 	}
+	info, err := c.cachedQueryInfo(ctx)
+	if err != nil || !info.SupportsSLO() {
+		return nil, SLONotSupported
+	}
+	realMinChunkSize := info.SLOMinSegmentSize()
+	if realMinChunkSize > opts.MinChunkSize {
+		opts.MinChunkSize = realMinChunkSize
+	}
+	segmentContainer := opts.segmentContainer()
+	// Read the segments as if they belonged to a DLO, which lists
+	// them by prefix and checks the listing is complete
+	_, segments, err := c.getAllSegments(ctx, opts.Container, opts.ObjectName, Headers{
+		"X-Object-Manifest": segmentContainer + "/" + opts.SegmentPrefix,
+	})
+	if err != nil && err != ContainerNotFound {
+		return nil, err
+	}
+	// Ignore objects from other uploads with a longer prefix
+	ours := segments[:0]
+	for _, segment := range segments {
+		if strings.HasPrefix(segment.Name, opts.SegmentPrefix+"/") {
+			ours = append(ours, segment)
+		}
+	}
+	segments = ours
+	file := c.newLargeObjectCreateFile(opts, segmentContainer, opts.SegmentPrefix, nil, 0)
+	good := 0
+	for i, segment := range segments {
+		if segment.Name != getSegment(opts.SegmentPrefix, i+1) || segment.Bytes != file.segmentChunkSize(i) {
+			break
+		}
+		if opts.ResumeSource != nil {
+			ok, err := segmentMatches(opts.ResumeSource, file.currentLength, segment)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+		}
+		file.currentLength += segment.Bytes
+		good++
+	}
+	segmentCtx := withSegmentAccess(ctx)
+	for _, segment := range segments[good:] {
+		err = c.ObjectDelete(segmentCtx, segmentContainer, segment.Name)
+		if err != nil && err != ObjectNotFound {
+			return nil, err
+		}
+	}
+	file.segments = segments[:good]
+	file.existingSegments = good
+	file.filePos = file.currentLength
+	return withBuffer(opts, &StaticLargeObjectCreateFile{
+		largeObjectCreateFile: *file,
+	}), nil
+}
 
-	return file, nil
+// segmentMatches returns whether segment has the MD5 hash of the data
+// in source starting at offset
+func segmentMatches(source io.ReaderAt, offset int64, segment Object) (bool, error) {
+	hash := md5.New()
+	n, err := io.Copy(hash, io.NewSectionReader(source, offset, segment.Bytes))
+	if err != nil {
+		return false, err
+	}
+	if n != segment.Bytes {
+		return false, nil
+	}
+	return etagMatches(segment.Hash, hex.EncodeToString(hash.Sum(nil))), nil
 }
 
 // LargeObjectDelete deletes the large object named by container, path
 func (c *Connection) LargeObjectDelete(ctx context.Context, container string, objectName string) error {
+	ctx = withSegmentAccess(ctx)
 	_, headers, err := c.Object(ctx, container, objectName)
 	if err != nil {
 		return err
@@ -257,11 +512,159 @@ func (c *Connection) LargeObjectDelete(ctx context.Context, container string, ob
 	return nil
 }
 
+// LargeObjectCopy copies the large object srcContainer, srcObjectName
+// to dstContainer, dstObjectName leaving the source in place.
+//
+// If deep is false the new manifest refers to the same segments as the
+// source, so deleting either object with LargeObjectDelete will break
+// the other - delete the copy's manifest with ObjectDelete instead.
+// LargeObjectCleanupSegments on the source will also break the copy
+// once the source has been uploaded again.
+// If deep is true the segments are copied server side to a new prefix
+// in the same segment container first so the two objects are
+// independent.  The segments of a static large object may be in
+// several containers, in which case each is copied within its own
+// container.  A static large object containing other static large
+// objects can't be deep copied.
+//
+// Only the user metadata of the source is copied.  It returns
+// NotLargeObject if the source isn't a large object.
+func (c *Connection) LargeObjectCopy(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, deep bool) error {
+	info, headers, err := c.Object(ctx, srcContainer, srcObjectName)
+	if err != nil {
+		return err
+	}
+	if headers.IsLargeObjectSLO() {
+		return c.sloCopy(ctx, srcContainer, srcObjectName, dstContainer, dstObjectName, deep, info.ContentType, headers.ObjectMetadata().ObjectHeaders())
+	}
+	segmentContainer, segments, err := c.getAllSegments(ctx, srcContainer, srcObjectName, headers)
+	if err != nil {
+		return err
+	}
+	manifest := headers["X-Object-Manifest"]
+	if deep {
+		segmentPath, err := swiftSegmentPath(dstObjectName)
+		if err != nil {
+			return err
+		}
+		segments, err = c.copySegments(ctx, segmentContainer, segments, segmentPath)
+		if err != nil {
+			return err
+		}
+		manifest = segmentContainer + "/" + segmentPath
+	}
+	return c.createDLOManifest(ctx, dstContainer, dstObjectName, manifest, info.ContentType, headers.ObjectMetadata().ObjectHeaders())
+}
+
+// copySegments copies segments server side within segmentContainer to
+// segmentPath returning the new segments
+func (c *Connection) copySegments(ctx context.Context, segmentContainer string, segments []Object, segmentPath string) ([]Object, error) {
+	ctx = withSegmentAccess(ctx)
+	newSegments := make([]Object, len(segments))
+	for i, segment := range segments {
+		newSegments[i] = segment
+		newSegments[i].Name = getSegment(segmentPath, i+1)
+		_, err := c.ObjectCopy(ctx, segmentContainer, segment.Name, segmentContainer, newSegments[i].Name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newSegments, nil
+}
+
+// LargeObjectCleanupSegments deletes the segments left in the segment
+// container for the large object container, objectName which its
+// manifest doesn't refer to, for instance by uploads which failed or
+// were abandoned.
+//
+// The segment container is searched under the manifest's segment
+// prefix.  If the object was created with
+// LargeObjectOpts.ObjectSegmentDir the whole pseudo-directory for the
+// object is searched so segments from earlier uploads of the object
+// are found too.
+//
+// This can't tell a failed upload from one which is still running, so
+// with ObjectSegmentDir it deletes the segments of any upload of the
+// same object in progress, which then fails when it writes its
+// manifest.  Use LargeObjectCleanupSegmentsOlderThan to leave recent
+// segments alone if uploads may be running.
+//
+// Likewise it can't see other manifests referring to the segments, so
+// with ObjectSegmentDir it deletes the segments of earlier uploads of
+// the object still used by a shallow LargeObjectCopy of it, breaking
+// the copy.  Make such copies with deep set before cleaning up.
+//
+// It returns NotLargeObject if the object isn't a large object.
+func (c *Connection) LargeObjectCleanupSegments(ctx context.Context, container string, objectName string) error {
+	return c.LargeObjectCleanupSegmentsOlderThan(ctx, container, objectName, 0)
+}
+
+// LargeObjectCleanupSegmentsOlderThan is like
+// LargeObjectCleanupSegments but only deletes unreferenced segments
+// which were last modified at least minAge ago, so the segments of
+// uploads started since then are left alone.  minAge should be longer
+// than an upload of the object can take.
+func (c *Connection) LargeObjectCleanupSegmentsOlderThan(ctx context.Context, container string, objectName string, minAge time.Duration) error {
+	_, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return err
+	}
+	segmentContainer, segments, err := c.getAllSegments(ctx, container, objectName, headers)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	referenced := make(map[string]struct{}, len(segments))
+	for _, segment := range segments {
+		referenced[segment.Name] = struct{}{}
+	}
+	segmentPath := gopath.Dir(segments[0].Name)
+	objectDir := objectSegmentDir(container, objectName)
+	wholeDir := gopath.Dir(segmentPath) == objectDir
+	prefix := segmentPath + "/"
+	if wholeDir {
+		prefix = objectDir + "/"
+	}
+	ctx = withSegmentAccess(ctx)
+	objects, err := c.ObjectsAll(ctx, segmentContainer, &ObjectsOpts{Prefix: prefix})
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-minAge)
+	for _, object := range objects {
+		name := object.Name
+		if _, ok := referenced[name]; ok {
+			continue
+		}
+		if minAge > 0 && object.LastModified.After(cutoff) {
+			continue
+		}
+		// Segments in the object's directory are <upload>/<number>
+		// so skip anything deeper which belongs to objects under it
+		if wholeDir && strings.Count(name[len(prefix):], "/") != 1 {
+			continue
+		}
+		err = c.ObjectDelete(ctx, segmentContainer, name)
+		// Don't fail on ObjectNotFound because eventual consistency
+		// makes this situation normal.
+		if err != nil && err != ObjectNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
 // LargeObjectGetSegments returns all the segments that compose an object
 // If the object is a Dynamic Large Object (DLO), it just returns the objects
 // that have the prefix as indicated by the manifest.
 // If the object is a Static Large Object (SLO), it retrieves the JSON content
 // of the manifest and return all the segments of it.
+//
+// The Name, Bytes, Hash, ContentType and LastModified of each segment
+// are filled in from the container listing or the manifest without
+// needing a HEAD of each segment.
 func (c *Connection) LargeObjectGetSegments(ctx context.Context, container string, path string) (string, []Object, error) {
 	_, headers, err := c.Object(ctx, container, path)
 	if err != nil {
@@ -271,6 +674,196 @@ func (c *Connection) LargeObjectGetSegments(ctx context.Context, container strin
 	return c.getAllSegments(ctx, container, path, headers)
 }
 
+// SegmentInfo describes one entry of a large object's manifest
+type SegmentInfo struct {
+	Path         string    // "container/object" of the segment, or "container/prefix" for a DLO
+	Etag         string    // MD5 hash of the segment, or of its manifest if SubSLO
+	Size         int64     // Size of the segment in bytes
+	ContentType  string    // Content type of the segment
+	LastModified time.Time // Last modified time of the segment
+	Range        string    // Byte range of the segment used, eg "0-99", or "" for all of it
+	SubSLO       bool      // Set if the segment is itself a static large object
+}
+
+// ObjectManifest returns the manifest of a large object as stored by
+// Swift without reading the segments or assembling the object.
+//
+// For a Static Large Object (SLO) it returns an entry for each
+// segment listed in the manifest fetched with
+// "multipart-manifest=get".  For a Dynamic Large Object (DLO) the
+// manifest is just a prefix so it returns a single entry with Path
+// set to the "container/prefix" from X-Object-Manifest and the other
+// fields empty.  Use LargeObjectGetSegments to list the segments of a
+// DLO.
+//
+// It returns NotLargeObject if the object isn't a large object.
+func (c *Connection) ObjectManifest(ctx context.Context, container string, objectName string) ([]SegmentInfo, error) {
+	_, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	switch headers.ObjectType() {
+	case DynamicLargeObjectType:
+		return []SegmentInfo{{Path: headers["X-Object-Manifest"]}}, nil
+	case StaticLargeObjectType:
+	default:
+		return nil, NotLargeObject
+	}
+	segmentList, err := c.getSLOManifest(ctx, container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	manifest := make([]SegmentInfo, 0, len(segmentList))
+	for _, segment := range segmentList {
+		info := SegmentInfo{
+			Path:        strings.TrimPrefix(segment.Name, "/"),
+			Etag:        segment.Hash,
+			Size:        segment.Bytes,
+			ContentType: segment.ContentType,
+			Range:       segment.Range,
+			SubSLO:      segment.SubSLO,
+		}
+		if segment.LastModified != "" {
+			info.LastModified, err = parseServerLastModified(segment.LastModified)
+			if err != nil {
+				return nil, err
+			}
+		}
+		manifest = append(manifest, info)
+	}
+	return manifest, nil
+}
+
+// SegmentProblem describes something wrong with a large object found
+// by LargeObjectVerify
+type SegmentProblem struct {
+	Path    string // "container/object" of the segment or "" if it is a problem with the whole object
+	Problem string // Description of the problem
+}
+
+// LargeObjectReport is the result of LargeObjectVerify
+type LargeObjectReport struct {
+	Type         ObjectType       // StaticLargeObjectType or DynamicLargeObjectType
+	Segments     int              // Number of segments checked
+	Etag         string           // Etag of the manifest (SLO only)
+	ComputedEtag string           // Etag computed from the manifest (SLO only)
+	Problems     []SegmentProblem // Problems found, empty if none
+}
+
+// OK returns true if no problems were found
+func (r *LargeObjectReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// add records a problem with the segment at path
+func (r *LargeObjectReport) add(path string, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, SegmentProblem{Path: path, Problem: fmt.Sprintf(format, args...)})
+}
+
+// LargeObjectVerify checks the segments of a large object are intact
+// so lost or corrupted segments are found before a GET fails part way
+// through.
+//
+// For a Static Large Object (SLO) every segment in the manifest is
+// read with a HEAD and its size and hash are checked against the
+// manifest, and the Etag of the manifest is recomputed from the
+// segments.  For a Dynamic Large Object (DLO) the segments under the
+// manifest's prefix are checked to be numbered contiguously from 1,
+// as written by DynamicLargeObjectCreate, and to add up to the size
+// of the object.
+//
+// Any differences are returned in the Problems of the report rather
+// than as an error.  The error is only set if the checks couldn't be
+// made, and is NotLargeObject if the object isn't a large object.
+func (c *Connection) LargeObjectVerify(ctx context.Context, container string, objectName string) (*LargeObjectReport, error) {
+	info, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return nil, err
+	}
+	report := &LargeObjectReport{Type: headers.ObjectType()}
+	switch report.Type {
+	case StaticLargeObjectType:
+		err = c.verifySLO(ctx, container, objectName, info, report)
+	case DynamicLargeObjectType:
+		err = c.verifyDLO(ctx, info, headers, report)
+	default:
+		return nil, NotLargeObject
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// verifySLO checks the segments of a static large object against its manifest
+func (c *Connection) verifySLO(ctx context.Context, container string, objectName string, info Object, report *LargeObjectReport) error {
+	manifest, err := c.ObjectManifest(ctx, container, objectName)
+	if err != nil {
+		return err
+	}
+	ctx = withSegmentAccess(ctx)
+	for _, entry := range manifest {
+		report.Segments++
+		segmentContainer, segmentName, err := parseFullPath(entry.Path)
+		if err != nil {
+			return err
+		}
+		segment, _, err := c.Object(ctx, segmentContainer, segmentName)
+		if err == ObjectNotFound {
+			report.add(entry.Path, "segment is missing")
+			continue
+		} else if err != nil {
+			return err
+		}
+		if segment.Bytes != entry.Size {
+			report.add(entry.Path, "size %d doesn't match %d in manifest", segment.Bytes, entry.Size)
+		}
+		if !etagMatches(segment.Hash, strings.ToLower(trimEtag(entry.Etag))) {
+			report.add(entry.Path, "hash %q doesn't match %q in manifest", segment.Hash, entry.Etag)
+		}
+	}
+	report.Etag = info.Hash
+	report.ComputedEtag = manifestEtag(manifest)
+	if !etagMatches(report.Etag, report.ComputedEtag) {
+		report.add("", "manifest etag %q doesn't match %q computed from segments", report.Etag, report.ComputedEtag)
+	}
+	return nil
+}
+
+// verifyDLO checks the segments of a dynamic large object are contiguous
+func (c *Connection) verifyDLO(ctx context.Context, info Object, headers Headers, report *LargeObjectReport) error {
+	segmentContainer, segmentPath, err := parseFullPath(headers["X-Object-Manifest"])
+	if err != nil {
+		return err
+	}
+	segments, err := c.getAllDLOSegments(withSegmentAccess(ctx), segmentContainer, segmentPath)
+	if err != nil {
+		return err
+	}
+	var total int64
+	next := 1
+	for _, segment := range segments {
+		report.Segments++
+		total += segment.Bytes
+		suffix := strings.TrimPrefix(segment.Name, segmentPath+"/")
+		partNumber, err := strconv.Atoi(suffix)
+		if err != nil || suffix == segment.Name || getSegment(segmentPath, partNumber) != segment.Name {
+			// Not named by this library so can't check the order
+			continue
+		}
+		for ; next < partNumber; next++ {
+			report.add(segmentContainer+"/"+getSegment(segmentPath, next), "segment is missing")
+		}
+		if partNumber == next {
+			next++
+		}
+	}
+	if total != info.Bytes {
+		report.add("", "segments total %d bytes but object is %d bytes", total, info.Bytes)
+	}
+	return nil
+}
+
 // Seek sets the offset for the next write operation
 func (file *largeObjectCreateFile) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
@@ -293,6 +886,45 @@ func (file *largeObjectCreateFile) Size() int64 {
 	return file.currentLength
 }
 
+// cleanupSegments is called with the error from writing the manifest.
+// If cleanupOnFailure is set it deletes the segments uploaded by this
+// writer and returns a *LargeObjectCleanupError, otherwise it returns
+// err unchanged.
+func (file *largeObjectCreateFile) cleanupSegments(ctx context.Context, err error) error {
+	if !file.cleanupOnFailure || len(file.segments) <= file.existingSegments {
+		return err
+	}
+	ctx = withSegmentAccess(ctx)
+	cleanupErr := &LargeObjectCleanupError{
+		Err:       err,
+		Container: file.segmentContainer,
+	}
+	for _, segment := range file.segments[file.existingSegments:] {
+		err := file.conn.ObjectDelete(ctx, file.segmentContainer, segment.Name)
+		// Don't fail on ObjectNotFound because eventual consistency
+		// makes this situation normal.
+		if err != nil && err != ObjectNotFound {
+			if cleanupErr.CleanupErr == nil {
+				cleanupErr.CleanupErr = err
+			}
+			continue
+		}
+		cleanupErr.Deleted = append(cleanupErr.Deleted, segment.Name)
+	}
+	if cleanupErr.CleanupErr == nil {
+		// Forget the deleted segments so a retry uploads them again
+		file.segments = file.segments[:file.existingSegments]
+		file.currentLength = 0
+		for _, obj := range file.segments {
+			file.currentLength += obj.Bytes
+		}
+		if file.filePos > file.currentLength {
+			file.filePos = file.currentLength
+		}
+	}
+	return cleanupErr
+}
+
 func withLORetry(expectedSize int64, fn func() (Headers, int64, error)) (err error) {
 	endTimer := time.NewTimer(readAfterWriteTimeout)
 	defer endTimer.Stop()
@@ -323,7 +955,7 @@ func (c *Connection) waitForSegmentsToShowUp(ctx context.Context, container, obj
 	err = withLORetry(expectedSize, func() (Headers, int64, error) {
 		var info Object
 		var headers Headers
-		info, headers, err = c.objectBase(ctx, container, objectName)
+		info, headers, err = c.objectBase(ctx, container, objectName, nil)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -341,13 +973,18 @@ func (file *largeObjectCreateFile) WriteWithContext(ctx context.Context, buf []b
 	var relativeFilePos int
 	writeSegmentIdx := 0
 	for i, obj := range file.segments {
-		if file.filePos < sz+obj.Bytes || (i == len(file.segments)-1 && file.filePos < sz+file.minChunkSize) {
+		if file.filePos < sz+obj.Bytes || (i == len(file.segments)-1 && !file.cut && file.filePos < sz+file.minChunkSize) {
 			relativeFilePos = int(file.filePos - sz)
 			break
 		}
 		writeSegmentIdx++
 		sz += obj.Bytes
 	}
+	file.cut = false
+	if progressFromContext(ctx) != nil {
+		// Don't report the progress of each segment
+		ctx = WithProgress(ctx, nil)
+	}
 	sizeToWrite := len(buf)
 	for offset := 0; offset < sizeToWrite; {
 		newSegment, n, err := file.writeSegment(ctx, buf[offset:], writeSegmentIdx, relativeFilePos)
@@ -362,6 +999,9 @@ func (file *largeObjectCreateFile) WriteWithContext(ctx context.Context, buf []b
 		offset += n
 		writeSegmentIdx++
 		relativeFilePos = 0
+		if file.progress != nil {
+			file.progress(file.filePos+int64(offset), -1)
+		}
 	}
 	file.filePos += int64(sizeToWrite)
 	file.currentLength = 0
@@ -377,8 +1017,9 @@ func (file *largeObjectCreateFile) writeSegment(ctx context.Context, buf []byte,
 		existingSegment *Object
 		segmentSize     int
 	)
+	ctx = withSegmentAccess(ctx)
 	segmentName := getSegment(file.prefix, writeSegmentIdx+1)
-	sizeToRead := int(file.chunkSize)
+	sizeToRead := int(file.segmentChunkSize(writeSegmentIdx))
 	if writeSegmentIdx < len(file.segments) {
 		existingSegment = &file.segments[writeSegmentIdx]
 		if writeSegmentIdx != len(file.segments)-1 {
@@ -424,26 +1065,109 @@ func (file *largeObjectCreateFile) writeSegment(ctx context.Context, buf []byte,
 		readers = append(readers, tailSegmentReader)
 	}
 	segmentReader := io.MultiReader(readers...)
-	headers, err := file.conn.ObjectPut(ctx, file.segmentContainer, segmentName, segmentReader, true, "", file.contentType, nil)
+	var segmentHeaders Headers
+	if !file.deleteAt.IsZero() {
+		segmentHeaders = Headers{}
+		segmentHeaders.SetDeleteAt(file.deleteAt)
+	}
+	headers, err := file.conn.ObjectPut(ctx, file.segmentContainer, segmentName, segmentReader, true, "", file.contentType, segmentHeaders)
 	if err != nil {
 		return nil, 0, err
 	}
 	return &Object{Name: segmentName, Bytes: int64(segmentSize), Hash: headers["Etag"]}, sizeToRead, nil
 }
 
+// CutSegment makes the next write start a new segment - see SegmentCutter
+func (file *largeObjectCreateFile) CutSegment(ctx context.Context) error {
+	file.cut = true
+	return nil
+}
+
+// segmentChunkSize returns the size to make the segment with index idx
+func (file *largeObjectCreateFile) segmentChunkSize(idx int) int64 {
+	if file.chunkSizeFn == nil {
+		return file.chunkSize
+	}
+	size := file.chunkSizeFn(idx)
+	if size <= 0 {
+		size = file.chunkSize
+	}
+	if size < file.minChunkSize {
+		size = file.minChunkSize
+	}
+	return size
+}
+
+// nextChunkSize returns the number of bytes which can be written at
+// the current position before the segment being written is full
+func (file *largeObjectCreateFile) nextChunkSize() int64 {
+	var sz int64
+	for i, obj := range file.segments {
+		if file.filePos < sz+obj.Bytes {
+			end := sz + obj.Bytes
+			// The last segment can grow up to its chunk size
+			if i == len(file.segments)-1 && sz+file.segmentChunkSize(i) > end {
+				end = sz + file.segmentChunkSize(i)
+			}
+			return end - file.filePos
+		}
+		sz += obj.Bytes
+	}
+	return file.segmentChunkSize(len(file.segments))
+}
+
+// chunkSizer is satisfied by the large object files which can report
+// the size of the next segment
+type chunkSizer interface {
+	nextChunkSize() int64
+}
+
 func withBuffer(opts *LargeObjectOpts, lo LargeObjectFile) LargeObjectFile {
+	if sizer, ok := lo.(chunkSizer); ok && opts.ChunkSizeFn != nil && !opts.NoBuffer {
+		return &segmentBufferedLargeObjectFile{
+			LargeObjectFile: lo,
+			sizer:           sizer,
+		}
+	}
 	if !opts.NoBuffer {
 		return &bufferedLargeObjectFile{
 			LargeObjectFile: lo,
-			bw:              bufio.NewWriterSize(lo, int(opts.ChunkSize)),
+			bw:              getChunkWriter(lo, int(opts.ChunkSize)),
+			size:            int(opts.ChunkSize),
 		}
 	}
 	return lo
 }
 
+// chunkWriterPools holds a *sync.Pool of *bufio.Writer for each
+// chunk size so the buffers can be reused by later uploads rather
+// than allocating a new chunk sized buffer for each one.
+var chunkWriterPools sync.Map
+
+// getChunkWriter returns a *bufio.Writer with a buffer of size bytes
+// writing to w, reusing a pooled one if possible
+func getChunkWriter(w io.Writer, size int) *bufio.Writer {
+	pool, _ := chunkWriterPools.LoadOrStore(size, new(sync.Pool))
+	if bw, ok := pool.(*sync.Pool).Get().(*bufio.Writer); ok {
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriterSize(w, size)
+}
+
+// putChunkWriter returns bw got with getChunkWriter(w, size) to the
+// pool.  It mustn't be used afterwards.
+func putChunkWriter(bw *bufio.Writer, size int) {
+	bw.Reset(nil)
+	if pool, ok := chunkWriterPools.Load(size); ok {
+		pool.(*sync.Pool).Put(bw)
+	}
+}
+
 type bufferedLargeObjectFile struct {
 	LargeObjectFile
-	bw *bufio.Writer
+	bw   *bufio.Writer // nil once closed
+	size int           // the size bw was got with
 }
 
 func (blo *bufferedLargeObjectFile) Close() error {
@@ -451,10 +1175,18 @@ func (blo *bufferedLargeObjectFile) Close() error {
 }
 
 func (blo *bufferedLargeObjectFile) CloseWithContext(ctx context.Context) error {
+	if blo.bw == nil {
+		return blo.LargeObjectFile.CloseWithContext(ctx)
+	}
 	err := blo.bw.Flush()
 	if err != nil {
+		// Keep the buffer so the data isn't lost - a bufio.Writer
+		// remembers the error so closing again fails too rather
+		// than writing a truncated object
 		return err
 	}
+	putChunkWriter(blo.bw, blo.size)
+	blo.bw = nil
 	return blo.LargeObjectFile.CloseWithContext(ctx)
 }
 
@@ -463,11 +1195,14 @@ func (blo *bufferedLargeObjectFile) WriteWithContext(_ context.Context, p []byte
 }
 
 func (blo *bufferedLargeObjectFile) Write(p []byte) (n int, err error) {
+	if blo.bw == nil {
+		return 0, newError(0, "Write on closed file")
+	}
 	return blo.bw.Write(p)
 }
 
 func (blo *bufferedLargeObjectFile) Seek(offset int64, whence int) (int64, error) {
-	err := blo.bw.Flush()
+	err := blo.flush()
 	if err != nil {
 		return 0, err
 	}
@@ -475,11 +1210,118 @@ func (blo *bufferedLargeObjectFile) Seek(offset int64, whence int) (int64, error
 }
 
 func (blo *bufferedLargeObjectFile) Size() int64 {
+	if blo.bw == nil {
+		return blo.LargeObjectFile.Size()
+	}
 	return blo.LargeObjectFile.Size() + int64(blo.bw.Buffered())
 }
 
 func (blo *bufferedLargeObjectFile) Flush(ctx context.Context) error {
-	err := blo.bw.Flush()
+	err := blo.flush()
+	if err != nil {
+		return err
+	}
+	return blo.LargeObjectFile.Flush(ctx)
+}
+
+// CutSegment writes out anything buffered as the end of the segment - see SegmentCutter
+func (blo *bufferedLargeObjectFile) CutSegment(ctx context.Context) error {
+	err := blo.flush()
+	if err != nil {
+		return err
+	}
+	return cutSegment(ctx, blo.LargeObjectFile)
+}
+
+// cutSegment calls CutSegment on lo if it supports it
+func cutSegment(ctx context.Context, lo LargeObjectFile) error {
+	if cutter, ok := lo.(SegmentCutter); ok {
+		return cutter.CutSegment(ctx)
+	}
+	return nil
+}
+
+// flush writes out anything buffered
+func (blo *bufferedLargeObjectFile) flush() error {
+	if blo.bw == nil {
+		return nil
+	}
+	return blo.bw.Flush()
+}
+
+// segmentBufferedLargeObjectFile buffers writes until they fill a
+// whole segment for when the segments have different sizes
+type segmentBufferedLargeObjectFile struct {
+	LargeObjectFile
+	sizer chunkSizer
+	buf   []byte
+}
+
+func (blo *segmentBufferedLargeObjectFile) Close() error {
+	return blo.CloseWithContext(context.Background())
+}
+
+func (blo *segmentBufferedLargeObjectFile) CloseWithContext(ctx context.Context) error {
+	err := blo.flushBuffer(ctx)
+	if err != nil {
+		return err
+	}
+	return blo.LargeObjectFile.CloseWithContext(ctx)
+}
+
+// flushBuffer writes out anything buffered
+func (blo *segmentBufferedLargeObjectFile) flushBuffer(ctx context.Context) error {
+	if len(blo.buf) == 0 {
+		return nil
+	}
+	_, err := blo.LargeObjectFile.WriteWithContext(ctx, blo.buf)
+	blo.buf = blo.buf[:0]
+	return err
+}
+
+// CutSegment writes out anything buffered as the end of the segment - see SegmentCutter
+func (blo *segmentBufferedLargeObjectFile) CutSegment(ctx context.Context) error {
+	err := blo.flushBuffer(ctx)
+	if err != nil {
+		return err
+	}
+	return cutSegment(ctx, blo.LargeObjectFile)
+}
+
+func (blo *segmentBufferedLargeObjectFile) Write(p []byte) (n int, err error) {
+	return blo.WriteWithContext(context.Background(), p)
+}
+
+func (blo *segmentBufferedLargeObjectFile) WriteWithContext(ctx context.Context, p []byte) (n int, err error) {
+	blo.buf = append(blo.buf, p...)
+	for {
+		size := blo.sizer.nextChunkSize()
+		if size <= 0 || int64(len(blo.buf)) < size {
+			break
+		}
+		_, err = blo.LargeObjectFile.WriteWithContext(ctx, blo.buf[:size])
+		if err != nil {
+			return 0, err
+		}
+		blo.buf = blo.buf[:copy(blo.buf, blo.buf[size:])]
+	}
+	return len(p), nil
+}
+
+func (blo *segmentBufferedLargeObjectFile) Seek(offset int64, whence int) (int64, error) {
+	err := blo.flushBuffer(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return blo.LargeObjectFile.Seek(offset, whence)
+}
+
+func (blo *segmentBufferedLargeObjectFile) Size() int64 {
+	return blo.LargeObjectFile.Size() + int64(len(blo.buf))
+}
+
+func (blo *segmentBufferedLargeObjectFile) Flush(ctx context.Context) error {
+	err := blo.flushBuffer(ctx)
 	if err != nil {
 		return err
 	}
@@ -172,3 +172,22 @@ func (m Metadata) GetModTime() (t time.Time, err error) {
 func (m Metadata) SetModTime(t time.Time) {
 	m["mtime"] = TimeToFloatString(t)
 }
+
+// SetDeleteAt sets the X-Delete-At header so the server deletes the
+// object at the time passed in.
+//
+// Pass these Headers when creating or updating an object.
+func (h Headers) SetDeleteAt(t time.Time) {
+	delete(h, "X-Delete-After")
+	h["X-Delete-At"] = strconv.FormatInt(t.Unix(), 10)
+}
+
+// SetDeleteAfter sets the X-Delete-After header so the server deletes
+// the object after the duration passed in.  It is rounded down to a
+// whole number of seconds.
+//
+// Pass these Headers when creating or updating an object.
+func (h Headers) SetDeleteAfter(d time.Duration) {
+	delete(h, "X-Delete-At")
+	h["X-Delete-After"] = strconv.FormatInt(int64(d/time.Second), 10)
+}
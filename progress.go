@@ -0,0 +1,87 @@
+// Upload and download progress reporting
+
+package swift
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// ProgressFunc is called as data is uploaded or downloaded with the
+// number of bytes transferred so far and the total number to
+// transfer, or -1 if that isn't known.
+type ProgressFunc func(bytesWritten int64, totalBytes int64)
+
+// progressKey is the context key for the ProgressFunc
+type progressKey struct{}
+
+// WithProgress returns a context which makes ObjectPut, ObjectCreate
+// and the functions built on them call fn as the object's data is
+// uploaded.
+//
+// The total is taken from the Content-Length header if one is passed
+// in or ObjectPut can work it out from the reader, otherwise it is
+// -1.  For large objects set
+// LargeObjectOpts.Progress instead which reports the progress of the
+// whole object rather than of each segment.
+//
+// ObjectOpen, ObjectGet and the functions built on them call fn as
+// the object is read - see ObjectOpenWithOpts.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFromContext returns the ProgressFunc set by WithProgress or
+// nil if there isn't one
+func progressFromContext(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader calls fn after every Read with the bytes read so far
+type progressReader struct {
+	r     io.Reader
+	fn    ProgressFunc
+	n     int64
+	total int64
+}
+
+// withProgress wraps r so it reports progress if ctx has a
+// ProgressFunc, reading the total from the Content-Length in h.
+func withProgress(ctx context.Context, r io.Reader, h Headers) io.Reader {
+	fn := progressFromContext(ctx)
+	if fn == nil {
+		return r
+	}
+	total := int64(-1)
+	if contentLength, err := strconv.ParseInt(h["Content-Length"], 10, 64); err == nil {
+		total = contentLength
+	}
+	return &progressReader{r: r, fn: fn, total: total}
+}
+
+// Read bytes - see io.Reader
+func (pr *progressReader) Read(p []byte) (n int, err error) {
+	n, err = pr.r.Read(p)
+	if n > 0 {
+		pr.n += int64(n)
+		pr.fn(pr.n, pr.total)
+	}
+	return n, err
+}
+
+// Seek passes the seek on to the underlying reader so the body can be
+// rewound for a retry, failing if it can't seek.
+func (pr *progressReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := pr.r.(io.Seeker)
+	if !ok {
+		return 0, errors.New("can't seek")
+	}
+	pos, err := seeker.Seek(offset, whence)
+	if err == nil {
+		pr.n = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,79 @@
+// Container and account quota manipulation
+
+package swift
+
+import (
+	"context"
+	"strconv"
+)
+
+// quotaHeader returns the value to set a quota header to - a negative
+// quota clears it
+func quotaHeader(quota int64) string {
+	if quota < 0 {
+		return ""
+	}
+	return strconv.FormatInt(quota, 10)
+}
+
+// parseQuota reads a quota header returning -1 if it isn't set
+func parseQuota(headers Headers, key string) (int64, error) {
+	value := headers[key]
+	if value == "" {
+		return -1, nil
+	}
+	quota, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1, newErrorf(0, "Bad Header '%s': '%s': %s", key, value, err)
+	}
+	return quota, nil
+}
+
+// ContainerSetQuota sets the maximum number of bytes and objects the
+// container may hold.
+//
+// Pass -1 for either to clear that quota.  Uploads which would exceed
+// a quota fail with TooLargeObject.
+func (c *Connection) ContainerSetQuota(ctx context.Context, container string, bytes int64, count int64) error {
+	return c.ContainerUpdate(ctx, container, Headers{
+		"X-Container-Meta-Quota-Bytes": quotaHeader(bytes),
+		"X-Container-Meta-Quota-Count": quotaHeader(count),
+	})
+}
+
+// ContainerQuota returns the byte and object count quotas of the
+// container, or -1 for any which aren't set.
+func (c *Connection) ContainerQuota(ctx context.Context, container string) (bytes int64, count int64, err error) {
+	_, headers, err := c.Container(ctx, container)
+	if err != nil {
+		return -1, -1, err
+	}
+	if bytes, err = parseQuota(headers, "X-Container-Meta-Quota-Bytes"); err != nil {
+		return -1, -1, err
+	}
+	if count, err = parseQuota(headers, "X-Container-Meta-Quota-Count"); err != nil {
+		return -1, -1, err
+	}
+	return bytes, count, nil
+}
+
+// AccountSetQuota sets the maximum number of bytes the account may
+// hold.
+//
+// Pass -1 to clear the quota.  Swift only allows reseller admins to
+// set account quotas.
+func (c *Connection) AccountSetQuota(ctx context.Context, bytes int64) error {
+	return c.AccountUpdate(ctx, Headers{
+		"X-Account-Meta-Quota-Bytes": quotaHeader(bytes),
+	})
+}
+
+// AccountQuota returns the byte quota of the account or -1 if it
+// isn't set.
+func (c *Connection) AccountQuota(ctx context.Context) (bytes int64, err error) {
+	_, headers, err := c.Account(ctx)
+	if err != nil {
+		return -1, err
+	}
+	return parseQuota(headers, "X-Account-Meta-Quota-Bytes")
+}
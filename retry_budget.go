@@ -0,0 +1,63 @@
+// Retry budget shared between operations
+
+package swift
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudget caps the number of retries made by all the operations
+// sharing it so a failing cluster isn't overloaded by every request
+// retrying at once.
+//
+// It is a token bucket - each retry takes a token and tokens are
+// refilled steadily over time. When the budget is spent operations
+// fail with the error from their last attempt instead of retrying.
+//
+// Set it in Connection.RetryBudget. A RetryBudget is safe for
+// concurrent use and may be shared by several Connections.
+type RetryBudget struct {
+	mu     sync.Mutex
+	max    float64       // maximum number of tokens
+	per    time.Duration // time to refill max tokens
+	tokens float64       // tokens available
+	last   time.Time     // time tokens was last updated
+}
+
+// NewRetryBudget makes a RetryBudget allowing retries retries every
+// per. The budget starts full.
+func NewRetryBudget(retries int, per time.Duration) *RetryBudget {
+	return &RetryBudget{
+		max:    float64(retries),
+		per:    per,
+		tokens: float64(retries),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a retry from the budget returning false if there are
+// none left.
+func (b *RetryBudget) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if b.per > 0 {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.per) * b.max
+		if b.tokens > b.max {
+			b.tokens = b.max
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryAllowed returns true if a retry may be made - it always is
+// unless a RetryBudget is set and spent
+func (c *Connection) retryAllowed() bool {
+	return c.RetryBudget == nil || c.RetryBudget.Allow()
+}
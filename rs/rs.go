@@ -3,8 +3,10 @@ package rs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ncw/swift/v2"
 )
@@ -71,6 +73,62 @@ func (c *RsConnection) ContainerCDNDisable(ctx context.Context, container string
 	return err
 }
 
+// CDNContainerInfo is the CDN metadata for a container.
+type CDNContainerInfo struct {
+	Enabled      bool   // whether the container is published on the CDN
+	URI          string // http URI of the container on the CDN
+	SSLURI       string // https URI of the container on the CDN
+	StreamingURI string // URI for streaming the container's objects
+	IOSURI       string // URI for streaming the container's objects to iOS devices
+	TTL          int    // seconds objects are cached on the CDN for
+	LogRetention bool   // whether the CDN access logs are kept
+}
+
+// parseCDNContainerInfo reads the CDN metadata from the headers
+// returned by ContainerCDNMeta.
+func parseCDNContainerInfo(h swift.Headers) (info CDNContainerInfo, err error) {
+	info.URI = h["X-Cdn-Uri"]
+	info.SSLURI = h["X-Cdn-Ssl-Uri"]
+	info.StreamingURI = h["X-Cdn-Streaming-Uri"]
+	info.IOSURI = h["X-Cdn-Ios-Uri"]
+	if info.Enabled, err = parseBoolHeader(h, "X-Cdn-Enabled"); err != nil {
+		return info, err
+	}
+	if info.LogRetention, err = parseBoolHeader(h, "X-Log-Retention"); err != nil {
+		return info, err
+	}
+	if ttl, ok := h["X-Ttl"]; ok {
+		if info.TTL, err = strconv.Atoi(ttl); err != nil {
+			return info, fmt.Errorf("bad X-Ttl header %q: %w", ttl, err)
+		}
+	}
+	return info, nil
+}
+
+// parseBoolHeader parses the boolean header key from h which is
+// false if missing.
+func parseBoolHeader(h swift.Headers, key string) (bool, error) {
+	value, ok := h[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("bad %s header %q: %w", key, value, err)
+	}
+	return b, nil
+}
+
+// ContainerCDNInfo returns the CDN metadata for a container parsed
+// from ContainerCDNMeta.
+func (c *RsConnection) ContainerCDNInfo(ctx context.Context, container string) (CDNContainerInfo, error) {
+	headers, err := c.ContainerCDNMeta(ctx, container)
+	if err != nil {
+		return CDNContainerInfo{}, err
+	}
+	return parseCDNContainerInfo(headers)
+}
+
 // ContainerCDNMeta returns the CDN metadata for a container.
 func (c *RsConnection) ContainerCDNMeta(ctx context.Context, container string) (swift.Headers, error) {
 	_, headers, err := c.manage(ctx, swift.RequestOpts{
@@ -82,3 +140,25 @@ func (c *RsConnection) ContainerCDNMeta(ctx context.Context, container string) (
 	})
 	return headers, err
 }
+
+// ObjectCDNPurge removes an object from the CDN edge caches so the
+// next request for it fetches the current version from the container.
+//
+// If emails are passed a notification is sent to each of them when
+// the purge has completed.
+func (c *RsConnection) ObjectCDNPurge(ctx context.Context, container string, object string, emails []string) error {
+	h := swift.Headers{}
+	if len(emails) > 0 {
+		h["X-Purge-Email"] = strings.Join(emails, ", ")
+	}
+
+	_, _, err := c.manage(ctx, swift.RequestOpts{
+		Container:  container,
+		ObjectName: object,
+		Operation:  "DELETE",
+		ErrorMap:   swift.ObjectErrorMap,
+		NoResponse: true,
+		Headers:    h,
+	})
+	return err
+}
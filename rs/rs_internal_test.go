@@ -0,0 +1,52 @@
+// This tests the rs package internals
+//
+// It does not require access to a swift server
+package rs
+
+import (
+	"testing"
+
+	"github.com/ncw/swift/v2"
+)
+
+func TestInternalParseCDNContainerInfo(t *testing.T) {
+	info, err := parseCDNContainerInfo(swift.Headers{
+		"X-Cdn-Enabled":       "True",
+		"X-Cdn-Uri":           "http://cdn.example.com",
+		"X-Cdn-Ssl-Uri":       "https://ssl.example.com",
+		"X-Cdn-Streaming-Uri": "http://stream.example.com",
+		"X-Cdn-Ios-Uri":       "http://ios.example.com",
+		"X-Ttl":               "259200",
+		"X-Log-Retention":     "False",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CDNContainerInfo{
+		Enabled:      true,
+		URI:          "http://cdn.example.com",
+		SSLURI:       "https://ssl.example.com",
+		StreamingURI: "http://stream.example.com",
+		IOSURI:       "http://ios.example.com",
+		TTL:          259200,
+		LogRetention: false,
+	}
+	if info != want {
+		t.Errorf("want %+v got %+v", want, info)
+	}
+
+	info, err = parseCDNContainerInfo(swift.Headers{})
+	if err != nil || info != (CDNContainerInfo{}) {
+		t.Errorf("Empty headers: got %+v, %v", info, err)
+	}
+
+	for _, h := range []swift.Headers{
+		{"X-Ttl": "forever"},
+		{"X-Cdn-Enabled": "maybe"},
+		{"X-Log-Retention": "sometimes"},
+	} {
+		if _, err = parseCDNContainerInfo(h); err == nil {
+			t.Errorf("%v: expecting error", h)
+		}
+	}
+}
@@ -81,6 +81,34 @@ func TestCDNMeta(t *testing.T) {
 	}
 }
 
+func TestCDNInfo(t *testing.T) {
+	info, err := c.ContainerCDNInfo(context.Background(), CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Enabled || info.URI == "" {
+		t.Errorf("CDN is not enabled: %+v", info)
+	}
+}
+
+func TestCDNPurge(t *testing.T) {
+	ctx := context.Background()
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	err = c.ObjectCDNPurge(ctx, CONTAINER, OBJECT, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestCDNDisable(t *testing.T) {
 	err := c.ContainerCDNDisable(context.Background(), CONTAINER) // files stick in CDN until TTL expires
 	if err != nil {
@@ -0,0 +1,91 @@
+// Serving objects over HTTP
+
+package swift
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// serveRequestHeaders are copied from the incoming request to the
+// request to Swift so ranges and conditional requests work
+var serveRequestHeaders = []string{
+	"Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+}
+
+// serveResponseHeaders are copied from Swift's response to the
+// response written
+var serveResponseHeaders = []string{
+	"Accept-Ranges",
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Range",
+	"Content-Type",
+	"Etag",
+	"Expires",
+	"Last-Modified",
+}
+
+// ObjectServeHTTP serves the object to w in response to r, like
+// http.ServeContent, streaming it from Swift.
+//
+// The Range and conditional headers of r are passed on to Swift so
+// range requests get a 206 Partial Content response, and the
+// Content-Type, Content-Length, ETag and Last-Modified of the object
+// are sent.  A HEAD request sends the headers only.
+//
+// If Swift returns an error its status code is written to w, or 502
+// Bad Gateway if there isn't one.  The error is returned in either
+// case so it can be logged, as is any error streaming the body after
+// the headers have been written.
+func (c *Connection) ObjectServeHTTP(ctx context.Context, container string, objectName string, w http.ResponseWriter, r *http.Request) error {
+	operation := "GET"
+	if r.Method == "HEAD" {
+		operation = "HEAD"
+	}
+	h := Headers{}
+	for _, key := range serveRequestHeaders {
+		if value := r.Header.Get(key); value != "" {
+			h[key] = value
+		}
+	}
+	resp, _, err := c.storage(ctx, RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  operation,
+		ErrorMap:   ObjectErrorMap,
+		Headers:    h,
+		NoResponse: operation == "HEAD",
+	})
+	if err != nil {
+		code := http.StatusBadGateway
+		if swiftErr, ok := err.(*Error); ok && swiftErr.StatusCode != 0 {
+			code = swiftErr.StatusCode
+		}
+		if code == http.StatusNotModified {
+			w.WriteHeader(code)
+		} else {
+			http.Error(w, http.StatusText(code), code)
+		}
+		return err
+	}
+	for _, key := range serveResponseHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if operation == "HEAD" {
+		return nil
+	}
+	_, err = io.Copy(w, resp.Body)
+	checkClose(resp.Body, &err)
+	return err
+}
@@ -3,12 +3,15 @@ package swift
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // StaticLargeObjectCreateFile represents an open static large object
@@ -35,6 +38,8 @@ type swiftSegment struct {
 	Bytes        int64  `json:"bytes,omitempty"`
 	ContentType  string `json:"content_type,omitempty"`
 	LastModified string `json:"last_modified,omitempty"`
+	Range        string `json:"range,omitempty"`
+	SubSLO       bool   `json:"sub_slo,omitempty"`
 }
 
 // StaticLargeObjectCreateFile creates a static large object returning
@@ -67,6 +72,76 @@ func (c *Connection) StaticLargeObjectCreate(ctx context.Context, opts *LargeObj
 	return c.StaticLargeObjectCreateFile(ctx, opts)
 }
 
+// defaultMaxFileSize is Swift's default limit on the size of an object
+// uploaded with a single PUT
+const defaultMaxFileSize = 5*1024*1024*1024 + 2
+
+// ObjectPutAuto uploads contents to opts.Container, opts.ObjectName
+// as a normal object if it is small, or as a static large object if it
+// isn't, so the caller doesn't need to know the size in advance.
+//
+// Up to opts.Threshold bytes (opts.ChunkSize if not set) are read into
+// memory. If contents ends before then the object is uploaded with a
+// single PUT using opts.ContentType, opts.Headers, opts.CheckHash,
+// opts.Hash and the expiry options. Otherwise a static large object is
+// created with StaticLargeObjectCreate from opts and all of contents
+// is written to it.
+//
+// The threshold is reduced to the max_file_size the server reports in
+// its /info, or 5GiB if it doesn't, since larger objects can't be
+// uploaded in one go.
+//
+// If reading contents fails part way through a large object no
+// manifest is written, but the segments already uploaded are left
+// behind.
+func (c *Connection) ObjectPutAuto(ctx context.Context, opts *LargeObjectOpts, contents io.Reader) error {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = opts.ChunkSize
+	}
+	if threshold <= 0 {
+		threshold = defaultChunkSize
+	}
+	maxFileSize := int64(defaultMaxFileSize)
+	if info, err := c.cachedQueryInfo(ctx); err == nil && info.MaxFileSize() > 0 {
+		maxFileSize = info.MaxFileSize()
+	}
+	if threshold > maxFileSize {
+		threshold = maxFileSize
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, contents, threshold+1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n <= threshold {
+		h := Headers{}
+		for key, value := range opts.Headers {
+			h[key] = value
+		}
+		if !opts.DeleteAt.IsZero() {
+			h.SetDeleteAt(opts.DeleteAt)
+		} else if opts.DeleteAfter > 0 {
+			h.SetDeleteAfter(opts.DeleteAfter)
+		}
+		h["Content-Length"] = strconv.FormatInt(n, 10)
+		_, err = c.ObjectPut(ctx, opts.Container, opts.ObjectName, &buf, opts.CheckHash, opts.Hash, opts.ContentType, h)
+		return err
+	}
+
+	out, err := c.StaticLargeObjectCreate(ctx, opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, io.MultiReader(&buf, contents))
+	if err != nil {
+		// Don't write a manifest for a partial upload
+		return err
+	}
+	return out.CloseWithContext(ctx)
+}
+
 // StaticLargeObjectDelete deletes a static large object and all of its segments.
 func (c *Connection) StaticLargeObjectDelete(ctx context.Context, container string, path string) error {
 	info, err := c.cachedQueryInfo(ctx)
@@ -106,7 +181,46 @@ func (c *Connection) StaticLargeObjectMove(ctx context.Context, srcContainer str
 	return nil
 }
 
+// ComputeManifestEtag returns the ETag Swift will report for a static
+// large object made from segments.
+//
+// This is the hex encoded MD5 of the concatenated MD5 hashes of the
+// segments, so only the Hash of each segment is used.  Compare it
+// with the Hash of the manifest object to check the whole object
+// without reading it back.
+//
+// An Object can't describe a range so each segment is assumed to be
+// used whole.  Swift hashes the range in too for segments which only
+// use part of an object, so the result is wrong for those manifests -
+// LargeObjectVerify checks them using the ranges in the manifest.
+func ComputeManifestEtag(segments []Object) string {
+	manifest := make([]SegmentInfo, len(segments))
+	for i, segment := range segments {
+		manifest[i].Etag = segment.Hash
+	}
+	return manifestEtag(manifest)
+}
+
+// manifestEtag computes the Etag Swift gives a static large object
+// from its manifest, which includes the range of any segment which
+// only uses part of the object.
+func manifestEtag(manifest []SegmentInfo) string {
+	hash := md5.New()
+	for _, segment := range manifest {
+		etag := strings.ToLower(trimEtag(segment.Etag))
+		if segment.Range != "" {
+			etag += ":" + segment.Range + ";"
+		}
+		_, _ = io.WriteString(hash, etag)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
 // createSLOManifest creates a static large object manifest
+//
+// The etag of each segment is its MD5 hash.  Swift only accepts MD5
+// for the etags of segments (and for the Etag sent with a PUT) so
+// there is no way of using a different digest such as SHA-256 here.
 func (c *Connection) createSLOManifest(ctx context.Context, container string, path string, contentType string, segmentContainer string, segments []Object, h Headers) error {
 	sloSegments := make([]swiftSegment, len(segments))
 	for i, segment := range segments {
@@ -114,7 +228,12 @@ func (c *Connection) createSLOManifest(ctx context.Context, container string, pa
 		sloSegments[i].Etag = segment.Hash
 		sloSegments[i].Size = segment.Bytes
 	}
+	return c.putSLOManifest(ctx, container, path, contentType, sloSegments, h)
+}
 
+// putSLOManifest uploads sloSegments as the manifest of the static
+// large object container/path
+func (c *Connection) putSLOManifest(ctx context.Context, container string, path string, contentType string, sloSegments []swiftSegment, h Headers) error {
 	content, err := json.Marshal(sloSegments)
 	if err != nil {
 		return err
@@ -122,6 +241,8 @@ func (c *Connection) createSLOManifest(ctx context.Context, container string, pa
 
 	values := url.Values{}
 	values.Set("multipart-manifest", "put")
+	// The manifest isn't part of the object's data
+	ctx = WithProgress(ctx, nil)
 	if _, err := c.objectPut(ctx, container, path, bytes.NewBuffer(content), false, "", contentType, h, values); err != nil {
 		return err
 	}
@@ -139,33 +260,91 @@ func (file *StaticLargeObjectCreateFile) CloseWithContext(ctx context.Context) e
 
 func (file *StaticLargeObjectCreateFile) Flush(ctx context.Context) error {
 	if err := file.conn.createSLOManifest(ctx, file.container, file.objectName, file.contentType, file.segmentContainer, file.segments, file.headers); err != nil {
-		return err
+		return file.cleanupSegments(ctx, err)
 	}
 	return file.conn.waitForSegmentsToShowUp(ctx, file.container, file.objectName, file.Size())
 }
 
-func (c *Connection) getAllSLOSegments(ctx context.Context, container, path string) (string, []Object, error) {
-	var (
-		segmentList      []swiftSegment
-		segments         []Object
-		segPath          string
-		segmentContainer string
-	)
+// sloCopy copies the static large object srcContainer, srcObjectName
+// for LargeObjectCopy.  The new manifest is made from the source's so
+// each segment keeps its own container and range.
+func (c *Connection) sloCopy(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, deep bool, contentType string, h Headers) error {
+	manifest, err := c.getSLOManifest(ctx, srcContainer, srcObjectName)
+	if err != nil {
+		return err
+	}
+	var segmentPath string
+	if deep {
+		segmentPath, err = swiftSegmentPath(dstObjectName)
+		if err != nil {
+			return err
+		}
+	}
+	segmentCtx := withSegmentAccess(ctx)
+	sloSegments := make([]swiftSegment, len(manifest))
+	for i, segment := range manifest {
+		path := strings.TrimPrefix(segment.Name, "/")
+		if deep {
+			if segment.SubSLO {
+				return newErrorf(0, "can't deep copy %q as it contains the static large object %q", srcObjectName, path)
+			}
+			segmentContainer, segmentName, err := parseFullPath(path)
+			if err != nil {
+				return err
+			}
+			newName := getSegment(segmentPath, i+1)
+			_, err = c.ObjectCopy(segmentCtx, segmentContainer, segmentName, segmentContainer, newName, nil)
+			if err != nil {
+				return err
+			}
+			path = segmentContainer + "/" + newName
+		}
+		sloSegments[i] = swiftSegment{
+			Path:  path,
+			Etag:  segment.Hash,
+			Range: segment.Range,
+		}
+		if segment.Range == "" {
+			// The size is optional and checked against the
+			// whole segment, not the range
+			sloSegments[i].Size = segment.Bytes
+		}
+	}
+	return c.putSLOManifest(ctx, dstContainer, dstObjectName, contentType, sloSegments, h)
+}
 
+// getSLOManifest reads the manifest of the static large object
+// container/path as stored by Swift
+func (c *Connection) getSLOManifest(ctx context.Context, container, path string) (segmentList []swiftSegment, err error) {
 	values := url.Values{}
 	values.Set("multipart-manifest", "get")
 
 	file, _, err := c.objectOpen(ctx, container, path, true, nil, values)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
+	defer checkClose(file, &err)
 
 	content, err := io.ReadAll(file)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	err = json.Unmarshal(content, &segmentList)
+	if err != nil {
+		return nil, err
+	}
+	return segmentList, nil
+}
+
+func (c *Connection) getAllSLOSegments(ctx context.Context, container, path string) (string, []Object, error) {
+	var (
+		segments         []Object
+		segPath          string
+		segmentContainer string
+	)
+
+	segmentList, err := c.getSLOManifest(ctx, container, path)
 	if err != nil {
 		return "", nil, err
 	}
@@ -174,11 +353,20 @@ func (c *Connection) getAllSLOSegments(ctx context.Context, container, path stri
 		if err != nil {
 			return "", nil, err
 		}
-		segments = append(segments, Object{
-			Name:  segPath,
-			Bytes: segment.Bytes,
-			Hash:  segment.Hash,
-		})
+		object := Object{
+			Name:               segPath,
+			Bytes:              segment.Bytes,
+			Hash:               segment.Hash,
+			ContentType:        segment.ContentType,
+			ServerLastModified: segment.LastModified,
+		}
+		if object.ServerLastModified != "" {
+			object.LastModified, err = parseServerLastModified(object.ServerLastModified)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		segments = append(segments, object)
 	}
 
 	return segmentContainer, segments, nil
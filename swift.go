@@ -3,24 +3,35 @@ package swift
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -35,7 +46,7 @@ const (
 )
 
 // ObjectType is the type of the swift object, regular, static large,
-// or dynamic large.
+// dynamic large or symlink.
 type ObjectType int
 
 // Values that ObjectType can take
@@ -43,6 +54,7 @@ const (
 	RegularObjectType ObjectType = iota
 	StaticLargeObjectType
 	DynamicLargeObjectType
+	SymlinkObjectType
 )
 
 // Connection holds the details of the connection to the swift server.
@@ -92,6 +104,37 @@ const (
 // http.ProxyFromEnvironment (http://golang.org/pkg/net/http/#ProxyFromEnvironment).
 // This means that the connection will respect the HTTP proxy specified by the
 // environment variables $HTTP_PROXY and $NO_PROXY.
+//
+// If you need more control, eg a cookie jar or a test double, supply a
+// complete HTTPClient instead.  This takes precedence over Transport,
+// which is then only used to cancel requests and flush idle connections
+// and defaults to the Transport of the HTTPClient.  ConnectTimeout and
+// Timeout still apply as well as any Timeout set in the HTTPClient.
+//
+// ConnectTimeout bounds the time from starting a request to receiving
+// the response headers.  The Transport made by default also uses it
+// to limit dialing and the TLS handshake.  Timeout is an idle
+// timeout rather than a limit on the whole request: each chunk of an
+// upload (see WatchdogChunkSize) and each read of a download must
+// complete within it, and it bounds the wait for the response headers
+// once an upload has been sent.  When a timeout fires the request is
+// cancelled and TimeoutError returned.  To limit the total time a
+// request may take pass a context with a deadline.
+//
+// A Connection may be used by many goroutines at once.  StorageUrl,
+// AuthToken, Expires and Auth are guarded by an internal lock as they
+// are updated when re-authenticating, so once the Connection is in
+// use read them with GetStorageUrl and Authenticated, and change them
+// with UnAuthenticate or Authenticate rather than directly.  The other
+// parameters are read without locking so they must not be changed
+// after the first call.
+//
+// DefaultHeaders are added to every storage request, eg for tracing
+// or a Via header.  Headers passed to an individual call take
+// precedence over them whatever the case of their names.
+// X-Auth-Token, User-Agent and Content-Length are always set by the
+// library so are ignored if present, and DefaultHeaders aren't sent
+// to the auth server.
 type Connection struct {
 	// Parameters - fill these in before calling Authenticate
 	// They are all optional except UserName, ApiKey and AuthUrl
@@ -106,8 +149,9 @@ type Connection struct {
 	AuthUrl                     string            // Auth URL
 	Retries                     int               // Retries on error (default is 3)
 	UserAgent                   string            // Http User agent (default goswift/1.0)
-	ConnectTimeout              time.Duration     // Connect channel timeout (default 10s)
-	Timeout                     time.Duration     // Data channel timeout (default 60s)
+	ConnectTimeout              time.Duration     // Connect and response header timeout (default 10s)
+	Timeout                     time.Duration     // Data channel idle timeout (default 60s)
+	WatchdogChunkSize           int               // Uploads are read in chunks of at most this many bytes each of which must arrive within Timeout (default 1MiB)
 	Region                      string            // Region to use eg "LON", "ORD" - default is use first region (v2,v3 auth only)
 	AuthVersion                 int               // Set to 1, 2 or 3 or leave at 0 for autodetect
 	Internal                    bool              // Set this to true to use the the internal / service network
@@ -117,7 +161,11 @@ type Connection struct {
 	TenantDomain                string            // Name of the tenant's domain (v3 auth only), only needed if it differs from the user domain
 	TenantDomainId              string            // Id of the tenant's domain (v3 auth only), only needed if it differs the from user domain
 	TrustId                     string            // Id of the trust (v3 auth only)
+	InfoUrl                     string            // URL of the /info endpoint - default is derived from StorageUrl
+	InfoCacheTTL                time.Duration     // How long the result of QueryInfo is used for (default 1h, negative for ever)
 	Transport                   http.RoundTripper `json:"-" xml:"-"` // Optional specialised http.Transport (eg. for Google Appengine)
+	HTTPClient                  *http.Client      `json:"-" xml:"-"` // Optional http.Client to use instead of one made from Transport
+	DefaultHeaders              Headers           `xml:"-"`          // Headers sent with every storage request unless the request sets them itself
 	// These are filled in after Authenticate is called as are the defaults for above
 	StorageUrl string
 	AuthToken  string
@@ -125,11 +173,40 @@ type Connection struct {
 	client     *http.Client
 	Auth       Authenticator `json:"-" xml:"-"` // the current authenticator
 	authLock   sync.Mutex    // lock when R/W StorageUrl, AuthToken, Auth
-	// swiftInfo is filled after QueryInfo is called
-	swiftInfo SwiftInfo
+	// swiftInfo is filled after QueryInfo is called at swiftInfoTime
+	swiftInfo     SwiftInfo
+	swiftInfoTime time.Time
 	// Workarounds for non-compliant servers that don't always return opts.Limit items per page
 	FetchUntilEmptyPage       bool // Always fetch unless we received an empty page
 	PartialPageFetchThreshold int  // Fetch if the current page is this percentage of opts.Limit
+	// Safety checks
+	ProtectSegmentContainers bool // Refuse object operations directly on "*_segments" containers
+	// Retry a failed authentication for all auth versions - by default
+	// only v2 and custom authentication are retried as v1 and v3 would
+	// send the same credentials again which can lock accounts out
+	ReauthOn401 bool
+	// Retry requests which fail with 429 Too Many Requests, 503
+	// Service Unavailable or a connection error.  The wait before
+	// retrying a 429 or 503 is taken from Retry-After if set,
+	// otherwise it doubles each time up to a limit.  Requests with a
+	// body are only retried if it is an io.Seeker, eg *os.File or
+	// *bytes.Reader, so it can be rewound - otherwise the error is
+	// returned wrapped in a *BodyNotRewindableError.
+	RetryTransientErrors bool
+	// Optional budget shared by all retries - see RetryBudget
+	RetryBudget *RetryBudget `json:"-" xml:"-"`
+	// If set this is called to log each HTTP request with its
+	// method, URL, headers, status and how long it took.  The auth
+	// token and other secrets are redacted.
+	Logger func(format string, args ...interface{}) `json:"-" xml:"-"`
+}
+
+// protectedHeaders are set by Call itself so can't be supplied by
+// Connection.DefaultHeaders
+var protectedHeaders = map[string]bool{
+	"Content-Length": true,
+	"User-Agent":     true,
+	"X-Auth-Token":   true,
 }
 
 // setFromEnv reads the value that param points to (it must be a
@@ -231,6 +308,8 @@ func setFromEnv(param interface{}, name string) (err error) {
 //	GOSWIFT_CONNECT_TIMEOUT - Connect channel timeout with unit, eg "10s", "100ms" (default "10s")
 //	GOSWIFT_TIMEOUT - Data channel timeout with unit, eg "10s", "100ms" (default "60s")
 //	GOSWIFT_INTERNAL - Set this to "true" to use the the internal network (obsolete - use OS_ENDPOINT_TYPE)
+//	GOSWIFT_INFO_URL - URL of the /info endpoint (default is derived from the storage URL)
+//	GOSWIFT_INFO_CACHE_TTL - How long QueryInfo results are used for with unit, eg "10m" (default "1h", negative for ever)
 func (c *Connection) ApplyEnvironment() (err error) {
 	for _, item := range []struct {
 		result interface{}
@@ -260,6 +339,8 @@ func (c *Connection) ApplyEnvironment() (err error) {
 		{&c.TenantDomain, "OS_PROJECT_DOMAIN_NAME"},
 		{&c.TenantDomainId, "OS_PROJECT_DOMAIN_ID"},
 		{&c.TrustId, "OS_TRUST_ID"},
+		{&c.InfoUrl, "GOSWIFT_INFO_URL"},
+		{&c.InfoCacheTTL, "GOSWIFT_INFO_CACHE_TTL"},
 		{&c.StorageUrl, "OS_STORAGE_URL"},
 		{&c.AuthToken, "OS_AUTH_TOKEN"},
 		// v1 auth alternatives
@@ -287,6 +368,23 @@ func (e *Error) Error() string {
 	return e.Text
 }
 
+// BodyNotRewindableError is returned by requests which failed with an
+// error that would have been retried, but weren't as their body
+// couldn't be rewound to send again.  Use errors.As to find the
+// *Error with the status in Err.
+type BodyNotRewindableError struct {
+	Err error // The error which wasn't retried
+}
+
+func (e *BodyNotRewindableError) Error() string {
+	return fmt.Sprintf("%v: not retried as the request body can't be rewound", e.Err)
+}
+
+// Unwrap returns the error which wasn't retried
+func (e *BodyNotRewindableError) Unwrap() error {
+	return e.Err
+}
+
 // newError make a new error from a string.
 func newError(StatusCode int, Text string) *Error {
 	return &Error{
@@ -305,18 +403,22 @@ type errorMap map[int]error
 
 var (
 	// Specific Errors you might want to check for equality
-	NotModified         = newError(304, "Not Modified")
-	BadRequest          = newError(400, "Bad Request")
-	AuthorizationFailed = newError(401, "Authorization Failed")
-	ContainerNotFound   = newError(404, "Container Not Found")
-	ContainerNotEmpty   = newError(409, "Container Not Empty")
-	ObjectNotFound      = newError(404, "Object Not Found")
-	ObjectCorrupted     = newError(422, "Object Corrupted")
-	TimeoutError        = newError(408, "Timeout when reading or writing data")
-	Forbidden           = newError(403, "Operation forbidden")
-	TooLargeObject      = newError(413, "Too Large Object")
-	RateLimit           = newError(498, "Rate Limit")
-	TooManyRequests     = newError(429, "TooManyRequests")
+	NotModified            = newError(304, "Not Modified")
+	BadRequest             = newError(400, "Bad Request")
+	AuthorizationFailed    = newError(401, "Authorization Failed")
+	ContainerNotFound      = newError(404, "Container Not Found")
+	ContainerNotEmpty      = newError(409, "Container Not Empty")
+	ObjectNotFound         = newError(404, "Object Not Found")
+	ObjectCorrupted        = newError(422, "Object Corrupted")
+	TimeoutError           = newError(408, "Timeout when reading or writing data")
+	Forbidden              = newError(403, "Operation forbidden")
+	TooLargeObject         = newError(413, "Too Large Object")
+	PreconditionFailed     = newError(412, "Precondition Failed")
+	RateLimit              = newError(498, "Rate Limit")
+	TooManyRequests        = newError(429, "TooManyRequests")
+	NotSymlink             = newError(0, "Not a symlink")
+	ObjectCopyMismatch     = newError(0, "Copied object doesn't match source")
+	VersioningNotSupported = newError(0, "Object versioning not supported by server")
 
 	// Mappings for authentication errors
 	authErrorMap = errorMap{
@@ -335,11 +437,12 @@ var (
 	}
 
 	// Mappings for object errors
-	objectErrorMap = errorMap{
+	ObjectErrorMap = errorMap{
 		304: NotModified,
 		400: BadRequest,
 		403: Forbidden,
 		404: ObjectNotFound,
+		412: PreconditionFailed,
 		413: TooLargeObject,
 		422: ObjectCorrupted,
 		429: TooManyRequests,
@@ -387,6 +490,25 @@ func (c *Connection) parseHeaders(resp *http.Response, errorMap errorMap) error
 	return nil
 }
 
+// trimEtag removes the weak marker and double quotes from an ETag
+// leaving the bare hash.
+//
+// ETag header may be double quoted if following RFC 7232
+// https://github.com/openstack/swift/blob/2.24.0/CHANGELOG#L9 and
+// some clusters send the ETag of large objects as a weak ETag, eg
+// W/"d41d8cd98f00b204e9800998ecf8427e"
+func trimEtag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, "\"")
+}
+
+// etagMatches returns true if the ETag received from the server
+// matches the hash we calculated
+func etagMatches(etag string, calculated string) bool {
+	return strings.EqualFold(trimEtag(etag), calculated)
+}
+
 // readHeaders returns a Headers object from the http.Response.
 //
 // If it receives multiple values for a key (which should never
@@ -394,10 +516,8 @@ func (c *Connection) parseHeaders(resp *http.Response, errorMap errorMap) error
 func readHeaders(resp *http.Response) Headers {
 	headers := Headers{}
 	for key, values := range resp.Header {
-		// ETag header may be double quoted if following RFC 7232
-		// https://github.com/openstack/swift/blob/2.24.0/CHANGELOG#L9
 		if key == "Etag" {
-			headers[key] = strings.Trim(values[0], "\"")
+			headers[key] = trimEtag(values[0])
 		} else {
 			headers[key] = values[0]
 		}
@@ -408,8 +528,35 @@ func readHeaders(resp *http.Response) Headers {
 // Headers stores HTTP headers (can only have one of each header like Swift).
 type Headers map[string]string
 
+// cancelBody is a response body which cancels the context of its
+// request when it is closed or aborted
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close the body and release the request's context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // Does an http request using the running timer passed in
-func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (*http.Response, error) {
+//
+// The timer bounds the time until the response headers arrive,
+// including dialing and the TLS handshake. If it fires the request's
+// context is cancelled which aborts the request whatever stage it has
+// reached, even with a Transport which doesn't support CancelRequest.
+func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (resp *http.Response, err error) {
+	if c.Logger != nil {
+		start := time.Now()
+		defer func() {
+			c.logRequest(req, resp, err, time.Since(start))
+		}()
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	outReq := req.WithContext(ctx)
 	// Do the request in the background so we can check the timeout
 	type result struct {
 		resp *http.Response
@@ -417,20 +564,65 @@ func (c *Connection) doTimeoutRequest(timer *time.Timer, req *http.Request) (*ht
 	}
 	done := make(chan result, 1)
 	go func() {
-		resp, err := c.client.Do(req)
+		resp, err := c.client.Do(outReq)
 		done <- result{resp, err}
 	}()
 	// Wait for the read or the timeout
 	select {
 	case r := <-done:
-		return r.resp, r.err
+		if r.err != nil {
+			cancel()
+			return nil, r.err
+		}
+		r.resp.Body = &cancelBody{ReadCloser: r.resp.Body, cancel: cancel}
+		return r.resp, nil
 	case <-timer.C:
 		// Kill the connection on timeout so we don't leak sockets or goroutines
-		cancelRequest(c.Transport, req)
+		cancelRequest(c.Transport, outReq)
+		cancel()
 		return nil, TimeoutError
 	}
 }
 
+// redactedHeaders are the request headers which aren't logged
+var redactedHeaders = []string{"X-Auth-Token", "X-Auth-Key", "X-Storage-Pass", "Authorization"}
+
+// isRedactedHeader returns true if the value of the request header key
+// shouldn't be logged.  As well as redactedHeaders this includes the
+// temp URL keys of accounts and containers, eg
+// X-Account-Meta-Temp-Url-Key-2.
+func isRedactedHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for _, redacted := range redactedHeaders {
+		if key == redacted {
+			return true
+		}
+	}
+	return strings.Contains(key, "-Meta-Temp-Url-Key")
+}
+
+// logRequest logs req and its result with c.Logger
+func (c *Connection) logRequest(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	u := *req.URL
+	if query := u.Query(); query.Get("temp_url_sig") != "" {
+		query.Set("temp_url_sig", "XXXX")
+		u.RawQuery = query.Encode()
+	}
+	headers := req.Header.Clone()
+	for key := range headers {
+		if isRedactedHeader(key) {
+			headers.Set(key, "XXXX")
+		}
+	}
+	status := ""
+	if err != nil {
+		status = "error: " + err.Error()
+	} else {
+		status = resp.Status
+	}
+	c.Logger("swift: %s %s %v: %s (%v)", req.Method, u.String(), headers, status, elapsed)
+}
+
 // Set defaults for any unset values
 //
 // Call with authLock held
@@ -447,11 +639,27 @@ func (c *Connection) setDefaults() {
 	if c.Timeout == 0 {
 		c.Timeout = 60 * time.Second
 	}
+	if c.WatchdogChunkSize <= 0 {
+		c.WatchdogChunkSize = watchdogChunkSize
+	}
+	if c.HTTPClient != nil {
+		if c.Transport == nil {
+			c.Transport = c.HTTPClient.Transport
+		}
+		if c.client == nil {
+			c.client = c.HTTPClient
+		}
+	}
 	if c.Transport == nil {
 		t := &http.Transport{
 			//		TLSClientConfig:    &tls.Config{RootCAs: pool},
 			//		DisableCompression: true,
 			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   c.ConnectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: c.ConnectTimeout,
 			// Half of linux's default open files limit (1024).
 			MaxIdleConnsPerHost: 512,
 		}
@@ -518,7 +726,7 @@ again:
 			// Try again for a limited number of times on
 			// AuthorizationFailed or BadRequest. This allows us
 			// to try some alternate forms of the request
-			if (err == AuthorizationFailed || err == BadRequest) && retries > 0 {
+			if (err == AuthorizationFailed || err == BadRequest) && retries > 0 && c.authRetryHelps() && c.retryAllowed() {
 				retries--
 				goto again
 			}
@@ -548,9 +756,49 @@ again:
 	return
 }
 
+// authRetryHelps returns whether retrying a failed authentication
+// request could succeed.
+//
+// v2 auth alternates between password and API key authentication but
+// v1 and v3 would just send the same credentials again.
+func (c *Connection) authRetryHelps() bool {
+	if c.ReauthOn401 {
+		return true
+	}
+	switch c.Auth.(type) {
+	case *v1Auth, *v3Auth:
+		return false
+	}
+	return true
+}
+
+// EffectiveAuthVersion returns the auth version (1, 2 or 3) used to
+// authenticate, which is the one detected from the AuthUrl if
+// AuthVersion is 0.
+//
+// It returns 0 before the Connection has authenticated or if a
+// custom Authenticator is in use.
+func (c *Connection) EffectiveAuthVersion() int {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	switch c.Auth.(type) {
+	case *v1Auth:
+		return 1
+	case *v2Auth:
+		return 2
+	case *v3Auth:
+		return 3
+	}
+	return 0
+}
+
 // Get an authToken and url
 //
-// The Url may be updated if it needed to authenticate using the OnReAuth function
+// The Url may be updated if it needed to authenticate using the OnReAuth function.
+//
+// authLock is held while authenticating so if many requests need a
+// new token at once only the first authenticates and the rest wait
+// for it and use the token it fetched.
 func (c *Connection) getUrlAndAuthToken(ctx context.Context, targetUrlIn string, OnReAuth func() (string, error)) (targetUrlOut, authToken string, err error) {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
@@ -588,6 +836,20 @@ func (c *Connection) UnAuthenticate() {
 	c.authLock.Unlock()
 }
 
+// unAuthenticateToken removes the authentication from the Connection
+// if it is still using authToken.
+//
+// This stops a request which failed with an old token throwing away a
+// new one another request has just fetched.
+func (c *Connection) unAuthenticateToken(authToken string) {
+	c.authLock.Lock()
+	if c.AuthToken == authToken {
+		c.StorageUrl = ""
+		c.AuthToken = ""
+	}
+	c.authLock.Unlock()
+}
+
 // Authenticated returns a boolean to show if the current connection
 // is authenticated.
 //
@@ -627,6 +889,11 @@ func (i SwiftInfo) SupportsSLO() bool {
 	return val
 }
 
+func (i SwiftInfo) SupportsObjectVersioning() bool {
+	_, val := i["object_versioning"]
+	return val
+}
+
 func (i SwiftInfo) SLOMinSegmentSize() int64 {
 	if slo, ok := i["slo"].(map[string]interface{}); ok {
 		val, _ := slo["min_segment_size"].(float64)
@@ -635,22 +902,173 @@ func (i SwiftInfo) SLOMinSegmentSize() int64 {
 	return 1
 }
 
-// Discover Swift configuration by doing a request against /info
-func (c *Connection) QueryInfo(ctx context.Context) (infos SwiftInfo, err error) {
-	storageUrl, err := c.GetStorageUrl(ctx)
-	if err != nil {
-		return nil, err
+// SupportsSymlinks returns whether the symlink middleware is enabled.
+func (i SwiftInfo) SupportsSymlinks() bool {
+	_, val := i["symlink"]
+	return val
+}
+
+// SupportsStaticSymlinks returns whether symlinks pinned to an Etag
+// are supported.
+func (i SwiftInfo) SupportsStaticSymlinks() bool {
+	symlink, _ := i["symlink"].(map[string]interface{})
+	val, _ := symlink["static_links"].(bool)
+	return val
+}
+
+// SupportsVersionedWrites returns whether the legacy versioned_writes
+// middleware (X-Versions-Location and X-History-Location) is enabled.
+func (i SwiftInfo) SupportsVersionedWrites() bool {
+	_, val := i["versioned_writes"]
+	return val
+}
+
+// intValue returns the number key in section of the info or 0 if it
+// isn't there
+func (i SwiftInfo) intValue(section string, key string) int64 {
+	values, _ := i[section].(map[string]interface{})
+	val, _ := values[key].(float64)
+	return int64(val)
+}
+
+// MaxFileSize returns the largest object which can be uploaded in one
+// go, or 0 if unknown.
+func (i SwiftInfo) MaxFileSize() int64 {
+	return i.intValue("swift", "max_file_size")
+}
+
+// MaxContainerNameLength returns the maximum length of a container
+// name, or 0 if unknown.
+func (i SwiftInfo) MaxContainerNameLength() int {
+	return int(i.intValue("swift", "max_container_name_length"))
+}
+
+// MaxObjectNameLength returns the maximum length of an object name, or
+// 0 if unknown.
+func (i SwiftInfo) MaxObjectNameLength() int {
+	return int(i.intValue("swift", "max_object_name_length"))
+}
+
+// ContainerListingLimit returns the maximum number of objects returned
+// in one page of a container listing, or 0 if unknown.
+func (i SwiftInfo) ContainerListingLimit() int {
+	return int(i.intValue("swift", "container_listing_limit"))
+}
+
+// AccountListingLimit returns the maximum number of containers
+// returned in one page of an account listing, or 0 if unknown.
+func (i SwiftInfo) AccountListingLimit() int {
+	return int(i.intValue("swift", "account_listing_limit"))
+}
+
+// defaultMaxDeletesPerRequest is Swift's default limit on the number
+// of objects in a bulk delete
+const defaultMaxDeletesPerRequest = 10000
+
+// BulkDeleteMaxDeletesPerRequest returns the maximum number of objects
+// the server accepts in one bulk delete, or Swift's default of 10000
+// if it doesn't say.
+func (i SwiftInfo) BulkDeleteMaxDeletesPerRequest() int {
+	if bulkDelete, ok := i["bulk_delete"].(map[string]interface{}); ok {
+		if val, ok := bulkDelete["max_deletes_per_request"].(float64); ok && val >= 1 {
+			return int(val)
+		}
+	}
+	return defaultMaxDeletesPerRequest
+}
+
+// StoragePolicy describes one of the storage policies of the cluster
+// as returned by SwiftInfo.StoragePolicies.
+type StoragePolicy struct {
+	Name    string   // name of the policy, eg "gold"
+	Aliases []string // other names the policy can be given by, including Name
+	Default bool     // set if containers are made with this policy unless told otherwise
+}
+
+// StoragePolicies returns the storage policies the cluster has from
+// "swift.policies" or nil if it doesn't say.
+//
+// Any of these may be passed to ContainerCreateWithPolicy.
+func (i SwiftInfo) StoragePolicies() (policies []StoragePolicy) {
+	swift, _ := i["swift"].(map[string]interface{})
+	items, _ := swift["policies"].([]interface{})
+	for _, item := range items {
+		values, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var policy StoragePolicy
+		policy.Name, _ = values["name"].(string)
+		policy.Default, _ = values["default"].(bool)
+		aliases, _ := values["aliases"].(string)
+		for _, alias := range strings.Split(aliases, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				policy.Aliases = append(policy.Aliases, alias)
+			}
+		}
+		policies = append(policies, policy)
 	}
-	infoUrl, err := url.Parse(storageUrl)
+	return policies
+}
+
+// versionSegment matches the API version in a storage URL, eg "v1"
+var versionSegment = regexp.MustCompile(`^v\d+(\.\d+)?$`)
+
+// infoUrlFromStorageUrl works out the URL of the /info endpoint from
+// the storage URL.
+//
+// The info endpoint is found where the API version is, so
+// https://host/v1/AUTH_account and https://host/swift/v1 have their
+// info at https://host/info and https://host/swift/info.  If there is
+// no version in the last two path segments they are both removed.
+func infoUrlFromStorageUrl(storageUrl string) (string, error) {
+	u, err := url.Parse(storageUrl)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	base := len(segments) - 2
+	for i := len(segments) - 1; i >= 0 && i >= len(segments)-2; i-- {
+		if versionSegment.MatchString(segments[i]) {
+			base = i
+			break
+		}
+	}
+	if base < 0 {
+		base = 0
+	}
+	u.Path = path.Join(append([]string{"/"}, append(segments[:base], "info")...)...)
+	u.RawPath = ""
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// Discover Swift configuration by doing a request against /info
+//
+// The URL used is InfoUrl if set, otherwise it is derived from the
+// storage URL.
+func (c *Connection) QueryInfo(ctx context.Context) (infos SwiftInfo, err error) {
+	infoUrl := c.InfoUrl
+	if infoUrl == "" {
+		storageUrl, err := c.GetStorageUrl(ctx)
+		if err != nil {
+			return nil, err
+		}
+		infoUrl, err = infoUrlFromStorageUrl(storageUrl)
+		if err != nil {
+			return nil, err
+		}
 	}
-	infoUrl.Path = path.Join(infoUrl.Path, "..", "..", "info")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoUrl.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoUrl, nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	start := time.Now()
 	resp, err := c.client.Do(req)
+	if c.Logger != nil {
+		c.logRequest(req, resp, err, time.Since(start))
+	}
 	if err == nil {
 		if resp.StatusCode != http.StatusOK {
 			drainAndClose(resp.Body, nil)
@@ -658,20 +1076,29 @@ func (c *Connection) QueryInfo(ctx context.Context) (infos SwiftInfo, err error)
 		}
 		err = readJson(resp, &infos)
 		if err == nil {
-			c.authLock.Lock()
-			c.swiftInfo = infos
-			c.authLock.Unlock()
+			c.SetCachedInfo(infos, time.Now())
 		}
 		return infos, err
 	}
 	return nil, err
 }
 
+// defaultInfoCacheTTL is how long the result of QueryInfo is used for
+// if InfoCacheTTL isn't set
+const defaultInfoCacheTTL = time.Hour
+
+// cachedQueryInfo returns the result of the last QueryInfo if it is
+// younger than InfoCacheTTL, otherwise it calls QueryInfo again.
+//
+// Failures aren't cached so a transient failure doesn't disable
+// features gated on the info for long.
 func (c *Connection) cachedQueryInfo(ctx context.Context) (infos SwiftInfo, err error) {
-	c.authLock.Lock()
-	infos = c.swiftInfo
-	c.authLock.Unlock()
-	if infos == nil {
+	ttl := c.InfoCacheTTL
+	if ttl == 0 {
+		ttl = defaultInfoCacheTTL
+	}
+	infos, fetched := c.CachedInfo()
+	if infos == nil || (ttl > 0 && time.Since(fetched) >= ttl) {
 		infos, err = c.QueryInfo(ctx)
 		if err != nil {
 			return
@@ -680,6 +1107,33 @@ func (c *Connection) cachedQueryInfo(ctx context.Context) (infos SwiftInfo, err
 	return infos, nil
 }
 
+// InvalidateInfo forgets the cached result of QueryInfo so it is read
+// again the next time it is needed, eg after the cluster has been
+// reconfigured.
+func (c *Connection) InvalidateInfo() {
+	c.SetCachedInfo(nil, time.Time{})
+}
+
+// CachedInfo returns the cached result of QueryInfo and when it was
+// fetched, or nil if there isn't one.
+//
+// The info isn't serialized with the Connection so use this and
+// SetCachedInfo to save and restore it too if wanted.
+func (c *Connection) CachedInfo() (infos SwiftInfo, fetched time.Time) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	return c.swiftInfo, c.swiftInfoTime
+}
+
+// SetCachedInfo sets the cached result of QueryInfo as if it had been
+// fetched at the time given.
+func (c *Connection) SetCachedInfo(infos SwiftInfo, fetched time.Time) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	c.swiftInfo = infos
+	c.swiftInfoTime = fetched
+}
+
 // RequestOpts contains parameters for Connection.storage.
 type RequestOpts struct {
 	Container  string
@@ -723,7 +1177,26 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 	if retries == 0 {
 		retries = c.Retries
 	}
+	// Note where the body starts so it can be rewound for a retry
+	bodyStart := int64(-1)
+	if seeker, ok := p.Body.(io.Seeker); ok {
+		if pos, seekErr := seeker.Seek(0, io.SeekCurrent); seekErr == nil {
+			bodyStart = pos
+		}
+	}
+	rewind := func() bool {
+		if p.Body == nil {
+			return true
+		}
+		if bodyStart < 0 {
+			return false
+		}
+		_, seekErr := p.Body.(io.Seeker).Seek(bodyStart, io.SeekStart)
+		return seekErr == nil
+	}
 	var req *http.Request
+	transientRetries := 0
+	notRewindable := false
 	for {
 		var authToken string
 		if targetUrl, authToken, err = c.getUrlAndAuthToken(ctx, targetUrl, p.OnReAuth); err != nil {
@@ -747,12 +1220,26 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 		defer timer.Stop()
 		reader := p.Body
 		if reader != nil {
-			reader = newWatchdogReader(reader, c.Timeout, timer)
+			reader = newWatchdogReader(reader, c.Timeout, timer, c.WatchdogChunkSize)
 		}
 		req, err = http.NewRequestWithContext(ctx, p.Operation, URL.String(), reader)
 		if err != nil {
 			return
 		}
+		if len(c.DefaultHeaders) > 0 {
+			// Header names are case insensitive so compare them canonicalised
+			overridden := make(map[string]bool, len(p.Headers))
+			for k := range p.Headers {
+				overridden[http.CanonicalHeaderKey(k)] = true
+			}
+			for k, v := range c.DefaultHeaders {
+				k = http.CanonicalHeaderKey(k)
+				if overridden[k] || protectedHeaders[k] {
+					continue
+				}
+				req.Header.Add(k, v)
+			}
+		}
 		if p.Headers != nil {
 			for k, v := range p.Headers {
 				// Set ContentLength in req if the user passed it in in the headers
@@ -775,28 +1262,48 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 
 		resp, err = c.doTimeoutRequest(timer, req)
 		if err != nil {
-			if (p.Operation == "HEAD" || p.Operation == "GET") && retries > 0 {
-				retries--
-				continue
+			if (p.Operation == "HEAD" || p.Operation == "GET" || c.RetryTransientErrors) && retries > 0 {
+				if !rewind() {
+					err = &BodyNotRewindableError{Err: err}
+					return
+				}
+				if c.retryAllowed() {
+					retries--
+					continue
+				}
 			}
 			return
 		}
 		// Check to see if token has expired
-		if resp.StatusCode == 401 && retries > 0 {
+		if resp.StatusCode == 401 && retries > 0 && c.retryAllowed() {
 			drainAndClose(resp.Body, nil)
-			c.UnAuthenticate()
+			c.unAuthenticateToken(authToken)
 			retries--
 			err = AuthorizationFailed
-
-			// Attempt to rewind the body
-			if p.Body != nil {
-				if do, ok := p.Body.(io.Seeker); ok {
-					if _, seekErr := do.Seek(0, io.SeekStart); seekErr != nil {
-						return
-					}
-				} else {
-					return
-				}
+			if !rewind() {
+				return
+			}
+		} else if c.RetryTransientErrors && (resp.StatusCode == 429 || resp.StatusCode == 503) && retries > 0 {
+			if !rewind() {
+				notRewindable = true
+				break
+			}
+			if !c.retryAllowed() {
+				break
+			}
+			drainAndClose(resp.Body, nil)
+			retries--
+			wait := retryAfter(resp, time.Now())
+			if wait <= 0 {
+				wait = retryBackoff(transientRetries)
+			}
+			transientRetries++
+			sleep := time.NewTimer(wait)
+			select {
+			case <-sleep.C:
+			case <-ctx.Done():
+				sleep.Stop()
+				return nil, nil, ctx.Err()
 			}
 		} else {
 			break
@@ -805,6 +1312,9 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 
 	headers = readHeaders(resp)
 	if err = c.parseHeaders(resp, p.ErrorMap); err != nil {
+		if notRewindable {
+			err = &BodyNotRewindableError{Err: err}
+		}
 		return
 	}
 	if p.NoResponse {
@@ -817,12 +1327,59 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 		cancel := func() {
 			cancelRequest(c.Transport, req)
 		}
+		if body, ok := resp.Body.(*cancelBody); ok {
+			cancel = body.cancel
+		}
 		// Wrap resp.Body to make it obey an idle timeout
-		resp.Body = newTimeoutReader(resp.Body, c.Timeout, cancel)
+		resp.Body = newTimeoutReader(ctx, resp.Body, c.Timeout, cancel)
 	}
 	return
 }
 
+// Limits of the wait before retrying a 429 or 503 without Retry-After
+const (
+	minRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff = 10 * time.Second
+)
+
+// retryBackoff returns how long to wait before the retry after
+// previous retries of a 429 or 503, doubling from minRetryBackoff up
+// to maxRetryBackoff.
+func retryBackoff(previous int) time.Duration {
+	wait := minRetryBackoff
+	for i := 0; i < previous && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	return wait
+}
+
+// retryAfter returns how long the server asked us to wait before
+// retrying from the Retry-After header, which is either a number of
+// seconds or an HTTP date, or 0 if it didn't.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
 // storage runs a remote command on a the storage url, returns a
 // response, headers and possible error.
 //
@@ -837,6 +1394,9 @@ func (c *Connection) Call(ctx context.Context, targetUrl string, p RequestOpts)
 // This will Authenticate if necessary, and re-authenticate if it
 // receives a 401 error which means the token has expired
 func (c *Connection) storage(ctx context.Context, p RequestOpts) (resp *http.Response, headers Headers, err error) {
+	if err = c.checkSegmentContainer(ctx, p.Container, p.ObjectName); err != nil {
+		return
+	}
 	p.OnReAuth = func() (string, error) {
 		return c.StorageUrl, nil
 	}
@@ -846,6 +1406,41 @@ func (c *Connection) storage(ctx context.Context, p RequestOpts) (resp *http.Res
 	return c.Call(ctx, url, p)
 }
 
+// accountStorageUrl returns the storage URL of account on the same
+// cluster as storageUrl by replacing its last path element, eg
+// "https://host/v1/AUTH_a" becomes "https://host/v1/AUTH_b".
+func accountStorageUrl(storageUrl string, account string) (string, error) {
+	if account == "" || strings.Contains(account, "/") {
+		return "", newErrorf(0, "bad account %q", account)
+	}
+	u, err := url.Parse(storageUrl)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Dir(strings.TrimRight(u.Path, "/")) + "/" + account
+	return u.String(), nil
+}
+
+// accountStorage runs a remote command like storage but on account
+// rather than the account of the Connection.  The token of the
+// Connection is used so the user must have access to account.
+func (c *Connection) accountStorage(ctx context.Context, account string, p RequestOpts) (resp *http.Response, headers Headers, err error) {
+	p.OnReAuth = func() (string, error) {
+		return accountStorageUrl(c.StorageUrl, account)
+	}
+	c.authLock.Lock()
+	targetUrl := c.StorageUrl
+	c.authLock.Unlock()
+	if targetUrl != "" {
+		// Otherwise Call authenticates and uses OnReAuth
+		targetUrl, err = accountStorageUrl(targetUrl, account)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return c.Call(ctx, targetUrl, p)
+}
+
 // readLines reads the response into an array of strings.
 //
 // Closes the response when done
@@ -880,6 +1475,33 @@ func readJson(resp *http.Response, result interface{}) (err error) {
 	return decoder.Decode(result)
 }
 
+// readJsonArray reads a JSON array from the response calling fn to
+// decode each element in turn, so the whole array needn't be held in
+// memory.
+//
+// Closes the response when done
+func readJsonArray(resp *http.Response, fn func(*json.Decoder) error) (err error) {
+	defer drainAndClose(resp.Body, &err)
+	decoder := json.NewDecoder(resp.Body)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil // null is an empty array as far as Decode is concerned
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expecting JSON array but got %v", token)
+	}
+	for decoder.More() {
+		if err = fn(decoder); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
 /* ------------------------------------------------------------ */
 
 // ContainersOpts is options for Containers() and ContainerNames()
@@ -888,6 +1510,8 @@ type ContainersOpts struct {
 	Prefix    string  // Given a string value x, return container names matching the specified prefix.
 	Marker    string  // Given a string value x, return container names greater in value than the specified marker.
 	EndMarker string  // Given a string value x, return container names less in value than the specified marker.
+	Delimiter rune    // For a character c, roll up container names containing c after the Prefix into a single SubDir
+	Reverse   bool    // Return container names in reverse order - Marker and EndMarker are then upper and lower bounds.
 	Headers   Headers // Any additional HTTP headers - can be nil
 }
 
@@ -908,6 +1532,12 @@ func (opts *ContainersOpts) parse() (url.Values, Headers) {
 		if opts.EndMarker != "" {
 			v.Set("end_marker", opts.EndMarker)
 		}
+		if opts.Delimiter != 0 {
+			v.Set("delimiter", string(opts.Delimiter))
+		}
+		if opts.Reverse {
+			v.Set("reverse", "true")
+		}
 		h = opts.Headers
 	}
 	return v, h
@@ -931,15 +1561,24 @@ func (c *Connection) ContainerNames(ctx context.Context, opts *ContainersOpts) (
 
 // Container contains information about a container
 type Container struct {
-	Name       string // Name of the container
-	Count      int64  // Number of objects in the container
-	Bytes      int64  // Total number of bytes used in the container
-	QuotaCount int64  // Maximum object count of the container. 0 if not available
-	QuotaBytes int64  // Maximum size of the container, in bytes. 0 if not available
+	Name               string    // Name of the container
+	Count              int64     // Number of objects in the container
+	Bytes              int64     // Total number of bytes used in the container
+	QuotaCount         int64     // Maximum object count of the container. 0 if not available
+	QuotaBytes         int64     // Maximum size of the container, in bytes. 0 if not available
+	ServerLastModified string    `json:"last_modified"`  // Last modified time as a string supplied by the server in listings, if available
+	LastModified       time.Time `json:"-"`              // Last modified time converted to a time.Time, zero if not available
+	StoragePolicy      string    `json:"storage_policy"` // Name of the storage policy of the container, if available
+	PseudoDirectory    bool      `json:"-"`              // Set when using Delimiter to show that this is a group of containers rather than a container
+	SubDir             string    `json:"subdir"`         // returned only when using Delimiter to mark groups of containers
 }
 
 // Containers returns a slice of structures with full information as
 // described in Container.
+//
+// If Delimiter is set in the opts then PseudoDirectory may be set with
+// Name the common prefix of a group of containers.  These are not real
+// containers.
 func (c *Connection) Containers(ctx context.Context, opts *ContainersOpts) ([]Container, error) {
 	v, h := opts.parse()
 	v.Set("format", "json")
@@ -954,7 +1593,23 @@ func (c *Connection) Containers(ctx context.Context, opts *ContainersOpts) ([]Co
 	}
 	var containers []Container
 	err = readJson(resp, &containers)
-	return containers, err
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		container := &containers[i]
+		if container.SubDir != "" {
+			container.Name = container.SubDir
+			container.PseudoDirectory = true
+		}
+		if container.ServerLastModified != "" {
+			container.LastModified, err = parseServerLastModified(container.ServerLastModified)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return containers, nil
 }
 
 // containersAllOpts makes a copy of opts if set or makes a new one and
@@ -992,7 +1647,12 @@ func (c *Connection) isLastPage(length int, limit int) bool {
 //
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ContainersAll(ctx context.Context, opts *ContainersOpts) ([]Container, error) {
-	opts = containersAllOpts(opts)
+	return c.containersAll(ctx, containersAllOpts(opts))
+}
+
+// containersAll calls Containers until the last page starting from
+// opts.Marker which it updates as it goes along
+func (c *Connection) containersAll(ctx context.Context, opts *ContainersOpts) ([]Container, error) {
 	containers := make([]Container, 0)
 	for {
 		newContainers, err := c.Containers(ctx, opts)
@@ -1008,26 +1668,139 @@ func (c *Connection) ContainersAll(ctx context.Context, opts *ContainersOpts) ([
 	return containers, nil
 }
 
-// ContainerNamesAll is like ContainerNames but it returns all the Containers
+// ContainersAllParallel is like ContainersAll but it splits the
+// container namespace into ranges which are listed concurrently.
 //
-// # It calls ContainerNames multiple times using the Marker parameter
+// The ranges are split at the names in boundaries, so passing
+// []string{"g", "n"} lists everything up to "g", between "g" and "n"
+// and after "n" in three concurrent listings.  Containers which are
+// named exactly as a boundary are found with an extra listing.
+// Boundaries which are evenly distributed over the names in use
+// give the best speedup.
 //
-// It has a default Limit parameter but you may pass in your own
-func (c *Connection) ContainerNamesAll(ctx context.Context, opts *ContainersOpts) ([]string, error) {
+// The result is sorted in the same order as ContainersAll would
+// return it.  As with ContainersAll, containers created or deleted
+// while the listing is in progress may or may not be included.
+//
+// Prefix, EndMarker, Limit and Headers in opts are used as in
+// ContainersAll.  Unlike ContainersAll, Marker isn't ignored - only
+// containers after it are listed, so Marker and EndMarker bound the
+// ranges.  If Reverse is set the ranges are listed forwards and the
+// result is reversed, so Marker remains the lower bound and EndMarker
+// the upper bound.
+func (c *Connection) ContainersAllParallel(ctx context.Context, opts *ContainersOpts, boundaries []string) ([]Container, error) {
+	marker := ""
+	if opts != nil {
+		marker = opts.Marker
+	}
 	opts = containersAllOpts(opts)
-	containers := make([]string, 0)
-	for {
-		newContainers, err := c.ContainerNames(ctx, opts)
-		if err != nil {
-			return nil, err
+	if opts.Reverse {
+		opts.Reverse = false
+		opts.Marker = marker
+		containers, err := c.ContainersAllParallel(ctx, opts, boundaries)
+		for i, j := 0, len(containers)-1; i < j; i, j = i+1, j-1 {
+			containers[i], containers[j] = containers[j], containers[i]
 		}
-		containers = append(containers, newContainers...)
-		if c.isLastPage(len(newContainers), opts.Limit) {
+		return containers, err
+	}
+	var sorted []string
+	for _, boundary := range boundaries {
+		if boundary != "" {
+			sorted = append(sorted, boundary)
+		}
+	}
+	sort.Strings(sorted)
+	boundaries = sorted
+	if len(boundaries) == 0 {
+		opts.Marker = marker
+		return c.containersAll(ctx, opts)
+	}
+
+	// inRange checks name is selected by the caller's opts
+	inRange := func(name string) bool {
+		return strings.HasPrefix(name, opts.Prefix) && name > marker && (opts.EndMarker == "" || name < opts.EndMarker)
+	}
+
+	// results[2*i] is the range below boundaries[i] and
+	// results[2*i+1] is the container named boundaries[i] if any
+	results := make([][]Container, 2*len(boundaries)+1)
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	lower := marker
+	for i := 0; i <= len(boundaries); i++ {
+		upper := opts.EndMarker
+		if i < len(boundaries) {
+			upper = boundaries[i]
+			if opts.EndMarker != "" && opts.EndMarker < upper {
+				upper = opts.EndMarker
+			}
+		}
+		if upper == "" || lower < upper {
+			rangeOpts := *opts
+			rangeOpts.Marker = lower
+			rangeOpts.EndMarker = upper
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[2*i], errs[2*i] = c.containersAll(ctx, &rangeOpts)
+			}(i)
+		}
+		if i == len(boundaries) {
 			break
 		}
-		opts.Marker = newContainers[len(newContainers)-1]
+		if name := boundaries[i]; inRange(name) && (i == 0 || name != boundaries[i-1]) {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				// The first container with the boundary as
+				// prefix is the boundary if it exists
+				found, err := c.Containers(ctx, &ContainersOpts{
+					Limit:   1,
+					Prefix:  name,
+					Headers: opts.Headers,
+				})
+				if err == nil && len(found) > 0 && found[0].Name == name {
+					results[2*i+1] = found[:1]
+				}
+				errs[2*i+1] = err
+			}(i)
+		}
+		if boundaries[i] > lower {
+			lower = boundaries[i]
+		}
 	}
-	return containers, nil
+	wg.Wait()
+
+	containers := make([]Container, 0)
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		containers = append(containers, results[i]...)
+	}
+	return containers, nil
+}
+
+// ContainerNamesAll is like ContainerNames but it returns all the Containers
+//
+// # It calls ContainerNames multiple times using the Marker parameter
+//
+// It has a default Limit parameter but you may pass in your own
+func (c *Connection) ContainerNamesAll(ctx context.Context, opts *ContainersOpts) ([]string, error) {
+	opts = containersAllOpts(opts)
+	containers := make([]string, 0)
+	for {
+		newContainers, err := c.ContainerNames(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, newContainers...)
+		if c.isLastPage(len(newContainers), opts.Limit) {
+			break
+		}
+		opts.Marker = newContainers[len(newContainers)-1]
+	}
+	return containers, nil
 }
 
 /* ------------------------------------------------------------ */
@@ -1040,6 +1813,7 @@ type ObjectsOpts struct {
 	Prefix     string  // For a string value x, causes the results to be limited to object names beginning with the substring x.
 	Path       string  // For a string value x, return the object names nested in the pseudo path
 	Delimiter  rune    // For a character c, return all the object names nested in the container
+	Reverse    bool    // Return object names in reverse order - Marker and EndMarker are then upper and lower bounds.
 	Headers    Headers // Any additional HTTP headers - can be nil
 	KeepMarker bool    // Do not reset Marker when using ObjectsAll or ObjectNamesAll
 }
@@ -1067,6 +1841,9 @@ func (opts *ObjectsOpts) parse() (url.Values, Headers) {
 		if opts.Delimiter != 0 {
 			v.Set("delimiter", string(opts.Delimiter))
 		}
+		if opts.Reverse {
+			v.Set("reverse", "true")
+		}
 		h = opts.Headers
 	}
 	return v, h
@@ -1085,6 +1862,23 @@ func (c *Connection) ObjectNames(ctx context.Context, container string, opts *Ob
 	if err != nil {
 		return nil, err
 	}
+	// Some gateways return JSON even though it wasn't asked for
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/json" {
+		var objects []Object
+		err = readJson(resp, &objects)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(objects))
+		for i, object := range objects {
+			if object.SubDir != "" {
+				names[i] = object.SubDir
+			} else {
+				names[i] = object.Name
+			}
+		}
+		return names, nil
+	}
 	return readLines(resp)
 }
 
@@ -1098,7 +1892,8 @@ type Object struct {
 	Hash               string     `json:"hash"`     // MD5 hash, eg "d41d8cd98f00b204e9800998ecf8427e"
 	SLOHash            string     `json:"slo_etag"` // MD5 hash of all segments' MD5 hash, eg "d41d8cd98f00b204e9800998ecf8427e"
 	PseudoDirectory    bool       // Set when using delimiter to show that this directory object does not really exist
-	SubDir             string     `json:"subdir"` // returned only when using delimiter to mark "pseudo directories"
+	SubDir             string     `json:"subdir"`       // returned only when using delimiter to mark "pseudo directories"
+	SymlinkPath        string     `json:"symlink_path"` // path of the target if this is a symlink - only set in listings
 	ObjectType         ObjectType // type of this object
 }
 
@@ -1110,6 +1905,25 @@ type Object struct {
 // objects but represent directories of objects which haven't had an
 // object created for them.
 func (c *Connection) Objects(ctx context.Context, container string, opts *ObjectsOpts) ([]Object, error) {
+	objects := []Object{}
+	_, _, err := c.objectsStream(ctx, container, opts, func(object Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil && len(objects) == 0 {
+		return nil, err
+	}
+	// Return any objects decoded before an error too
+	return objects, err
+}
+
+// objectsStream fetches one page of objects like Objects but calls fn
+// with each object as it is decoded rather than reading the whole page
+// into memory first.
+//
+// It returns the number of objects and the name of the last one.  If
+// fn returns an error the listing stops and that error is returned.
+func (c *Connection) objectsStream(ctx context.Context, container string, opts *ObjectsOpts, fn func(Object) error) (n int, last string, err error) {
 	v, h := opts.parse()
 	v.Set("format", "json")
 	resp, _, err := c.storage(ctx, RequestOpts{
@@ -1120,36 +1934,65 @@ func (c *Connection) Objects(ctx context.Context, container string, opts *Object
 		Headers:    h,
 	})
 	if err != nil {
-		return nil, err
+		return 0, "", err
 	}
-	var objects []Object
-	err = readJson(resp, &objects)
-	// Convert Pseudo directories and dates
-	for i := range objects {
-		object := &objects[i]
+	err = readJsonArray(resp, func(decoder *json.Decoder) error {
+		var object Object
+		if err := decoder.Decode(&object); err != nil {
+			return err
+		}
+		// Convert Pseudo directories and dates
 		if object.SubDir != "" {
 			object.Name = object.SubDir
 			object.PseudoDirectory = true
 			object.ContentType = "application/directory"
 		}
 		if object.ServerLastModified != "" {
-			// e.g. 2012-11-11T14:49:47, 2012-11-11T14:49:47Z, 2012-11-11T14:49:47.887250, or 2012-11-11T14:49:47.887250Z
-			// Remove the Z suffix and fractional seconds if present. This then keeps it consistent with Object which
-			// can only return timestamps accurate to 1 second
-			//
-			// The TimeFormat will parse fractional seconds if desired though
-			lastModified := strings.TrimSuffix(object.ServerLastModified, "Z")
-			datetime := strings.SplitN(lastModified, ".", 2)[0]
-			object.LastModified, err = time.Parse(TimeFormat, datetime)
+			var err error
+			object.LastModified, err = parseServerLastModified(object.ServerLastModified)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 		if object.SLOHash != "" {
 			object.ObjectType = StaticLargeObjectType
+		} else if object.SymlinkPath != "" {
+			object.ObjectType = SymlinkObjectType
 		}
+		n++
+		last = object.Name
+		return fn(object)
+	})
+	return n, last, err
+}
+
+// parseServerLastModified parses the last_modified field from a JSON
+// listing.
+func parseServerLastModified(serverLastModified string) (time.Time, error) {
+	// e.g. 2012-11-11T14:49:47, 2012-11-11T14:49:47Z, 2012-11-11T14:49:47.887250, or 2012-11-11T14:49:47.887250Z
+	// Remove the Z suffix and fractional seconds if present. This then keeps it consistent with Object which
+	// can only return timestamps accurate to 1 second
+	//
+	// The TimeFormat will parse fractional seconds if desired though
+	lastModified := strings.TrimSuffix(serverLastModified, "Z")
+	datetime := strings.SplitN(lastModified, ".", 2)[0]
+	return time.Parse(TimeFormat, datetime)
+}
+
+// pastEndMarker returns true if a listing which has reached marker
+// has also reached opts.EndMarker so there is nothing more to fetch.
+//
+// Swift excludes the EndMarker from listings so this shouldn't
+// happen, but it stops the paging if the server ignores end_marker or
+// FetchUntilEmptyPage or PartialPageFetchThreshold are set.
+func (opts *ObjectsOpts) pastEndMarker(marker string) bool {
+	if opts.EndMarker == "" || marker == "" {
+		return false
 	}
-	return objects, err
+	if opts.Reverse {
+		return marker <= opts.EndMarker
+	}
+	return marker >= opts.EndMarker
 }
 
 // objectsAllOpts makes a copy of opts if set or makes a new one and
@@ -1169,6 +2012,32 @@ func objectsAllOpts(opts *ObjectsOpts, Limit int) *ObjectsOpts {
 	return &newOpts
 }
 
+// ObjectsPage returns one page of objects in the container starting
+// after opts.Marker, and the marker to pass in opts.Marker to fetch
+// the next page.
+//
+// nextMarker is empty when there are no more pages. The last page may
+// be empty if the previous page was full.
+//
+// It has a default Limit parameter but you may pass in your own
+func (c *Connection) ObjectsPage(ctx context.Context, container string, opts *ObjectsOpts) (objects []Object, nextMarker string, err error) {
+	var newOpts ObjectsOpts
+	if opts != nil {
+		newOpts = *opts
+	}
+	if newOpts.Limit == 0 {
+		newOpts.Limit = allObjectsChanLimit
+	}
+	objects, err = c.Objects(ctx, container, &newOpts)
+	if err != nil {
+		return nil, "", err
+	}
+	if !c.isLastPage(len(objects), newOpts.Limit) && !newOpts.pastEndMarker(objects[len(objects)-1].Name) {
+		nextMarker = objects[len(objects)-1].Name
+	}
+	return objects, nextMarker, nil
+}
+
 // A closure defined by the caller to iterate through all objects
 //
 // Call Objects or ObjectNames from here with the context.Context and *ObjectOpts passed in
@@ -1183,12 +2052,22 @@ type ObjectsWalkFn func(context.Context, *ObjectsOpts) (interface{}, error)
 // Pass in a closure `walkFn` which calls Objects or ObjectNames with
 // the *ObjectsOpts passed to it and does something with the results.
 //
+// If Reverse is set the paging works the same way as Swift swaps the
+// meaning of Marker and EndMarker for reversed listings, so the last
+// name of each page is still the Marker for the next.
+//
 // # Errors will be returned from this function
 //
+// If ctx is cancelled the walk stops before fetching the next page
+// and returns ctx.Err().
+//
 // It has a default Limit parameter but you may pass in your own
 func (c *Connection) ObjectsWalk(ctx context.Context, container string, opts *ObjectsOpts, walkFn ObjectsWalkFn) error {
 	opts = objectsAllOpts(opts, allObjectsChanLimit)
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		objects, err := walkFn(ctx, opts)
 		if err != nil {
 			return err
@@ -1209,7 +2088,7 @@ func (c *Connection) ObjectsWalk(ctx context.Context, container string, opts *Ob
 		default:
 			panic("Unknown type returned to ObjectsWalk")
 		}
-		if c.isLastPage(n, opts.Limit) {
+		if c.isLastPage(n, opts.Limit) || opts.pastEndMarker(last) {
 			break
 		}
 		opts.Marker = last
@@ -1250,11 +2129,112 @@ func (c *Connection) ObjectNamesAll(ctx context.Context, container string, opts
 	return objects, err
 }
 
+// ObjectsInfo returns info about the objects called names in container
+// using container listings rather than a HEAD for each one.  Objects
+// which don't exist are left out of the map.
+//
+// The names are sorted and listed in pages restricted to their common
+// prefix, stopping after the last one, so this works best when the
+// names are close together, eg the segments of a large object.
+//
+// Only the fields a listing returns are set - Name, ContentType,
+// Bytes, LastModified and Hash - there is no metadata.  Listings are
+// updated asynchronously by Swift so they may lag behind a HEAD for
+// recently changed objects.  The Bytes and Hash of large objects are
+// those of the manifest, not of the whole object.
+func (c *Connection) ObjectsInfo(ctx context.Context, container string, names []string) (map[string]Object, error) {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	infos := make(map[string]Object, len(names))
+	opts := &ObjectsOpts{Limit: allObjectsChanLimit}
+	for len(names) > 0 {
+		opts.Prefix = commonPrefix(names[0], names[len(names)-1])
+		objects, err := c.Objects(ctx, container, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			for len(names) > 0 && names[0] < object.Name {
+				names = names[1:]
+			}
+			if len(names) > 0 && names[0] == object.Name {
+				infos[object.Name] = object
+				names = names[1:]
+			}
+		}
+		if c.isLastPage(len(objects), opts.Limit) {
+			break
+		}
+		opts.Marker = objects[len(objects)-1].Name
+	}
+	return infos, nil
+}
+
+// commonPrefix returns the longest prefix a and b have in common
+// without splitting a UTF-8 character
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	for i < len(a) && i > 0 && !utf8.RuneStart(a[i]) {
+		i--
+	}
+	return a[:i]
+}
+
 // Account contains information about this account.
 type Account struct {
-	BytesUsed  int64 // total number of bytes used
-	Containers int64 // total number of containers
-	Objects    int64 // total number of objects
+	BytesUsed  int64                  // total number of bytes used
+	Containers int64                  // total number of containers
+	Objects    int64                  // total number of objects
+	Policies   map[string]PolicyUsage // usage per storage policy name, nil if the server doesn't report it
+}
+
+// PolicyUsage is the usage of one storage policy in an Account
+type PolicyUsage struct {
+	BytesUsed  int64 // number of bytes used in this policy
+	Containers int64 // number of containers using this policy
+	Objects    int64 // number of objects in this policy
+}
+
+// parsePolicyUsage reads the X-Account-Storage-Policy-<name>-* headers
+// returning nil if there aren't any.
+//
+// The policy names are as canonicalised by net/http, eg "Gold".
+func parsePolicyUsage(headers Headers) (policies map[string]PolicyUsage, err error) {
+	const prefix = "X-Account-Storage-Policy-"
+	for key, value := range headers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name := key[len(prefix):]
+		var field func(*PolicyUsage) *int64
+		switch {
+		case strings.HasSuffix(name, "-Bytes-Used"):
+			name = strings.TrimSuffix(name, "-Bytes-Used")
+			field = func(u *PolicyUsage) *int64 { return &u.BytesUsed }
+		case strings.HasSuffix(name, "-Container-Count"):
+			name = strings.TrimSuffix(name, "-Container-Count")
+			field = func(u *PolicyUsage) *int64 { return &u.Containers }
+		case strings.HasSuffix(name, "-Object-Count"):
+			name = strings.TrimSuffix(name, "-Object-Count")
+			field = func(u *PolicyUsage) *int64 { return &u.Objects }
+		default:
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, newErrorf(0, "Bad Header '%s': '%s': %s", key, value, err)
+		}
+		if policies == nil {
+			policies = map[string]PolicyUsage{}
+		}
+		usage := policies[name]
+		*field(&usage) = n
+		policies[name] = usage
+	}
+	return policies, nil
 }
 
 // getInt64FromHeader is a helper function to decode int64 from header.
@@ -1295,9 +2275,18 @@ func (c *Connection) Account(ctx context.Context) (info Account, headers Headers
 	if info.Objects, err = getInt64FromHeader(resp, "X-Account-Object-Count"); err != nil {
 		return
 	}
+	if info.Policies, err = parsePolicyUsage(headers); err != nil {
+		return
+	}
 	return
 }
 
+// AccountMergeMetadata adds the metadata in add to the account and
+// removes the keys in remove, leaving the rest of its metadata alone.
+func (c *Connection) AccountMergeMetadata(ctx context.Context, add Metadata, remove []string) error {
+	return c.AccountUpdate(ctx, mergeMetadataHeaders("Account", add, remove))
+}
+
 // AccountUpdate adds, replaces or remove account metadata.
 //
 // Add or update keys by mentioning them in the Headers.
@@ -1329,6 +2318,22 @@ func (c *Connection) ContainerCreate(ctx context.Context, container string, h He
 	return err
 }
 
+// ContainerCreateWithPolicy is like ContainerCreate but makes the
+// container with the storage policy called policy, eg "gold".  Use
+// SwiftInfo.StoragePolicies to find which policies there are.
+//
+// The policy of a container can't be changed once it is made so if
+// the container exists already with a different policy Swift refuses
+// with 409 Conflict which is returned as ContainerNotEmpty.  Container
+// returns the policy in Container.StoragePolicy.
+func (c *Connection) ContainerCreateWithPolicy(ctx context.Context, container string, policy string, h Headers) error {
+	extraHeaders := Headers{"X-Storage-Policy": policy}
+	for key, value := range h {
+		extraHeaders[key] = value
+	}
+	return c.ContainerCreate(ctx, container, extraHeaders)
+}
+
 // ContainerDelete deletes a container.
 //
 // May return ContainerDoesNotExist or ContainerNotEmpty
@@ -1366,9 +2371,37 @@ func (c *Connection) Container(ctx context.Context, container string) (info Cont
 	// optional headers
 	info.QuotaBytes, _ = getInt64FromHeader(resp, "X-Container-Meta-Quota-Bytes")
 	info.QuotaCount, _ = getInt64FromHeader(resp, "X-Container-Meta-Quota-Count")
+	info.StoragePolicy = resp.Header.Get("X-Storage-Policy")
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		info.ServerLastModified = lastModified
+		info.LastModified, _ = time.Parse(http.TimeFormat, lastModified)
+	}
 	return
 }
 
+// ContainerMergeMetadata adds the metadata in add to the container
+// and removes the keys in remove, leaving the rest of its metadata
+// alone.
+//
+// A POST to a container only changes the metadata it mentions so
+// this doesn't need to read the existing metadata first.
+func (c *Connection) ContainerMergeMetadata(ctx context.Context, container string, add Metadata, remove []string) error {
+	return c.ContainerUpdate(ctx, container, mergeMetadataHeaders("Container", add, remove))
+}
+
+// mergeMetadataHeaders returns the headers for a POST to an account
+// or container (as kind) which sets add and removes remove
+func mergeMetadataHeaders(kind string, add Metadata, remove []string) Headers {
+	h := Headers{}
+	for _, key := range remove {
+		h[http.CanonicalHeaderKey("X-Remove-"+kind+"-Meta-"+key)] = "x"
+	}
+	for key, value := range add.Headers("X-" + kind + "-Meta-") {
+		h[key] = value
+	}
+	return h
+}
+
 // ContainerUpdate adds, replaces or removes container metadata.
 //
 // Add or update keys by mentioning them in the Metadata.
@@ -1394,7 +2427,7 @@ type ObjectCreateFile struct {
 	checkHash  bool           // whether we are checking the hash
 	pipeReader *io.PipeReader // pipe for the caller to use
 	pipeWriter *io.PipeWriter
-	hash       hash.Hash      // hash being build up as we go along
+	hash       hash.Hash      // hash being build up as we go along - nil unless checkHash
 	done       chan struct{}  // signals when the upload has finished
 	resp       *http.Response // valid when done has signalled
 	err        error          // ditto
@@ -1442,9 +2475,8 @@ func (file *ObjectCreateFile) Close() error {
 		return file.err
 	}
 	if file.checkHash {
-		receivedMd5 := strings.ToLower(file.headers["Etag"])
 		calculatedMd5 := fmt.Sprintf("%x", file.hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
+		if !etagMatches(file.headers["Etag"], calculatedMd5) {
 			return ObjectCorrupted
 		}
 	}
@@ -1464,6 +2496,27 @@ func (file *ObjectCreateFile) Headers() (Headers, error) {
 	return file.headers, nil
 }
 
+// StatusCode returns the HTTP status code of the response to the
+// upload, eg 201 Created, if it has been completed. The Close() method
+// must be called on an ObjectCreateFile before this method.
+//
+// If the upload failed the status code is that of the *Error returned
+// by Close(), or 0 if the request didn't get a response.
+func (file *ObjectCreateFile) StatusCode() (int, error) {
+	select {
+	case <-file.done:
+	default:
+		return 0, fmt.Errorf("cannot get status code, object upload has not yet completed")
+	}
+	if file.resp != nil {
+		return file.resp.StatusCode, nil
+	}
+	if swiftErr, ok := file.err.(*Error); ok {
+		return swiftErr.StatusCode, nil
+	}
+	return 0, nil
+}
+
 // Check it satisfies the interface
 var _ io.WriteCloser = &ObjectCreateFile{}
 
@@ -1493,6 +2546,30 @@ func objectPutHeaders(objectName string, checkHash *bool, Hash string, contentTy
 	return extraHeaders
 }
 
+// knownLength returns the number of bytes left to read in r if it can
+// be worked out without reading it.
+func knownLength(r io.Reader) (n int64, ok bool) {
+	switch v := r.(type) {
+	case *bytes.Reader:
+		return int64(v.Len()), true
+	case *bytes.Buffer:
+		return int64(v.Len()), true
+	case *strings.Reader:
+		return int64(v.Len()), true
+	case *os.File:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+		pos, err := v.Seek(0, io.SeekCurrent)
+		if err != nil || pos > fi.Size() {
+			return 0, false
+		}
+		return fi.Size() - pos, true
+	}
+	return 0, false
+}
+
 // ObjectCreate creates or updates the object in the container.  It
 // returns an io.WriteCloser you should write the contents to.  You
 // MUST call Close() on it and you MUST check the error return from
@@ -1517,12 +2594,14 @@ func (c *Connection) ObjectCreate(ctx context.Context, container string, objectN
 	extraHeaders := objectPutHeaders(objectName, &checkHash, Hash, contentType, h)
 	pipeReader, pipeWriter := io.Pipe()
 	file = &ObjectCreateFile{
-		hash:       md5.New(),
 		checkHash:  checkHash,
 		pipeReader: pipeReader,
 		pipeWriter: pipeWriter,
 		done:       make(chan struct{}),
 	}
+	if checkHash {
+		file.hash = md5.New()
+	}
 	// Run the PUT in the background piping it data
 	go func() {
 		opts := RequestOpts{
@@ -1530,9 +2609,9 @@ func (c *Connection) ObjectCreate(ctx context.Context, container string, objectN
 			ObjectName: objectName,
 			Operation:  "PUT",
 			Headers:    extraHeaders,
-			Body:       pipeReader,
+			Body:       withProgress(ctx, pipeReader, extraHeaders),
 			NoResponse: true,
-			ErrorMap:   objectErrorMap,
+			ErrorMap:   ObjectErrorMap,
 		}
 		file.resp, file.headers, file.err = c.storage(ctx, opts)
 		// Signal finished
@@ -1542,8 +2621,18 @@ func (c *Connection) ObjectCreate(ctx context.Context, container string, objectN
 	return
 }
 
+// ObjectSymlinkCreate creates a symlink called symlink in container
+// pointing at targetObject in targetContainer.
+//
+// If targetAccount is set the symlink points into that account, eg
+// "AUTH_shared", which the user reading the symlink needs read access
+// to.  It is sent as X-Symlink-Target-Account.
+//
+// If targetEtag is set a static symlink is made which will only
+// resolve to a target with that ETag.
+//
+// Returns the headers of the response.
 func (c *Connection) ObjectSymlinkCreate(ctx context.Context, container string, symlink string, targetAccount string, targetContainer string, targetObject string, targetEtag string) (headers Headers, err error) {
-
 	EMPTY_MD5 := "d41d8cd98f00b204e9800998ecf8427e"
 	symHeaders := Headers{}
 	contents := bytes.NewBufferString("")
@@ -1554,17 +2643,104 @@ func (c *Connection) ObjectSymlinkCreate(ctx context.Context, container string,
 		symHeaders["X-Symlink-Target-Etag"] = targetEtag
 	}
 	symHeaders["X-Symlink-Target"] = fmt.Sprintf("%s/%s", targetContainer, targetObject)
-	_, err = c.ObjectPut(ctx, container, symlink, contents, true, EMPTY_MD5, "application/symlink", symHeaders)
-	return
+	return c.ObjectPut(ctx, container, symlink, contents, true, EMPTY_MD5, "application/symlink", symHeaders)
+}
+
+// SymlinkTarget describes where a symlink points as returned by
+// ObjectSymlinkTarget.
+type SymlinkTarget struct {
+	Account   string // only set if the symlink points into a different account
+	Container string // container of the target
+	Object    string // name of the target object
+	Etag      string // ETag the target must have - only set for static links
+	IsStatic  bool   // set for static links which only resolve to a target with Etag
+}
+
+// ObjectSymlinkTarget returns where the symlink points without
+// following it.
+//
+// Static symlinks (made with a targetEtag in ObjectSymlinkCreate) have
+// IsStatic set so they can be recreated as static links when copied.
+//
+// Returns NotSymlink if the object isn't a symlink.
+func (c *Connection) ObjectSymlinkTarget(ctx context.Context, container string, symlink string) (target SymlinkTarget, err error) {
+	v := url.Values{}
+	v.Set("symlink", "get")
+	_, headers, err := c.storage(ctx, RequestOpts{
+		Container:  container,
+		ObjectName: symlink,
+		Operation:  "GET",
+		Parameters: v,
+		ErrorMap:   ObjectErrorMap,
+		NoResponse: true,
+	})
+	if err != nil {
+		return target, err
+	}
+	fullPath, ok := headers["X-Symlink-Target"]
+	if !ok {
+		return target, NotSymlink
+	}
+	target.Container, target.Object, err = parseFullPath(fullPath)
+	if err != nil {
+		return SymlinkTarget{}, err
+	}
+	target.Account = headers["X-Symlink-Target-Account"]
+	target.Etag = trimEtag(headers["X-Symlink-Target-Etag"])
+	target.IsStatic = target.Etag != ""
+	return target, nil
+}
+
+// hashReader is like io.TeeReader into a hash but can be rewound for
+// a retry if the underlying reader is an io.Seeker.
+type hashReader struct {
+	r    io.Reader
+	hash hash.Hash
+}
+
+// Read bytes - see io.Reader
+func (hr *hashReader) Read(p []byte) (n int, err error) {
+	n, err = hr.r.Read(p)
+	if n > 0 {
+		_, _ = hr.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+// Seek passes the seek on to the underlying reader, failing if it
+// can't seek.  Seek(0, io.SeekCurrent) finds the position, any other
+// seek must be back to the start of the data as the hash is reset.
+func (hr *hashReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := hr.r.(io.Seeker)
+	if !ok {
+		return 0, errors.New("can't seek")
+	}
+	pos, err := seeker.Seek(offset, whence)
+	if err == nil && !(offset == 0 && whence == io.SeekCurrent) {
+		hr.hash.Reset()
+	}
+	return pos, err
 }
 
 func (c *Connection) objectPut(ctx context.Context, container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers, parameters url.Values) (headers Headers, err error) {
 	extraHeaders := objectPutHeaders(objectName, &checkHash, Hash, contentType, h)
-	hash := md5.New()
-	var body io.Reader = contents
+	// Send a Content-Length rather than using chunked transfer
+	// encoding if we can, as some proxies refuse chunked PUTs
+	if _, ok := extraHeaders["Content-Length"]; !ok {
+		if n, ok := knownLength(contents); ok {
+			extraHeaders["Content-Length"] = strconv.FormatInt(n, 10)
+		}
+	}
+	// Only hash the data if it is going to be checked
+	var md5Hash hash.Hash
+	body := contents
 	if checkHash {
-		body = io.TeeReader(contents, hash)
+		md5Hash = md5.New()
+		body = &hashReader{r: contents, hash: md5Hash}
 	}
+	// Progress wraps the hashing reader, not contents, so it is
+	// reported whether or not the hash is checked
+	body = withProgress(ctx, body, extraHeaders)
 	_, headers, err = c.storage(ctx, RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
@@ -1572,16 +2748,15 @@ func (c *Connection) objectPut(ctx context.Context, container string, objectName
 		Headers:    extraHeaders,
 		Body:       body,
 		NoResponse: true,
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 		Parameters: parameters,
 	})
 	if err != nil {
 		return
 	}
 	if checkHash {
-		receivedMd5 := strings.ToLower(headers["Etag"])
-		calculatedMd5 := fmt.Sprintf("%x", hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
+		calculatedMd5 := fmt.Sprintf("%x", md5Hash.Sum(nil))
+		if !etagMatches(headers["Etag"], calculatedMd5) {
 			err = ObjectCorrupted
 			return
 		}
@@ -1603,17 +2778,45 @@ func (c *Connection) objectPut(ctx context.Context, container string, objectName
 // If you know the MD5 hash of the object ahead of time then set the
 // Hash parameter and it will be sent to the server (as an Etag
 // header) and the server will check the MD5 itself after the upload,
-// and this will return ObjectCorrupted if it is incorrect.
+// and this will return ObjectCorrupted if it is incorrect.  This
+// saves reading contents twice to work out the MD5 when it is already
+// known, and checkHash can then be false.
 //
 // If you don't want any error protection (not recommended) then set
 // checkHash to false and Hash to "".
 //
 // If contentType is set it will be used, otherwise one will be
 // guessed from objectName using mime.TypeByExtension
+//
+// To make the object expire set h.SetDeleteAt or h.SetDeleteAfter.
+//
+// Conditional headers may be passed in h, eg "If-None-Match": "*"
+// will only create the object if it doesn't already exist.  If the
+// condition isn't met PreconditionFailed will be returned.
+//
+// If contents is a *bytes.Reader, *bytes.Buffer, *strings.Reader or
+// a regular *os.File and h doesn't contain a Content-Length then one
+// is set from the data remaining to be read, otherwise the upload
+// uses chunked transfer encoding which some proxies reject with 411
+// Length Required.
 func (c *Connection) ObjectPut(ctx context.Context, container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (headers Headers, err error) {
 	return c.objectPut(ctx, container, objectName, contents, checkHash, Hash, contentType, h, nil)
 }
 
+// ObjectPutIfNotExists is like ObjectPut but only creates the object
+// if it doesn't exist already.
+//
+// It sends an "If-None-Match: *" header so the server checks for
+// existence atomically.  If the object exists PreconditionFailed
+// will be returned and the object will be left unchanged.
+func (c *Connection) ObjectPutIfNotExists(ctx context.Context, container string, objectName string, contents io.Reader, checkHash bool, Hash string, contentType string, h Headers) (headers Headers, err error) {
+	extraHeaders := Headers{"If-None-Match": "*"}
+	for key, value := range h {
+		extraHeaders[key] = value
+	}
+	return c.ObjectPut(ctx, container, objectName, contents, checkHash, Hash, contentType, extraHeaders)
+}
+
 // ObjectPutBytes creates an object from a []byte in a container.
 //
 // This is a simplified interface which checks the MD5.
@@ -1644,10 +2847,10 @@ type ObjectOpenFile struct {
 	container  string         // stored copy of container used in Open
 	objectName string         // stored copy of objectName used in Open
 	headers    Headers        // stored copy of headers used in Open
+	parameters url.Values     // stored copy of query parameters used in Open
 	resp       *http.Response // http connection
 	body       io.Reader      // read data from this
-	checkHash  bool           // true if checking MD5
-	hash       hash.Hash      // currently accumulating MD5
+	hashes     []checkedHash  // hashes being accumulated, if any
 	bytes      int64          // number of bytes read on this connection
 	eof        bool           // whether we have read end of file
 	pos        int64          // current position when reading
@@ -1655,6 +2858,9 @@ type ObjectOpenFile struct {
 	length     int64          // length of the object if read
 	seeked     bool           // whether we have seeked this file or not
 	overSeeked bool           // set if we have seeked to the end or beyond
+	info       Object         // info about the object read from the response
+	gunzip     bool           // set if body is decompressing the response
+	progress   ProgressFunc   // if set, called after each Read
 }
 
 // Read bytes from the object - see io.Reader
@@ -1663,11 +2869,20 @@ func (file *ObjectOpenFile) Read(p []byte) (n int, err error) {
 		return 0, io.EOF
 	}
 	n, err = file.body.Read(p)
-	file.bytes += int64(n)
+	if !file.gunzip {
+		file.bytes += int64(n)
+	}
 	file.pos += int64(n)
 	if err == io.EOF {
 		file.eof = true
 	}
+	if file.progress != nil && n > 0 {
+		total := file.info.Bytes
+		if file.gunzip || total <= 0 {
+			total = -1
+		}
+		file.progress(file.pos, total)
+	}
 	return
 }
 
@@ -1707,6 +2922,9 @@ func (file *ObjectOpenFile) Seek(ctx context.Context, offset int64, whence int)
 	if newPos == file.pos {
 		return
 	}
+	if file.gunzip {
+		return file.pos, newError(0, "Can't seek in a decompressed object")
+	}
 	// Close the file...
 	file.seeked = true
 	err = file.Close()
@@ -1722,18 +2940,27 @@ func (file *ObjectOpenFile) Seek(ctx context.Context, offset int64, whence int)
 	} else {
 		delete(file.headers, "Range")
 	}
-	newFile, _, err := file.connection.ObjectOpen(ctx, file.container, file.objectName, false, file.headers)
+	newFile, _, err := file.connection.objectOpen(ctx, file.container, file.objectName, false, file.headers, file.parameters)
 	if err != nil {
 		return
 	}
 	// Update the file
 	file.resp = newFile.resp
 	file.body = newFile.body
-	file.checkHash = false
+	file.hashes = nil
 	file.pos = newPos
 	return
 }
 
+// Info returns the Object info parsed from the headers of the
+// response used to open the file.
+//
+// Bytes is the size of the whole object even if a Range was requested
+// provided the server returned it.
+func (file *ObjectOpenFile) Info() Object {
+	return file.info
+}
+
 // Length gets the objects content length either from a cached copy or
 // from the server.
 func (file *ObjectOpenFile) Length(ctx context.Context) (int64, error) {
@@ -1757,13 +2984,9 @@ func (file *ObjectOpenFile) Close() (err error) {
 		return
 	}
 
-	// Check the MD5 sum if requested
-	if file.checkHash {
-		// ETag header may be double quoted if following RFC 7232
-		// https://github.com/openstack/swift/blob/2.24.0/CHANGELOG#L9
-		receivedMd5 := strings.ToLower(strings.Trim(file.resp.Header.Get("Etag"), "\""))
-		calculatedMd5 := fmt.Sprintf("%x", file.hash.Sum(nil))
-		if receivedMd5 != calculatedMd5 {
+	// Check the hashes if requested
+	for _, h := range file.hashes {
+		if !etagMatches(h.expected, fmt.Sprintf("%x", h.hash.Sum(nil))) {
 			err = ObjectCorrupted
 			return
 		}
@@ -1777,13 +3000,54 @@ func (file *ObjectOpenFile) Close() (err error) {
 	return
 }
 
-func (c *Connection) objectOpenBase(ctx context.Context, container string, objectName string, checkHash bool, h Headers, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
+// checkedHash is a hash being accumulated while an object is read
+// and the hex encoded value it should have at the end.
+type checkedHash struct {
+	hash     hash.Hash
+	expected string
+}
+
+// DownloadOpts describes how an object should be checked as it is
+// downloaded
+type DownloadOpts struct {
+	CheckHash    bool             // If set check the MD5 against the ETag
+	Hash         func() hash.Hash // If set also calculate this hash, eg sha256.New
+	ExpectedHash string           // Hex encoded value Hash should have
+	HashHeader   string           // If ExpectedHash isn't set read it from this header, eg "X-Object-Meta-Sha256"
+	Headers      Headers          // Additional headers to send
+	Decompress   bool             // If set decompress the object if it has Content-Encoding: gzip
+	Progress     ProgressFunc     // If set called after each Read with the position in the object and its size
+	Parameters   url.Values       // Query parameters to send, eg "multipart-manifest=get"
+}
+
+func (c *Connection) objectOpenWithOpts(ctx context.Context, container string, objectName string, dopts *DownloadOpts, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
 	var resp *http.Response
+	h := dopts.Headers
+	if (dopts.Decompress || dopts.CheckHash) && h["Accept-Encoding"] == "" {
+		// Stop the http.Transport asking for gzip and decompressing
+		// objects stored with Content-Encoding: gzip itself, which
+		// would make the hash and length checks fail
+		h = Headers{}
+		for key, value := range dopts.Headers {
+			h[key] = value
+		}
+		h["Accept-Encoding"] = "identity"
+	}
+	if len(dopts.Parameters) > 0 {
+		merged := url.Values{}
+		for key, values := range dopts.Parameters {
+			merged[key] = values
+		}
+		for key, values := range parameters {
+			merged[key] = values
+		}
+		parameters = merged
+	}
 	opts := RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "GET",
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 		Headers:    h,
 		Parameters: parameters,
 	}
@@ -1791,35 +3055,90 @@ func (c *Connection) objectOpenBase(ctx context.Context, container string, objec
 	if err != nil {
 		return
 	}
-	// Can't check MD5 on an object with X-Object-Manifest or X-Static-Large-Object set
-	if checkHash && headers.IsLargeObject() {
-		// log.Printf("swift: turning off md5 checking on object with manifest %v", objectName)
-		checkHash = false
+	// Don't fail the download if the info can't be parsed
+	info, _ := parseObjectInfo(resp, objectName)
+	progress := dopts.Progress
+	if progress == nil {
+		progress = progressFromContext(ctx)
 	}
 	file = &ObjectOpenFile{
 		connection: c,
 		container:  container,
 		objectName: objectName,
-		headers:    h,
+		headers:    dopts.Headers,
+		parameters: parameters,
 		resp:       resp,
-		checkHash:  checkHash,
 		body:       resp.Body,
+		info:       info,
+		progress:   progress,
 	}
-	if checkHash {
-		file.hash = md5.New()
-		file.body = io.TeeReader(resp.Body, file.hash)
+	// Can't check MD5 on an object with X-Object-Manifest or X-Static-Large-Object set
+	if dopts.CheckHash && !headers.IsLargeObject() {
+		file.hashes = append(file.hashes, checkedHash{
+			hash:     md5.New(),
+			expected: resp.Header.Get("Etag"),
+		})
+	}
+	if dopts.Hash != nil {
+		expected := dopts.ExpectedHash
+		if expected == "" && dopts.HashHeader != "" {
+			expected = resp.Header.Get(dopts.HashHeader)
+		}
+		// Nothing to check against if the header is missing
+		if expected != "" {
+			file.hashes = append(file.hashes, checkedHash{
+				hash:     dopts.Hash(),
+				expected: expected,
+			})
+		}
+	}
+	if len(file.hashes) > 0 {
+		writers := make([]io.Writer, len(file.hashes))
+		for i, h := range file.hashes {
+			writers[i] = h.hash
+		}
+		file.body = io.TeeReader(resp.Body, io.MultiWriter(writers...))
 	}
 	// Read Content-Length
 	if resp.Header.Get("Content-Length") != "" {
 		file.length, err = getInt64FromHeader(resp, "Content-Length")
 		file.lengthOk = (err == nil)
 	}
+	if dopts.Decompress && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		// The hashes and length are checked on the compressed data
+		// so count it as it is read
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(&countingReader{r: file.body, n: &file.bytes})
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, headers, err
+		}
+		file.body = gz
+		file.gunzip = true
+	}
+	return
+}
+
+// countingReader adds the number of bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+// Read bytes - see io.Reader
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	*cr.n += int64(n)
 	return
 }
 
 func (c *Connection) objectOpen(ctx context.Context, container string, objectName string, checkHash bool, h Headers, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
+	return c.objectOpenRetry(ctx, container, objectName, &DownloadOpts{CheckHash: checkHash, Headers: h}, parameters)
+}
+
+func (c *Connection) objectOpenRetry(ctx context.Context, container string, objectName string, opts *DownloadOpts, parameters url.Values) (file *ObjectOpenFile, headers Headers, err error) {
 	err = withLORetry(0, func() (Headers, int64, error) {
-		file, headers, err = c.objectOpenBase(ctx, container, objectName, checkHash, h, parameters)
+		file, headers, err = c.objectOpenWithOpts(ctx, container, objectName, opts, parameters)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -1855,6 +3174,60 @@ func (c *Connection) ObjectOpen(ctx context.Context, container string, objectNam
 	return c.objectOpen(ctx, container, objectName, checkHash, h, nil)
 }
 
+// ObjectOpenWithOpts is like ObjectOpen but the checks done on the
+// downloaded data are described by opts.
+//
+// As well as, or instead of, the MD5 a hash of the caller's choosing
+// can be calculated and checked against opts.ExpectedHash or the
+// value of the opts.HashHeader response header, eg
+//
+//	opts := &DownloadOpts{
+//		Hash:       sha256.New,
+//		HashHeader: "X-Object-Meta-Sha256",
+//	}
+//
+// Close() returns ObjectCorrupted if any of the hashes don't match.
+// No checking will be done if you don't read all the contents or if
+// the hash header isn't present on the object.
+//
+// Unlike the MD5 the extra hash is checked for large objects too as
+// it is compared with a value the caller supplied for the whole
+// object.
+//
+// If opts.Decompress is set and the object was stored with
+// Content-Encoding: gzip then it is decompressed as it is read. The
+// hashes and length are checked against the compressed data as that
+// is what Swift stores, and the returned headers still describe the
+// compressed object. A decompressing file can't be seeked.
+//
+// If opts.Progress is set, or ctx was made with WithProgress, it is
+// called after each Read with the position in the object and the size
+// of the whole object, or -1 if that isn't known. The position is
+// absolute so it carries on from the right place after a Seek.
+//
+// opts.Parameters are added to the query string of the GET, and of
+// the GETs made by Seek, so options of Swift middlewares can be used,
+// eg "multipart-manifest=get" to read the manifest of a static large
+// object or "symlink=get" to read a symlink rather than its target.
+func (c *Connection) ObjectOpenWithOpts(ctx context.Context, container string, objectName string, opts *DownloadOpts) (file *ObjectOpenFile, headers Headers, err error) {
+	if opts == nil {
+		opts = &DownloadOpts{}
+	}
+	return c.objectOpenRetry(ctx, container, objectName, opts, nil)
+}
+
+// ObjectGetWithOpts is like ObjectGet but the checks done on the
+// downloaded data are described by opts - see ObjectOpenWithOpts.
+func (c *Connection) ObjectGetWithOpts(ctx context.Context, container string, objectName string, contents io.Writer, opts *DownloadOpts) (headers Headers, err error) {
+	file, headers, err := c.ObjectOpenWithOpts(ctx, container, objectName, opts)
+	if err != nil {
+		return
+	}
+	defer checkClose(file, &err)
+	_, err = io.Copy(contents, file)
+	return
+}
+
 // ObjectGet gets the object into the io.Writer contents.
 //
 // Returns the headers of the response.
@@ -1874,6 +3247,22 @@ func (c *Connection) ObjectGet(ctx context.Context, container string, objectName
 	return
 }
 
+// ObjectGetWithInfo is like ObjectGet but it also returns the Object
+// info parsed from the response so there is no need for a separate
+// call to Object.
+//
+// Use headers.ObjectMetadata() to read the metadata in the Headers.
+func (c *Connection) ObjectGetWithInfo(ctx context.Context, container string, objectName string, contents io.Writer, checkHash bool, h Headers) (info Object, headers Headers, err error) {
+	file, headers, err := c.ObjectOpen(ctx, container, objectName, checkHash, h)
+	if err != nil {
+		return
+	}
+	defer checkClose(file, &err)
+	info = file.Info()
+	_, err = io.Copy(contents, file)
+	return
+}
+
 // ObjectGetBytes returns an object as a []byte.
 //
 // This is a simplified interface which checks the MD5
@@ -1894,7 +3283,299 @@ func (c *Connection) ObjectGetString(ctx context.Context, container string, obje
 	return
 }
 
-// ObjectDelete deletes the object.
+// Range describes a byte range of an object for ObjectGetRanges
+type Range struct {
+	Start  int64 // Offset of the first byte in the range
+	Length int64 // Number of bytes in the range - 0 means to the end of the object
+}
+
+// String returns the range in the form used by the HTTP Range header
+func (r Range) String() string {
+	if r.Length <= 0 {
+		return fmt.Sprintf("%d-", r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.Start+r.Length-1)
+}
+
+// ObjectRangePart is a single part of the response to ObjectGetRanges.
+//
+// Read the data for the part from it before calling NextPart again.
+type ObjectRangePart struct {
+	io.Reader          // data for this part
+	ContentType string // Content-Type of the part
+	Start       int64  // offset of the first byte of this part
+	End         int64  // offset of the last byte of this part
+	Size        int64  // size of the whole object or -1 if unknown
+}
+
+// ObjectRanges reads the parts returned by ObjectGetRanges
+//
+// You must call Close() on it when finished
+type ObjectRanges struct {
+	resp *http.Response    // http connection
+	mr   *multipart.Reader // set if the response is multipart/byteranges
+	part *ObjectRangePart  // the only part if the response isn't multipart
+}
+
+// parseContentRange parses a Content-Range header such as "bytes 0-99/1000".
+//
+// size is returned as -1 if the server doesn't know it.
+func parseContentRange(contentRange string) (start, end, size int64, err error) {
+	_, err = fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &end)
+	if err != nil {
+		return 0, 0, 0, newErrorf(0, "Bad Content-Range %q: %v", contentRange, err)
+	}
+	size = -1
+	if i := strings.LastIndex(contentRange, "/"); i >= 0 && contentRange[i+1:] != "*" {
+		size, err = strconv.ParseInt(contentRange[i+1:], 10, 64)
+		if err != nil {
+			return 0, 0, 0, newErrorf(0, "Bad Content-Range %q: %v", contentRange, err)
+		}
+	}
+	return start, end, size, nil
+}
+
+// NextPart returns the next part of the response or io.EOF if there
+// are no more parts.
+func (r *ObjectRanges) NextPart() (*ObjectRangePart, error) {
+	if r.mr == nil {
+		part := r.part
+		if part == nil {
+			return nil, io.EOF
+		}
+		r.part = nil
+		return part, nil
+	}
+	p, err := r.mr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	part := &ObjectRangePart{
+		Reader:      p,
+		ContentType: p.Header.Get("Content-Type"),
+	}
+	part.Start, part.End, part.Size, err = parseContentRange(p.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// Close the response
+func (r *ObjectRanges) Close() (err error) {
+	drainAndClose(r.resp.Body, &err)
+	return err
+}
+
+// ObjectGetRanges fetches several byte ranges of an object in a
+// single request.
+//
+// Swift returns these as a multipart/byteranges response which is
+// read part by part with NextPart.  If the server decides to return
+// a single range (for instance if only one range was asked for, or
+// the ranges overlap) or the whole object then this will be returned
+// as a single part.
+//
+// You must call Close() on the result when finished.
+//
+// Returns the headers of the response.
+func (c *Connection) ObjectGetRanges(ctx context.Context, container string, objectName string, ranges []Range) (result *ObjectRanges, headers Headers, err error) {
+	if len(ranges) == 0 {
+		return nil, nil, newError(0, "No ranges supplied")
+	}
+	specs := make([]string, len(ranges))
+	for i, r := range ranges {
+		specs[i] = r.String()
+	}
+	var resp *http.Response
+	resp, headers, err = c.storage(ctx, RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  "GET",
+		ErrorMap:   ObjectErrorMap,
+		Headers:    Headers{"Range": "bytes=" + strings.Join(specs, ",")},
+	})
+	if err != nil {
+		return
+	}
+	result = &ObjectRanges{resp: resp}
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "multipart/byteranges" && params["boundary"] != "" {
+		result.mr = multipart.NewReader(resp.Body, params["boundary"])
+		return
+	}
+	part := &ObjectRangePart{
+		Reader:      resp.Body,
+		ContentType: resp.Header.Get("Content-Type"),
+		End:         resp.ContentLength - 1,
+		Size:        resp.ContentLength,
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		part.Start, part.End, part.Size, err = parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			drainAndClose(resp.Body, nil)
+			return nil, headers, err
+		}
+	}
+	result.part = part
+	return
+}
+
+// ContentRange describes the part of an object returned by
+// ObjectGetWithRange.
+type ContentRange struct {
+	Start int64 // Offset of the first byte returned
+	End   int64 // Offset of the last byte returned
+	Size  int64 // Total size of the object or -1 if unknown
+}
+
+// ObjectGetWithRange opens a single range of the object for reading.
+//
+// Unlike ObjectOpen followed by Seek this only ever makes one request
+// and it returns the parsed Content-Range so the total size of the
+// object is known even though only part of it was read.  If the
+// server ignores the range and returns the whole object then the
+// ContentRange returned covers all of it.
+//
+// The MD5 isn't checked as a partial body can't match the ETag of the
+// whole object.
+//
+// The returned body must be closed after use.
+//
+// h may contain additional headers to send.
+//
+// May return ObjectNotFound or an error if the range can't be satisfied.
+func (c *Connection) ObjectGetWithRange(ctx context.Context, container string, objectName string, r Range, h Headers) (body io.ReadCloser, contentRange ContentRange, headers Headers, err error) {
+	extraHeaders := Headers{}
+	for key, value := range h {
+		extraHeaders[key] = value
+	}
+	extraHeaders["Range"] = "bytes=" + r.String()
+	file, headers, err := c.objectOpen(ctx, container, objectName, false, extraHeaders, nil)
+	if err != nil {
+		return nil, contentRange, headers, err
+	}
+	if file.resp.StatusCode == http.StatusPartialContent {
+		contentRange.Start, contentRange.End, contentRange.Size, err = parseContentRange(file.resp.Header.Get("Content-Range"))
+		if err != nil {
+			_ = file.Close()
+			return nil, contentRange, headers, err
+		}
+	} else {
+		contentRange.Size = file.resp.ContentLength
+		contentRange.End = contentRange.Size - 1
+	}
+	return file, contentRange, headers, nil
+}
+
+// parallelDownloadMaxChunk is the largest range fetched by each
+// request in ObjectGetParallel
+const parallelDownloadMaxChunk = 64 * 1024 * 1024
+
+// ObjectGetParallel downloads the object into w using up to
+// concurrency simultaneous range requests, which can be much quicker
+// than ObjectGet for big objects over high latency links.
+//
+// The object is split into ranges of at most 64 MiB, each of which is
+// written to w at its offset as it arrives.  Each range is fetched
+// with If-Match set to the ETag of the object so a change to the object
+// during the download fails with PreconditionFailed rather than
+// producing a mixture of versions.
+//
+// The MD5 of the object isn't checked.
+//
+// It returns the headers of the object, and ObjectNotFound if it
+// doesn't exist.
+func (c *Connection) ObjectGetParallel(ctx context.Context, container string, objectName string, w io.WriterAt, concurrency int) (headers Headers, err error) {
+	info, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return headers, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	chunkSize := (info.Bytes + int64(concurrency) - 1) / int64(concurrency)
+	if chunkSize > parallelDownloadMaxChunk {
+		chunkSize = parallelDownloadMaxChunk
+	}
+	h := Headers{}
+	if info.Hash != "" {
+		h["If-Match"] = `"` + info.Hash + `"`
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ranges := make(chan Range)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range ranges {
+				err := c.objectGetRangeAt(ctx, container, objectName, r, h, w)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+outer:
+	for start := int64(0); start < info.Bytes; start += chunkSize {
+		length := chunkSize
+		if start+length > info.Bytes {
+			length = info.Bytes - start
+		}
+		select {
+		case ranges <- Range{Start: start, Length: length}:
+		case <-ctx.Done():
+			break outer
+		}
+	}
+	close(ranges)
+	wg.Wait()
+	close(errs)
+	if err = <-errs; err != nil {
+		return headers, err
+	}
+	return headers, ctx.Err()
+}
+
+// objectGetRangeAt fetches r of the object and writes it to w at its
+// offset
+func (c *Connection) objectGetRangeAt(ctx context.Context, container string, objectName string, r Range, h Headers, w io.WriterAt) (err error) {
+	body, contentRange, _, err := c.ObjectGetWithRange(ctx, container, objectName, r, h)
+	if err != nil {
+		return err
+	}
+	defer checkClose(body, &err)
+	if contentRange.Start != r.Start {
+		return newErrorf(0, "Bad range returned: asked for %v got %+v", r, contentRange)
+	}
+	buf := make([]byte, 64*1024)
+	for offset, end := r.Start, r.Start+r.Length; offset < end; {
+		chunk := buf
+		if int64(len(chunk)) > end-offset {
+			chunk = chunk[:end-offset]
+		}
+		n, err := io.ReadFull(body, chunk)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = w.WriteAt(chunk[:n], offset); err != nil {
+			return err
+		}
+		offset += int64(n)
+	}
+	return nil
+}
+
+// ObjectDelete deletes the object.
 //
 // May return ObjectNotFound if the object isn't found
 func (c *Connection) ObjectDelete(ctx context.Context, container string, objectName string) error {
@@ -1902,26 +3583,301 @@ func (c *Connection) ObjectDelete(ctx context.Context, container string, objectN
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "DELETE",
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 	})
 	return err
 }
 
+// TempUrlDigest is the digest used to sign a temporary URL
+type TempUrlDigest string
+
+// Digests supported by ObjectTempUrlWithDigest - check
+// SwiftInfo["tempurl"]["allowed_digests"] for which the server allows
+const (
+	TempUrlSHA1   TempUrlDigest = "sha1"
+	TempUrlSHA256 TempUrlDigest = "sha256"
+	TempUrlSHA512 TempUrlDigest = "sha512"
+)
+
 // ObjectTempUrl returns a temporary URL for an object
+//
+// The URL is signed with SHA-1 - use ObjectTempUrlWithDigest for
+// servers which require a stronger digest.
 func (c *Connection) ObjectTempUrl(container string, objectName string, secretKey string, method string, expires time.Time) string {
+	return c.ObjectTempUrlWithOpts(container, objectName, secretKey, method, expires, nil)
+}
+
+// ObjectTempUrlWithDigest returns a temporary URL for an object signed
+// with digest.
+//
+// SHA-1 signatures are hex encoded as ObjectTempUrl does. Others are
+// base64 encoded with the digest name as a prefix, eg "sha512:...".
+//
+// Returns an empty string if digest isn't supported.
+func (c *Connection) ObjectTempUrlWithDigest(container string, objectName string, secretKey string, method string, expires time.Time, digest TempUrlDigest) string {
+	return c.ObjectTempUrlWithOpts(container, objectName, secretKey, method, expires, &TempUrlOpts{Digest: digest})
+}
+
+// ObjectTempUrlPrefix returns a temporary URL for the objects in
+// container whose names start with objectPrefix, signed with SHA-1.
+//
+// The URL returned points to objectPrefix itself - replace that with
+// the name of any object starting with objectPrefix keeping the query
+// string to fetch it.
+func (c *Connection) ObjectTempUrlPrefix(container string, objectPrefix string, secretKey string, method string, expires time.Time) string {
+	return c.ObjectTempUrlWithOpts(container, objectPrefix, secretKey, method, expires, &TempUrlOpts{Prefix: true})
+}
+
+// ObjectTempUrlPrefixWithDigest is like ObjectTempUrlPrefix but signs
+// the URL with digest as ObjectTempUrlWithDigest does.
+//
+// Returns an empty string if digest isn't supported.
+func (c *Connection) ObjectTempUrlPrefixWithDigest(container string, objectPrefix string, secretKey string, method string, expires time.Time, digest TempUrlDigest) string {
+	return c.ObjectTempUrlWithOpts(container, objectPrefix, secretKey, method, expires, &TempUrlOpts{Digest: digest, Prefix: true})
+}
+
+// TempUrlOpts is options for ObjectTempUrlWithOpts
+type TempUrlOpts struct {
+	Digest TempUrlDigest // Digest to sign with - default TempUrlSHA1
+	Prefix bool          // Sign objectName as a prefix as ObjectTempUrlPrefix does
+	IP     string        // Only allow this client IP address or CIDR range, eg "10.0.0.0/24"
+}
+
+// ObjectTempUrlWithOpts returns a temporary URL for an object as
+// ObjectTempUrl does with the extra options in opts.
+//
+// The signature is the HMAC of these lines joined with "\n"
+//
+//	ip=<IP>                       only if IP is set
+//	<method>
+//	<expires as a unix timestamp>
+//	prefix:<path to objectName>   if Prefix is set, otherwise just the path
+//
+// where the path is the path of the StorageUrl followed by
+// /container/objectName.
+//
+// Returns an empty string if the digest isn't supported.
+func (c *Connection) ObjectTempUrlWithOpts(container string, objectName string, secretKey string, method string, expires time.Time, opts *TempUrlOpts) string {
 	c.authLock.Lock()
 	storageUrl := c.StorageUrl
 	c.authLock.Unlock()
 	if storageUrl == "" {
 		return "" // Cannot do better without changing the interface
 	}
+	if opts == nil {
+		opts = &TempUrlOpts{}
+	}
+	digest := opts.Digest
+	if digest == "" {
+		digest = TempUrlSHA1
+	}
 
-	mac := hmac.New(sha1.New, []byte(secretKey))
 	prefix, _ := url.Parse(storageUrl)
-	body := fmt.Sprintf("%s\n%d\n%s/%s/%s", method, expires.Unix(), prefix.Path, container, objectName)
+	signedPath := fmt.Sprintf("%s/%s/%s", prefix.Path, container, objectName)
+	if opts.Prefix {
+		signedPath = "prefix:" + signedPath
+	}
+	body := fmt.Sprintf("%s\n%d\n%s", method, expires.Unix(), signedPath)
+	if opts.IP != "" {
+		body = fmt.Sprintf("ip=%s\n%s", opts.IP, body)
+	}
+	sig := tempUrlSig(secretKey, body, digest)
+	if sig == "" {
+		return ""
+	}
+	tempUrl := fmt.Sprintf("%s/%s/%s?temp_url_sig=%s&temp_url_expires=%d", storageUrl, container, objectName, sig, expires.Unix())
+	if opts.Prefix {
+		tempUrl += "&temp_url_prefix=" + url.QueryEscape(objectName)
+	}
+	if opts.IP != "" {
+		tempUrl += "&temp_url_ip=" + url.QueryEscape(opts.IP)
+	}
+	return tempUrl
+}
+
+// tempUrlSig returns the temp_url_sig for the body to sign, or an
+// empty string if digest isn't supported
+func tempUrlSig(secretKey string, body string, digest TempUrlDigest) string {
+	var newHash func() hash.Hash
+	switch digest {
+	case TempUrlSHA1:
+		newHash = sha1.New
+	case TempUrlSHA256:
+		newHash = sha256.New
+	case TempUrlSHA512:
+		newHash = sha512.New
+	default:
+		return ""
+	}
+	mac := hmac.New(newHash, []byte(secretKey))
 	mac.Write([]byte(body))
-	sig := hex.EncodeToString(mac.Sum(nil))
-	return fmt.Sprintf("%s/%s/%s?temp_url_sig=%s&temp_url_expires=%d", c.StorageUrl, container, objectName, sig, expires.Unix())
+	if digest == TempUrlSHA1 {
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	return url.QueryEscape(string(digest) + ":" + base64.URLEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// TempUrlKeys returns the account level keys used to sign temporary
+// URLs, for auditing which keys are in use.
+//
+// Either key may be empty if it isn't set.  Swift only returns these
+// to users who are allowed to change them.
+func (c *Connection) TempUrlKeys(ctx context.Context) (accountKey string, accountKey2 string, err error) {
+	_, headers, err := c.Account(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return headers["X-Account-Meta-Temp-Url-Key"], headers["X-Account-Meta-Temp-Url-Key-2"], nil
+}
+
+// ContainerTempUrlKeys returns the container level keys used to sign
+// temporary URLs for objects in container.
+//
+// Either key may be empty if it isn't set.
+func (c *Connection) ContainerTempUrlKeys(ctx context.Context, container string) (containerKey string, containerKey2 string, err error) {
+	_, headers, err := c.Container(ctx, container)
+	if err != nil {
+		return "", "", err
+	}
+	return headers["X-Container-Meta-Temp-Url-Key"], headers["X-Container-Meta-Temp-Url-Key-2"], nil
+}
+
+// GenerateTempUrlKey returns a new cryptographically random key
+// suitable for signing temporary URLs.
+func GenerateTempUrlKey() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// AccountTempUrlKeySet sets the account level keys used to sign
+// temporary URLs.
+//
+// URLs signed with either key are accepted so to rotate keys without
+// breaking URLs already handed out, set key to the new key and key2 to
+// the old one.  An empty key removes it.
+func (c *Connection) AccountTempUrlKeySet(ctx context.Context, key string, key2 string) error {
+	return c.AccountUpdate(ctx, Headers{
+		"X-Account-Meta-Temp-Url-Key":   key,
+		"X-Account-Meta-Temp-Url-Key-2": key2,
+	})
+}
+
+// ContainerTempUrlKeySet sets the container level keys used to sign
+// temporary URLs for objects in container.
+//
+// These work like the keys set by AccountTempUrlKeySet.
+func (c *Connection) ContainerTempUrlKeySet(ctx context.Context, container string, key string, key2 string) error {
+	return c.ContainerUpdate(ctx, container, Headers{
+		"X-Container-Meta-Temp-Url-Key":   key,
+		"X-Container-Meta-Temp-Url-Key-2": key2,
+	})
+}
+
+// AccountTempUrlKeyRotate generates a new account temp URL key,
+// moving the current key to the secondary key so URLs signed with it
+// keep working until the next rotation.
+//
+// It returns the new key.
+func (c *Connection) AccountTempUrlKeyRotate(ctx context.Context) (key string, err error) {
+	oldKey, _, err := c.TempUrlKeys(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err = GenerateTempUrlKey()
+	if err != nil {
+		return "", err
+	}
+	return key, c.AccountTempUrlKeySet(ctx, key, oldKey)
+}
+
+// ContainerTempUrlKeyRotate generates a new container temp URL key as
+// AccountTempUrlKeyRotate does.
+//
+// It returns the new key.
+func (c *Connection) ContainerTempUrlKeyRotate(ctx context.Context, container string) (key string, err error) {
+	oldKey, _, err := c.ContainerTempUrlKeys(ctx, container)
+	if err != nil {
+		return "", err
+	}
+	key, err = GenerateTempUrlKey()
+	if err != nil {
+		return "", err
+	}
+	return key, c.ContainerTempUrlKeySet(ctx, container, key, oldKey)
+}
+
+// TempUrlKeySelfTest checks that key works for temporary URLs by
+// fetching object through a short lived temporary URL signed with it.
+//
+// If key isn't already an account temp URL key it is set as the
+// secondary key for the duration of the test and the keys are then put
+// back as they were.  If object is empty a probe object is uploaded to
+// container and deleted afterwards.
+//
+// It returns true if the temporary URL worked, false if the server
+// refused it, or an error if the test couldn't be done.
+func (c *Connection) TempUrlKeySelfTest(ctx context.Context, container string, object string, key string) (ok bool, err error) {
+	oldKey, oldKey2, err := c.TempUrlKeys(ctx)
+	if err != nil {
+		return false, err
+	}
+	if key != oldKey && key != oldKey2 {
+		err = c.AccountTempUrlKeySet(ctx, oldKey, key)
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			restoreErr := c.AccountTempUrlKeySet(ctx, oldKey, oldKey2)
+			if err == nil {
+				err = restoreErr
+			}
+		}()
+	}
+	if object == "" {
+		var probe string
+		probe, err = GenerateTempUrlKey()
+		if err != nil {
+			return false, err
+		}
+		object = "goswift-tempurl-probe-" + probe[:16]
+		err = c.ObjectPutString(ctx, container, object, "probe", "text/plain")
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			deleteErr := c.ObjectDelete(ctx, container, object)
+			if err == nil {
+				err = deleteErr
+			}
+		}()
+	}
+	tempUrl := c.ObjectTempUrl(container, object, key, "GET", time.Now().Add(time.Minute))
+	req, err := http.NewRequestWithContext(ctx, "GET", tempUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Add("User-Agent", c.UserAgent)
+	timer := time.NewTimer(c.ConnectTimeout)
+	defer timer.Stop()
+	resp, err := c.doTimeoutRequest(timer, req)
+	if err != nil {
+		return false, err
+	}
+	drainAndClose(resp.Body, &err)
+	if err != nil {
+		return false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	}
+	return false, newErrorf(resp.StatusCode, "HTTP Error: %d: %s", resp.StatusCode, resp.Status)
 }
 
 // parseResponseStatus parses string like "200 OK" and returns Error.
@@ -1997,7 +3953,7 @@ func (c *Connection) doBulkDelete(ctx context.Context, objects []string, h Heade
 		return
 	}
 
-	err = parseResponseStatus(jsonResult.Status, objectErrorMap)
+	err = parseResponseStatus(jsonResult.Status, ObjectErrorMap)
 	result.NumberNotFound = jsonResult.NotFound
 	result.NumberDeleted = jsonResult.Deleted
 	result.Headers = headers
@@ -2006,7 +3962,7 @@ func (c *Connection) doBulkDelete(ctx context.Context, objects []string, h Heade
 		if len(t) != 2 {
 			continue
 		}
-		el[t[0]] = parseResponseStatus(t[1], objectErrorMap)
+		el[t[0]] = parseResponseStatus(t[1], ObjectErrorMap)
 	}
 	result.Errors = el
 	return
@@ -2024,24 +3980,376 @@ func (c *Connection) BulkDelete(ctx context.Context, container string, objectNam
 	return c.BulkDeleteHeaders(ctx, container, objectNames, nil)
 }
 
-// BulkDeleteHeaders deletes multiple objectNames from container in one operation.
-//
-// Some servers may not accept bulk-delete requests since bulk-delete is
-// an optional feature of swift - these will return the Forbidden error.
-//
-// See also:
-// * http://docs.openstack.org/trunk/openstack-object-storage/admin/content/object-storage-bulk-delete.html
-// * http://docs.rackspace.com/files/api/v1/cf-devguide/content/Bulk_Delete-d1e2338.html
-func (c *Connection) BulkDeleteHeaders(ctx context.Context, container string, objectNames []string, h Headers) (result BulkDeleteResult, err error) {
-	if len(objectNames) == 0 {
-		result.Errors = make(map[string]error)
-		return
+// BulkDeleteHeaders deletes multiple objectNames from container in one operation.
+//
+// If there are more objectNames than the server accepts in one bulk
+// delete (as read from QueryInfo) they are split into several requests
+// and the results added together.
+//
+// Some servers may not accept bulk-delete requests since bulk-delete is
+// an optional feature of swift - these will return the Forbidden error.
+//
+// See also:
+// * http://docs.openstack.org/trunk/openstack-object-storage/admin/content/object-storage-bulk-delete.html
+// * http://docs.rackspace.com/files/api/v1/cf-devguide/content/Bulk_Delete-d1e2338.html
+func (c *Connection) BulkDeleteHeaders(ctx context.Context, container string, objectNames []string, h Headers) (result BulkDeleteResult, err error) {
+	if len(objectNames) == 0 {
+		result.Errors = make(map[string]error)
+		return
+	}
+	fullPaths := make([]string, len(objectNames))
+	for i, name := range objectNames {
+		fullPaths[i] = fmt.Sprintf("/%s/%s", container, name)
+	}
+	return c.bulkDeleteChunked(ctx, fullPaths, h, 1)
+}
+
+// BulkDeletePaths deletes objects in any number of containers given
+// as paths like "container/object" in one operation.
+//
+// If there are more paths than the server accepts in one bulk delete
+// they are split into several requests as BulkDeleteHeaders does, and
+// up to concurrency of those are run at once - if concurrency is less
+// than 1 then 1 is used.  The results are added together.
+//
+// Some servers may not accept bulk-delete requests since bulk-delete is
+// an optional feature of swift - these will return the Forbidden error.
+func (c *Connection) BulkDeletePaths(ctx context.Context, paths []string, concurrency int) (result BulkDeleteResult, err error) {
+	if len(paths) == 0 {
+		result.Errors = make(map[string]error)
+		return
+	}
+	fullPaths := make([]string, len(paths))
+	for i, path := range paths {
+		fullPaths[i] = "/" + strings.TrimPrefix(path, "/")
+	}
+	return c.bulkDeleteChunked(ctx, fullPaths, nil, concurrency)
+}
+
+// bulkDeleteChunked bulk deletes fullPaths splitting them into
+// requests no bigger than the server accepts and running up to
+// concurrency of them at once.
+func (c *Connection) bulkDeleteChunked(ctx context.Context, fullPaths []string, h Headers, concurrency int) (result BulkDeleteResult, err error) {
+	maxDeletes := defaultMaxDeletesPerRequest
+	if info, infoErr := c.cachedQueryInfo(ctx); infoErr == nil {
+		maxDeletes = info.BulkDeleteMaxDeletesPerRequest()
+	}
+	if len(fullPaths) <= maxDeletes {
+		return c.doBulkDelete(ctx, fullPaths, h)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	result.Errors = make(map[string]error)
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed bool
+	)
+	tokens := make(chan struct{}, concurrency)
+	for start := 0; start < len(fullPaths); start += maxDeletes {
+		end := start + maxDeletes
+		if end > len(fullPaths) {
+			end = len(fullPaths)
+		}
+		tokens <- struct{}{}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-tokens
+			break
+		}
+		wg.Add(1)
+		go func(chunk []string) {
+			defer wg.Done()
+			chunkResult, chunkErr := c.doBulkDelete(ctx, chunk, h)
+			mu.Lock()
+			defer mu.Unlock()
+			result.NumberNotFound += chunkResult.NumberNotFound
+			result.NumberDeleted += chunkResult.NumberDeleted
+			for path, err := range chunkResult.Errors {
+				result.Errors[path] = err
+			}
+			if chunkResult.Headers != nil {
+				result.Headers = chunkResult.Headers
+			}
+			if chunkErr != nil {
+				if err == nil {
+					err = chunkErr
+				}
+				// Give up if the request failed rather than some
+				// of the deletes in it
+				if chunkResult.Headers == nil {
+					failed = true
+				}
+			}
+			<-tokens
+		}(fullPaths[start:end])
+	}
+	wg.Wait()
+	return result, err
+}
+
+// DeletePrefix deletes all the objects in container whose names start
+// with prefix, eg everything in a pseudo directory.
+//
+// The objects are listed a page at a time and each page is deleted
+// with a bulk delete if the server supports it, otherwise with
+// individual deletes.  Large objects are deleted with
+// LargeObjectDelete so their segments are deleted too.  As DLO
+// manifests look like empty objects in a listing, empty objects are
+// deleted this way as well.
+//
+// concurrency is the number of individual deletes to run at once - if
+// it is less than 1 then 1 is used.
+//
+// deleted is the number of objects deleted.  Objects which couldn't be
+// deleted are returned in failures with the error for each one but
+// this doesn't stop the deletion.  err is returned if the listing
+// fails.
+func (c *Connection) DeletePrefix(ctx context.Context, container string, prefix string, concurrency int) (deleted int, failures map[string]error, err error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	failures = make(map[string]error)
+	info, infoErr := c.cachedQueryInfo(ctx)
+	bulk := infoErr == nil && info.SupportsBulkDelete()
+	var mu sync.Mutex
+	result := func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == ObjectNotFound {
+			// Already gone, eg because of eventual consistency
+			return
+		} else if err != nil {
+			failures[name] = err
+		} else {
+			deleted++
+		}
+	}
+	err = c.ObjectsWalk(ctx, container, &ObjectsOpts{Prefix: prefix}, func(ctx context.Context, opts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.Objects(ctx, container, opts)
+		if err != nil {
+			return nil, err
+		}
+		var bulkNames []string
+		var wg sync.WaitGroup
+		tokens := make(chan struct{}, concurrency)
+		for _, object := range objects {
+			// Symlinks are deleted like regular objects so their
+			// targets are left alone
+			maybeLarge := object.ObjectType == StaticLargeObjectType || object.ObjectType == DynamicLargeObjectType || (object.ObjectType == RegularObjectType && object.Bytes == 0)
+			if bulk && !maybeLarge {
+				bulkNames = append(bulkNames, object.Name)
+				continue
+			}
+			tokens <- struct{}{}
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				var err error
+				if maybeLarge {
+					// This deletes regular objects too
+					err = c.LargeObjectDelete(ctx, container, name)
+				} else {
+					err = c.ObjectDelete(ctx, container, name)
+				}
+				result(name, err)
+				<-tokens
+			}(object.Name)
+		}
+		if len(bulkNames) > 0 {
+			c.deletePrefixBulk(ctx, container, bulkNames, result)
+		}
+		wg.Wait()
+		return objects, nil
+	})
+	return deleted, failures, err
+}
+
+// deletePrefixBulk bulk deletes names from container for DeletePrefix
+// calling result for each one.
+func (c *Connection) deletePrefixBulk(ctx context.Context, container string, names []string, result func(name string, err error)) {
+	bulkResult, err := c.BulkDelete(ctx, container, names)
+	if err != nil {
+		for _, name := range names {
+			result(name, err)
+		}
+		return
+	}
+	// Errors are keyed by the escaped full path of the object
+	errs := make(map[string]error, len(bulkResult.Errors))
+	for path, err := range bulkResult.Errors {
+		if name, unescapeErr := url.PathUnescape(path); unescapeErr == nil {
+			path = name
+		}
+		errs[strings.TrimPrefix(path, "/"+container+"/")] = err
+	}
+	for _, name := range names {
+		result(name, errs[name])
+	}
+}
+
+// ContainerDeleteRecursive deletes container and all the objects in
+// it.
+//
+// The objects are deleted with DeletePrefix so bulk delete is used if
+// the server supports it and the segments of large objects are
+// deleted too, then the container is deleted.  concurrency is the
+// number of individual deletes to run at once as in DeletePrefix.  As
+// listings are eventually consistent objects which show up after the
+// first pass are deleted too if the container can't be deleted,
+// waiting longer before each of the later passes.
+//
+// deleted is the number of objects deleted and failures has the error
+// for each object which couldn't be deleted.  If there were failures
+// the container isn't deleted and ContainerNotEmpty is returned,
+// otherwise err is from listing the objects or deleting the
+// container.
+func (c *Connection) ContainerDeleteRecursive(ctx context.Context, container string, concurrency int) (deleted int, failures map[string]error, err error) {
+	failures = make(map[string]error)
+	const attempts = 3
+	for attempt := 1; ; attempt++ {
+		n, passFailures, passErr := c.DeletePrefix(ctx, container, "", concurrency)
+		deleted += n
+		for name, deleteErr := range passFailures {
+			failures[name] = deleteErr
+		}
+		if passErr != nil {
+			return deleted, failures, passErr
+		}
+		if len(failures) > 0 {
+			return deleted, failures, ContainerNotEmpty
+		}
+		err = c.ContainerDelete(ctx, container)
+		if err != ContainerNotEmpty || attempt >= attempts {
+			return deleted, failures, err
+		}
+		// Give the listing time to catch up before the next pass
+		wait := time.NewTimer(retryBackoff(attempt - 1))
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			return deleted, failures, ctx.Err()
+		}
+	}
+}
+
+// CopyOpts is options for ContainerCopy
+type CopyOpts struct {
+	Prefix      string // Only copy objects whose names start with this
+	Mirror      bool   // Don't copy objects already in the destination with the same Etag
+	Concurrency int    // Number of objects to copy at once - default 1
+	Deep        bool   // Copy the segments of large objects too - see LargeObjectCopy
+}
+
+// CopyResult is the result of copying a single object with
+// ContainerCopy
+type CopyResult struct {
+	Name    string // Name of the object
+	Skipped bool   // Set if the object wasn't copied as it was already in the destination
+	Err     error  // The error copying the object if any
+}
+
+// ContainerCopy does a server side copy of all the objects in
+// srcContainer to dstContainer which must exist already.  Objects with
+// the same names in dstContainer are overwritten.
+//
+// Regular objects are copied with ObjectCopy so all their metadata and
+// the content type are preserved, and symlinks are copied as links.
+// Large objects are copied with LargeObjectCopy so only their user
+// metadata is preserved, and the segments are only copied too if
+// opts.Deep is set.
+//
+// If opts.Mirror is set the destination is listed first and objects
+// which are there already with the same Etag and size aren't copied.
+// Objects which may be dynamic large objects are always copied as the
+// listing doesn't show whether their segments have changed.
+//
+// It returns a result for each object in the order they were listed
+// with the error copying that object if any.  err is from listing the
+// containers.
+func (c *Connection) ContainerCopy(ctx context.Context, srcContainer string, dstContainer string, opts *CopyOpts) (results []CopyResult, err error) {
+	if opts == nil {
+		opts = &CopyOpts{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var existing map[string]Object
+	if opts.Mirror {
+		existing = make(map[string]Object)
+		err = c.ObjectsWalk(ctx, dstContainer, &ObjectsOpts{Prefix: opts.Prefix}, func(ctx context.Context, walkOpts *ObjectsOpts) (interface{}, error) {
+			objects, err := c.Objects(ctx, dstContainer, walkOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, object := range objects {
+				existing[object.Name] = object
+			}
+			return objects, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = c.ObjectsWalk(ctx, srcContainer, &ObjectsOpts{Prefix: opts.Prefix}, func(ctx context.Context, walkOpts *ObjectsOpts) (interface{}, error) {
+		objects, err := c.Objects(ctx, srcContainer, walkOpts)
+		if err != nil {
+			return nil, err
+		}
+		page := make([]CopyResult, len(objects))
+		var wg sync.WaitGroup
+		tokens := make(chan struct{}, concurrency)
+		for i, object := range objects {
+			page[i].Name = object.Name
+			maybeDLO := object.ObjectType == DynamicLargeObjectType || (object.ObjectType == RegularObjectType && object.Bytes == 0)
+			if dst, ok := existing[object.Name]; ok && !maybeDLO && sameObject(&object, &dst) {
+				page[i].Skipped = true
+				continue
+			}
+			tokens <- struct{}{}
+			wg.Add(1)
+			go func(result *CopyResult, object Object, maybeDLO bool) {
+				defer wg.Done()
+				result.Err = c.containerCopyObject(ctx, srcContainer, dstContainer, object, maybeDLO, opts.Deep)
+				<-tokens
+			}(&page[i], object, maybeDLO)
+		}
+		wg.Wait()
+		results = append(results, page...)
+		return objects, nil
+	})
+	return results, err
+}
+
+// containerCopyObject copies a single object for ContainerCopy
+func (c *Connection) containerCopyObject(ctx context.Context, srcContainer string, dstContainer string, object Object, maybeDLO bool, deep bool) error {
+	if object.ObjectType == StaticLargeObjectType || maybeDLO {
+		err := c.LargeObjectCopy(ctx, srcContainer, object.Name, dstContainer, object.Name, deep)
+		if err != NotLargeObject {
+			return err
+		}
+		// An empty regular object so copy it normally
+	}
+	_, err := c.ObjectCopy(ctx, srcContainer, object.Name, dstContainer, object.Name, nil)
+	return err
+}
+
+// sameObject returns true if the listings of src and dst show they
+// have the same size and contents
+func sameObject(src *Object, dst *Object) bool {
+	if src.ObjectType != dst.ObjectType || src.Bytes != dst.Bytes {
+		return false
 	}
-	fullPaths := make([]string, len(objectNames))
-	for i, name := range objectNames {
-		fullPaths[i] = fmt.Sprintf("/%s/%s", container, name)
+	if src.ObjectType == StaticLargeObjectType {
+		// Hash is of the manifest so compare the etags of the contents
+		return src.SLOHash != "" && src.SLOHash == dst.SLOHash
 	}
-	return c.doBulkDelete(ctx, fullPaths, h)
+	return src.Hash != "" && src.Hash == dst.Hash
 }
 
 // BulkUploadResult stores results of BulkUpload().
@@ -2107,7 +4415,7 @@ func (c *Connection) BulkUpload(ctx context.Context, uploadPath string, dataStre
 		return
 	}
 
-	err = parseResponseStatus(jsonResult.Status, objectErrorMap)
+	err = parseResponseStatus(jsonResult.Status, ObjectErrorMap)
 	result.NumberCreated = jsonResult.Created
 	result.Headers = headers
 	el := make(map[string]error, len(jsonResult.Errors))
@@ -2115,7 +4423,7 @@ func (c *Connection) BulkUpload(ctx context.Context, uploadPath string, dataStre
 		if len(t) != 2 {
 			continue
 		}
-		el[t[0]] = parseResponseStatus(t[1], objectErrorMap)
+		el[t[0]] = parseResponseStatus(t[1], ObjectErrorMap)
 	}
 	result.Errors = el
 	return
@@ -2123,12 +4431,15 @@ func (c *Connection) BulkUpload(ctx context.Context, uploadPath string, dataStre
 
 // Object returns info about a single object including any metadata in the header.
 //
+// If objectName is a symlink Swift follows it so this returns info
+// about the target - use ObjectNoFollow for the symlink itself.
+//
 // May return ObjectNotFound.
 //
 // Use headers.ObjectMetadata() to read the metadata in the Headers.
 func (c *Connection) Object(ctx context.Context, container string, objectName string) (info Object, headers Headers, err error) {
 	err = withLORetry(0, func() (Headers, int64, error) {
-		info, headers, err = c.objectBase(ctx, container, objectName)
+		info, headers, err = c.objectBase(ctx, container, objectName, nil)
 		if err != nil {
 			return headers, 0, err
 		}
@@ -2137,18 +4448,70 @@ func (c *Connection) Object(ctx context.Context, container string, objectName st
 	return
 }
 
-func (c *Connection) objectBase(ctx context.Context, container string, objectName string) (info Object, headers Headers, err error) {
+// ObjectDetails is everything known about an object as returned by
+// ObjectStat.
+type ObjectDetails struct {
+	Object             // info parsed from the headers
+	Metadata Metadata  // user metadata from the X-Object-Meta- headers
+	DeleteAt time.Time // when the object will be deleted, zero if it won't
+	Headers  Headers   // all the headers returned
+}
+
+// ObjectStat is like Object but returns the info, metadata and
+// headers together.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectStat(ctx context.Context, container string, objectName string) (details ObjectDetails, err error) {
+	info, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return details, err
+	}
+	details = ObjectDetails{
+		Object:   info,
+		Metadata: headers.ObjectMetadata(),
+		Headers:  headers,
+	}
+	if deleteAt, err := strconv.ParseInt(headers["X-Delete-At"], 10, 64); err == nil {
+		details.DeleteAt = time.Unix(deleteAt, 0)
+	}
+	return details, nil
+}
+
+// ObjectNoFollow is like Object but if objectName is a symlink it
+// returns info about the symlink itself rather than its target.
+//
+// Object (and ObjectOpen etc) on a symlink are resolved by Swift so
+// they return the metadata and data of the object it points to.  This
+// sends "?symlink=get" so the headers returned are those of the link
+// object, including X-Symlink-Target - see also ObjectSymlinkTarget.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectNoFollow(ctx context.Context, container string, objectName string) (info Object, headers Headers, err error) {
+	v := url.Values{}
+	v.Set("symlink", "get")
+	return c.objectBase(ctx, container, objectName, v)
+}
+
+func (c *Connection) objectBase(ctx context.Context, container string, objectName string, parameters url.Values) (info Object, headers Headers, err error) {
 	var resp *http.Response
 	resp, headers, err = c.storage(ctx, RequestOpts{
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "HEAD",
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 		NoResponse: true,
+		Parameters: parameters,
 	})
 	if err != nil {
 		return
 	}
+	info, err = parseObjectInfo(resp, objectName)
+	return
+}
+
+// parseObjectInfo reads the Object info out of the headers of a HEAD
+// or GET response for objectName.
+func parseObjectInfo(resp *http.Response, objectName string) (info Object, err error) {
 	// Parse the headers into the struct
 	// HTTP/1.1 200 OK
 	// Date: Thu, 07 Jun 2010 20:59:39 GMT
@@ -2163,7 +4526,17 @@ func (c *Connection) objectBase(ctx context.Context, container string, objectNam
 	// X-Object-Meta-Dairy: Bacon
 	info.Name = objectName
 	info.ContentType = resp.Header.Get("Content-Type")
-	if resp.Header.Get("Content-Length") != "" {
+	if resp.StatusCode == http.StatusPartialContent && resp.Header.Get("Content-Range") != "" {
+		// Content-Length is only the size of the range so read the
+		// size of the whole object from the Content-Range if known
+		var size int64
+		if _, _, size, err = parseContentRange(resp.Header.Get("Content-Range")); err != nil {
+			return
+		}
+		if size >= 0 {
+			info.Bytes = size
+		}
+	} else if resp.Header.Get("Content-Length") != "" {
 		if info.Bytes, err = getInt64FromHeader(resp, "Content-Length"); err != nil {
 			return
 		}
@@ -2177,14 +4550,8 @@ func (c *Connection) objectBase(ctx context.Context, container string, objectNam
 		}
 	}
 
-	// ETag header may be double quoted if following RFC 7232
-	// https://github.com/openstack/swift/blob/2.24.0/CHANGELOG#L9
-	info.Hash = strings.Trim(resp.Header.Get("Etag"), "\"")
-	if resp.Header.Get("X-Object-Manifest") != "" {
-		info.ObjectType = DynamicLargeObjectType
-	} else if resp.Header.Get("X-Static-Large-Object") != "" {
-		info.ObjectType = StaticLargeObjectType
-	}
+	info.Hash = trimEtag(resp.Header.Get("Etag"))
+	info.ObjectType = readHeaders(resp).ObjectType()
 
 	return
 }
@@ -2216,13 +4583,106 @@ func (c *Connection) ObjectUpdate(ctx context.Context, container string, objectN
 		Container:  container,
 		ObjectName: objectName,
 		Operation:  "POST",
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 		NoResponse: true,
 		Headers:    h,
 	})
 	return err
 }
 
+// objectPostHeaders are the headers other than the user metadata
+// which a POST to an object replaces, so they must be sent again to
+// keep them.
+var objectPostHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Expires",
+	"X-Object-Manifest",
+}
+
+// objectUpdateKeepMetadata does an ObjectUpdate with the headers
+// passed in merged with the object's existing metadata and
+// objectPostHeaders so they aren't lost.
+//
+// If edit is set it is called to change the existing metadata before
+// it is written back.
+func (c *Connection) objectUpdateKeepMetadata(ctx context.Context, container string, objectName string, edit func(Metadata), h Headers) error {
+	_, headers, err := c.Object(ctx, container, objectName)
+	if err != nil {
+		return err
+	}
+	metadata := headers.ObjectMetadata()
+	if edit != nil {
+		edit(metadata)
+	}
+	newHeaders := metadata.ObjectHeaders()
+	for _, key := range objectPostHeaders {
+		if value, ok := headers[key]; ok {
+			newHeaders[key] = value
+		}
+	}
+	// Keep the expiry unless it is being replaced
+	if deleteAt := headers["X-Delete-At"]; deleteAt != "" && h["X-Delete-After"] == "" {
+		newHeaders["X-Delete-At"] = deleteAt
+	}
+	for key, value := range h {
+		newHeaders[key] = value
+	}
+	return c.ObjectUpdate(ctx, container, objectName, newHeaders)
+}
+
+// ObjectMergeMetadata adds the metadata in add to the object and
+// removes the keys in remove, leaving the rest of its metadata alone.
+//
+// Since a POST to an object replaces all its metadata this reads the
+// existing metadata with a HEAD, merges the changes and writes it
+// back with ObjectUpdate. It isn't atomic so a concurrent update of
+// the object's metadata may be lost. Any X-Delete-At on the object is
+// kept, as are the headers a POST would remove such as
+// Content-Disposition and the X-Object-Manifest of a Dynamic Large
+// Object.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectMergeMetadata(ctx context.Context, container string, objectName string, add Metadata, remove []string) error {
+	merge := func(metadata Metadata) {
+		for _, key := range remove {
+			delete(metadata, strings.ToLower(key))
+		}
+		for key, value := range add {
+			metadata[strings.ToLower(key)] = value
+		}
+	}
+	return c.objectUpdateKeepMetadata(ctx, container, objectName, merge, nil)
+}
+
+// ObjectSetExpiry makes the server delete the object at deleteAt by
+// setting X-Delete-At on it.
+//
+// Existing metadata on the object is preserved along with headers a
+// POST would remove such as Content-Disposition and X-Object-Manifest.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectSetExpiry(ctx context.Context, container string, objectName string, deleteAt time.Time) error {
+	h := Headers{}
+	h.SetDeleteAt(deleteAt)
+	return c.objectUpdateKeepMetadata(ctx, container, objectName, nil, h)
+}
+
+// ObjectSetExpiryAfter makes the server delete the object after d by
+// setting X-Delete-After on it.
+//
+// Existing metadata on the object is preserved along with headers a
+// POST would remove such as Content-Disposition and X-Object-Manifest.
+//
+// May return ObjectNotFound.
+func (c *Connection) ObjectSetExpiryAfter(ctx context.Context, container string, objectName string, d time.Duration) error {
+	h := Headers{}
+	h.SetDeleteAfter(d)
+	return c.objectUpdateKeepMetadata(ctx, container, objectName, nil, h)
+}
+
 // urlPathEscape escapes URL path the in string using URL escaping rules
 //
 // This mimics url.PathEscape which only available from go 1.8
@@ -2237,34 +4697,179 @@ func urlPathEscape(in string) string {
 // All metadata is preserved.  If metadata is set in the headers then
 // it overrides the old metadata on the copied object.
 //
+// Swift doesn't copy the expiry time of an object, so if the source
+// has X-Delete-At set it is read with an extra HEAD request and set on
+// the copy.  If the HEAD fails the copy is done without it.  The HEAD
+// reads a symlink itself rather than its target so a copied link only
+// expires if the link did.
+//
+// If X-Delete-At or X-Delete-After is set in the headers then that is
+// used for the copy instead and the HEAD isn't needed.  Set
+// X-Delete-At to "" in the headers to make a copy which doesn't
+// expire.
+//
 // The destination container must exist before the copy.
 //
 // You can use this to copy an object to itself - this is the only way
 // to update the content type of an object.
 func (c *Connection) ObjectCopy(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	return c.objectCopy(ctx, srcContainer, srcObjectName, "", dstContainer, dstObjectName, h)
+}
+
+// ObjectCopyAccount does a server side copy of an object to
+// dstContainer, dstObjectName in dstAccount, eg "AUTH_0123456789abcdef"
+//
+// The user must be able to write to dstAccount, which must be on the
+// same cluster.  Metadata and the expiry time are preserved and
+// overridden by h as in ObjectCopy.
+//
+// Unlike ObjectCopy the destination container is checked first and
+// ContainerNotFound is returned if it doesn't exist.
+func (c *Connection) ObjectCopyAccount(ctx context.Context, srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	_, _, err = c.accountStorage(ctx, dstAccount, RequestOpts{
+		Container:  dstContainer,
+		Operation:  "HEAD",
+		ErrorMap:   ContainerErrorMap,
+		NoResponse: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.objectCopy(ctx, srcContainer, srcObjectName, dstAccount, dstContainer, dstObjectName, h)
+}
+
+// objectCopy does the server side copy for ObjectCopy and
+// ObjectCopyAccount.  If dstAccount is empty the destination is in
+// the account of the Connection.
+func (c *Connection) objectCopy(ctx context.Context, srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
 	// Meta stuff
 	extraHeaders := map[string]string{
 		"Destination": urlPathEscape(dstContainer + "/" + dstObjectName),
 	}
+	if dstAccount != "" {
+		extraHeaders["Destination-Account"] = dstAccount
+	}
 	for key, value := range h {
 		extraHeaders[key] = value
 	}
+	deleteAt, hasDeleteAt := h["X-Delete-At"]
+	_, hasDeleteAfter := h["X-Delete-After"]
+	if !hasDeleteAt && !hasDeleteAfter {
+		// Any error, eg ObjectNotFound, is returned by the COPY
+		_, srcHeaders, headErr := c.objectBase(ctx, srcContainer, srcObjectName, url.Values{"symlink": {"get"}})
+		if deleteAt := srcHeaders["X-Delete-At"]; headErr == nil && deleteAt != "" {
+			extraHeaders["X-Delete-At"] = deleteAt
+		}
+	} else if hasDeleteAt && deleteAt == "" {
+		// Swift rejects an empty X-Delete-At
+		delete(extraHeaders, "X-Delete-At")
+	}
 	_, headers, err = c.storage(ctx, RequestOpts{
 		Container:  srcContainer,
 		ObjectName: srcObjectName,
 		Operation:  "COPY",
-		ErrorMap:   objectErrorMap,
+		ErrorMap:   ObjectErrorMap,
 		NoResponse: true,
 		Headers:    extraHeaders,
 	})
 	return
 }
 
+// maxSymlinkHops is the maximum number of symlinks followed by
+// ObjectCopyFollowSymlink
+const maxSymlinkHops = 16
+
+// ObjectCopyFollowSymlink copies an object like ObjectCopy but if the
+// source is a symlink it copies the object the symlink points to so
+// the destination is a real object rather than another link.
+//
+// Symlinks to symlinks are followed too.  The copy is done on the
+// server unless the link points into another account in which case
+// the content is read and uploaded again.
+//
+// The destination container must exist before the copy.
+func (c *Connection) ObjectCopyFollowSymlink(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	for hops := 0; ; hops++ {
+		target, err := c.ObjectSymlinkTarget(ctx, srcContainer, srcObjectName)
+		if err == NotSymlink {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hops >= maxSymlinkHops {
+			return nil, newErrorf(0, "too many levels of symlinks copying %q", srcObjectName)
+		}
+		if target.Account != "" {
+			return c.objectCopyReadThrough(ctx, srcContainer, srcObjectName, dstContainer, dstObjectName, h)
+		}
+		srcContainer, srcObjectName = target.Container, target.Object
+	}
+	return c.ObjectCopy(ctx, srcContainer, srcObjectName, dstContainer, dstObjectName, h)
+}
+
+// objectCopyReadThrough copies an object by reading it, following any
+// symlinks, and uploading it to the destination with its metadata.
+func (c *Connection) objectCopyReadThrough(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, h Headers) (headers Headers, err error) {
+	in, srcHeaders, err := c.ObjectOpen(ctx, srcContainer, srcObjectName, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer checkClose(in, &err)
+	putHeaders := srcHeaders.ObjectMetadata().ObjectHeaders()
+	for key, value := range h {
+		putHeaders[key] = value
+	}
+	// The ETag of a large object isn't the MD5 of its content
+	hash := srcHeaders["Etag"]
+	if srcHeaders.IsLargeObject() {
+		hash = ""
+	}
+	return c.ObjectPut(ctx, dstContainer, dstObjectName, in, true, hash, srcHeaders["Content-Type"], putHeaders)
+}
+
+// ObjectCopyRange copies length bytes of the source object starting
+// at start to the destination. If length is 0 the copy runs to the
+// end of the source.
+//
+// Swift's COPY can't copy part of an object so this reads the range
+// with a GET and uploads it with a PUT, transferring the data through
+// the client. The metadata, Content-Type, expiry and headers such as
+// Content-Disposition and Content-Encoding of the source are
+// preserved as ObjectCopy does.  Note that a range of an object with
+// a Content-Encoding such as gzip won't decode on its own.
+//
+// The destination container must exist before the copy.
+func (c *Connection) ObjectCopyRange(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string, start int64, length int64) (headers Headers, err error) {
+	if start < 0 || length < 0 {
+		return nil, newErrorf(0, "bad range to copy: start %d length %d", start, length)
+	}
+	r := Range{Start: start, Length: length}
+	in, srcHeaders, err := c.ObjectOpen(ctx, srcContainer, srcObjectName, false, Headers{"Range": "bytes=" + r.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer checkClose(in, &err)
+	putHeaders := srcHeaders.ObjectMetadata().ObjectHeaders()
+	for _, key := range objectPostHeaders {
+		// Part of a large object is a normal object
+		if key == "X-Object-Manifest" {
+			continue
+		}
+		if value, ok := srcHeaders[key]; ok {
+			putHeaders[key] = value
+		}
+	}
+	if deleteAt := srcHeaders["X-Delete-At"]; deleteAt != "" {
+		putHeaders["X-Delete-At"] = deleteAt
+	}
+	return c.ObjectPut(ctx, dstContainer, dstObjectName, in, true, "", srcHeaders["Content-Type"], putHeaders)
+}
+
 // ObjectMove does a server side move of an object to a new position
 //
 // # This is a convenience method which calls ObjectCopy then ObjectDelete
 //
-// All metadata is preserved.
+// All metadata is preserved, including the expiry time if set.
 //
 // The destination container must exist before the copy.
 func (c *Connection) ObjectMove(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) (err error) {
@@ -2275,6 +4880,54 @@ func (c *Connection) ObjectMove(ctx context.Context, srcContainer string, srcObj
 	return c.ObjectDelete(ctx, srcContainer, srcObjectName)
 }
 
+// ObjectMoveAccount does a server side move of an object to
+// dstContainer, dstObjectName in dstAccount
+//
+// This is a convenience method which calls ObjectCopyAccount then
+// ObjectDelete, so the destination container must exist and the
+// metadata is preserved as in ObjectMove.
+func (c *Connection) ObjectMoveAccount(ctx context.Context, srcContainer string, srcObjectName string, dstAccount string, dstContainer string, dstObjectName string) (err error) {
+	_, err = c.ObjectCopyAccount(ctx, srcContainer, srcObjectName, dstAccount, dstContainer, dstObjectName, nil)
+	if err != nil {
+		return
+	}
+	return c.ObjectDelete(ctx, srcContainer, srcObjectName)
+}
+
+// ObjectMoveVerified does a server side move of an object to a new
+// position, checking the copy before deleting the source
+//
+// This is like ObjectMove but it compares the size and MD5 of the
+// destination with the source after the copy and only deletes the
+// source if they match.  For large objects only the assembled size is
+// compared as the MD5 of the manifest won't match the copy.
+//
+// Returns ObjectCopyMismatch if the copy doesn't match the source, in
+// which case the source is left in place and the bad copy is deleted
+// if possible.
+func (c *Connection) ObjectMoveVerified(ctx context.Context, srcContainer string, srcObjectName string, dstContainer string, dstObjectName string) (err error) {
+	src, srcHeaders, err := c.Object(ctx, srcContainer, srcObjectName)
+	if err != nil {
+		return
+	}
+	_, err = c.ObjectCopy(ctx, srcContainer, srcObjectName, dstContainer, dstObjectName, nil)
+	if err != nil {
+		return
+	}
+	dst, _, err := c.Object(ctx, dstContainer, dstObjectName)
+	if err != nil {
+		return
+	}
+	if src.Bytes != dst.Bytes || (!srcHeaders.IsLargeObject() && src.Hash != dst.Hash) {
+		// Don't delete the source if it was copied onto itself
+		if srcContainer != dstContainer || srcObjectName != dstObjectName {
+			_ = c.ObjectDelete(ctx, dstContainer, dstObjectName)
+		}
+		return ObjectCopyMismatch
+	}
+	return c.ObjectDelete(ctx, srcContainer, srcObjectName)
+}
+
 // ObjectUpdateContentType updates the content type of an object
 //
 // # This is a convenience method which calls ObjectCopy
@@ -2327,9 +4980,101 @@ func (c *Connection) VersionEnable(ctx context.Context, current, version string)
 	return nil
 }
 
+// VersioningMode selects how a container keeps old versions of its
+// objects.
+type VersioningMode int
+
+// Versioning modes supported by VersionEnableMode
+const (
+	// VersioningStack keeps old versions in a separate container
+	// using X-Versions-Location. Deleting an object restores the
+	// previous version.
+	VersioningStack VersioningMode = iota
+	// VersioningHistory keeps old versions in a separate container
+	// using X-History-Location. Deleting an object copies it to the
+	// version container so every version is kept.
+	VersioningHistory
+	// VersioningObject uses the object versioning middleware with
+	// X-Versions-Enabled. Swift manages the versions itself so no
+	// version container is needed.
+	VersioningObject
+)
+
+// VersionEnableMode enables versioning on the current container using
+// the mode given.
+//
+// version is the tracking container and is ignored for
+// VersioningObject.
+func (c *Connection) VersionEnableMode(ctx context.Context, current, version string, mode VersioningMode) error {
+	switch mode {
+	case VersioningStack:
+		return c.VersionEnable(ctx, current, version)
+	case VersioningHistory:
+		return c.VersionEnableHistory(ctx, current, version)
+	case VersioningObject:
+		return c.VersionEnableObjects(ctx, current)
+	}
+	return newErrorf(0, "unknown versioning mode %d", mode)
+}
+
+// VersionEnableHistory enables versioning on the current container
+// with version as the tracking container using X-History-Location.
+//
+// May return Forbidden if this isn't supported by the server
+func (c *Connection) VersionEnableHistory(ctx context.Context, current, version string) error {
+	h := Headers{"X-History-Location": version}
+	if err := c.ContainerUpdate(ctx, current, h); err != nil {
+		return err
+	}
+	// Check to see if the header was set properly
+	_, headers, err := c.Container(ctx, current)
+	if err != nil {
+		return err
+	}
+	// If failed to set history header, return Forbidden as the server doesn't support this
+	if headers["X-History-Location"] != version {
+		return Forbidden
+	}
+	return nil
+}
+
+// VersionEnableObjects enables object versioning on the current
+// container by setting X-Versions-Enabled.
+//
+// Returns VersioningNotSupported if the server doesn't advertise
+// object_versioning in its /info.
+func (c *Connection) VersionEnableObjects(ctx context.Context, current string) error {
+	return c.versionSetObjects(ctx, current, true)
+}
+
+// VersionDisableObjects suspends object versioning on the current
+// container. Versions already stored are kept.
+//
+// Returns VersioningNotSupported if the server doesn't advertise
+// object_versioning in its /info.
+func (c *Connection) VersionDisableObjects(ctx context.Context, current string) error {
+	return c.versionSetObjects(ctx, current, false)
+}
+
+// versionSetObjects sets X-Versions-Enabled on the current container
+func (c *Connection) versionSetObjects(ctx context.Context, current string, enabled bool) error {
+	info, err := c.cachedQueryInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if !info.SupportsObjectVersioning() {
+		return VersioningNotSupported
+	}
+	h := Headers{"X-Versions-Enabled": strconv.FormatBool(enabled)}
+	return c.ContainerUpdate(ctx, current, h)
+}
+
 // VersionDisable disables versioning on the current container.
+//
+// This removes both X-Versions-Location and X-History-Location - use
+// VersionDisableObjects for containers using VersioningObject.
 func (c *Connection) VersionDisable(ctx context.Context, current string) error {
-	h := Headers{"X-Versions-Location": ""}
+	h := Headers{"X-Versions-Location": "", "X-History-Location": ""}
 	if err := c.ContainerUpdate(ctx, current, h); err != nil {
 		return err
 	}
@@ -2347,6 +5092,124 @@ func (c *Connection) VersionObjectList(ctx context.Context, version, object stri
 	return c.ObjectNames(ctx, version, opts)
 }
 
+// ObjectVersion is one version of an object stored by the object
+// versioning middleware as returned by ObjectVersions.
+type ObjectVersion struct {
+	Name               string    `json:"name"`          // object name
+	VersionId          string    `json:"version_id"`    // id to pass as version-id to read this version
+	IsLatest           bool      `json:"is_latest"`     // set if this is the current version
+	ContentType        string    `json:"content_type"`  // eg application/octet-stream or application/x-deleted;swift_versions_deleted=1 for a delete marker
+	Bytes              int64     `json:"bytes"`         // size in bytes
+	Hash               string    `json:"hash"`          // MD5 hash, eg "d41d8cd98f00b204e9800998ecf8427e"
+	ServerLastModified string    `json:"last_modified"` // Last modified time, eg '2011-06-30T08:20:47.736680' as a string supplied by the server
+	LastModified       time.Time // Last modified time converted to a time.Time
+}
+
+// ObjectVersions returns all the versions of object in a container
+// with object versioning enabled (see VersionEnableObjects), newest
+// first as listed by the server.
+//
+// Returns VersioningNotSupported if the server doesn't advertise
+// object_versioning in its /info.
+func (c *Connection) ObjectVersions(ctx context.Context, container, object string) ([]ObjectVersion, error) {
+	info, err := c.cachedQueryInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !info.SupportsObjectVersioning() {
+		return nil, VersioningNotSupported
+	}
+	v := url.Values{}
+	v.Set("versions", "")
+	v.Set("format", "json")
+	v.Set("prefix", object)
+	v.Set("limit", strconv.Itoa(allObjectsChanLimit))
+	var versions []ObjectVersion
+	for {
+		resp, _, err := c.storage(ctx, RequestOpts{
+			Container:  container,
+			Operation:  "GET",
+			Parameters: v,
+			ErrorMap:   ContainerErrorMap,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var page []ObjectVersion
+		err = readJson(resp, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range page {
+			// The prefix matches other objects starting with the same name
+			if version.Name != object {
+				continue
+			}
+			if version.ServerLastModified != "" {
+				version.LastModified, err = parseServerLastModified(version.ServerLastModified)
+				if err != nil {
+					return nil, err
+				}
+			}
+			versions = append(versions, version)
+		}
+		if c.isLastPage(len(page), allObjectsChanLimit) {
+			break
+		}
+		last := page[len(page)-1]
+		if last.Name > object {
+			break
+		}
+		v.Set("marker", last.Name)
+		v.Set("version_marker", last.VersionId)
+	}
+	return versions, nil
+}
+
+// ObjectDeleteVersion permanently deletes one version of an object
+// in a container with object versioning enabled.
+//
+// versionID is the VersionId from ObjectVersions.
+//
+// May return ObjectNotFound if the version isn't found
+func (c *Connection) ObjectDeleteVersion(ctx context.Context, container, objectName, versionID string) error {
+	v := url.Values{}
+	v.Set("version-id", versionID)
+	_, _, err := c.storage(ctx, RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  "DELETE",
+		Parameters: v,
+		ErrorMap:   ObjectErrorMap,
+		NoResponse: true,
+	})
+	return err
+}
+
+// ObjectRestoreVersion makes an old version of an object the current
+// one by copying it over the current object in a container with object
+// versioning enabled. The version copied over is kept as a version.
+//
+// versionID is the VersionId from ObjectVersions.
+//
+// May return ObjectNotFound if the version isn't found
+func (c *Connection) ObjectRestoreVersion(ctx context.Context, container, objectName, versionID string) error {
+	v := url.Values{}
+	v.Set("version-id", versionID)
+	_, _, err := c.storage(ctx, RequestOpts{
+		Container:  container,
+		ObjectName: objectName,
+		Operation:  "COPY",
+		Parameters: v,
+		ErrorMap:   ObjectErrorMap,
+		NoResponse: true,
+		Headers: Headers{
+			"Destination": urlPathEscape(container + "/" + objectName),
+		},
+	})
+	return err
+}
+
 // GetStorageUrl returns Swift storage URL.
 func (c *Connection) GetStorageUrl(ctx context.Context) (string, error) {
 	c.authLock.Lock()
@@ -2361,3 +5224,34 @@ func (c *Connection) GetStorageUrl(ctx context.Context) (string, error) {
 	}
 	return c.StorageUrl, nil
 }
+
+// EndpointUrl returns the URL of the service of serviceType, eg
+// "object-cdn", from the catalog returned by the auth server,
+// authenticating first if necessary.  The endpoint is chosen using
+// EndpointType and Internal in the same way as the StorageUrl.
+//
+// It returns "" if the service isn't in the catalog or the auth
+// version in use doesn't return a catalog (v1 auth).
+func (c *Connection) EndpointUrl(ctx context.Context, serviceType string) (string, error) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+
+	if c.Auth == nil || c.StorageUrl == "" {
+		err := c.authenticate(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	endpointUrler, ok := c.Auth.(EndpointUrler)
+	if !ok {
+		return "", nil
+	}
+	endpointType := c.EndpointType
+	if endpointType == "" {
+		endpointType = EndpointTypePublic
+		if c.Internal {
+			endpointType = EndpointTypeInternal
+		}
+	}
+	return endpointUrler.EndpointUrl(serviceType, endpointType), nil
+}
@@ -6,13 +6,17 @@
 package swift
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -279,7 +283,7 @@ func TestInternalParseHeaders(t *testing.T) {
 	if c.parseHeaders(resp, ContainerErrorMap) != ContainerNotFound {
 		t.Error("Bad 1")
 	}
-	if c.parseHeaders(resp, objectErrorMap) != ObjectNotFound {
+	if c.parseHeaders(resp, ObjectErrorMap) != ObjectNotFound {
 		t.Error("Bad 1")
 	}
 }
@@ -302,6 +306,37 @@ func TestInternalReadHeaders(t *testing.T) {
 	compareMaps(t, readHeaders(resp), Headers{"one": "1", "two": "2"})
 }
 
+func TestInternalTrimEtag(t *testing.T) {
+	const md5 = "d41d8cd98f00b204e9800998ecf8427e"
+	for _, test := range []string{
+		md5,
+		`"` + md5 + `"`,
+		`W/"` + md5 + `"`,
+		` W/"` + md5 + `" `,
+	} {
+		if got := trimEtag(test); got != md5 {
+			t.Errorf("trimEtag(%q) want %q got %q", test, md5, got)
+		}
+		if !etagMatches(test, md5) {
+			t.Errorf("etagMatches(%q) should match", test)
+		}
+	}
+	if !etagMatches(`W/"D41D8CD98F00B204E9800998ECF8427E"`, md5) {
+		t.Error("etagMatches should ignore case")
+	}
+	if etagMatches(`W/"00000000000000000000000000000000"`, md5) {
+		t.Error("etagMatches should not match a different hash")
+	}
+	if etagMatches("", md5) {
+		t.Error("etagMatches should not match an empty ETag")
+	}
+
+	resp := &http.Response{Header: http.Header{
+		"Etag": []string{`W/"` + md5 + `"`},
+	}}
+	compareMaps(t, readHeaders(resp), Headers{"Etag": md5})
+}
+
 func TestInternalStorage(t *testing.T) {
 	// FIXME
 }
@@ -340,6 +375,9 @@ func TestInternalAuthenticateDenied(t *testing.T) {
 	server.AddCheck(t).Error(401, "DENIED")
 	defer server.Finished()
 	c.UnAuthenticate()
+	// v1 auth is only retried if asked
+	c.ReauthOn401 = true
+	defer func() { c.ReauthOn401 = false }()
 	err := c.Authenticate(context.Background())
 	if err != AuthorizationFailed {
 		t.Fatal("Expecting AuthorizationFailed", err)
@@ -350,6 +388,16 @@ func TestInternalAuthenticateDenied(t *testing.T) {
 	// }
 }
 
+func TestInternalAuthenticateDeniedNoRetry(t *testing.T) {
+	server.AddCheck(t).Error(401, "DENIED")
+	defer server.Finished()
+	c.UnAuthenticate()
+	err := c.Authenticate(context.Background())
+	if err != AuthorizationFailed {
+		t.Fatal("Expecting AuthorizationFailed", err)
+	}
+}
+
 func TestInternalAuthenticateBad(t *testing.T) {
 	server.AddCheck(t).Out(Headers{
 		"X-Storage-Url": PROXY_URL,
@@ -417,6 +465,39 @@ func TestInternalContainerNames(t *testing.T) {
 	testContainerNames(t, "one\ntwo\nthree\n", []string{"one", "two", "three"})
 }
 
+func TestInternalContainers(t *testing.T) {
+	server.AddCheck(t).In(Headers{
+		"User-Agent":   DefaultUserAgent,
+		"X-Auth-Token": AUTH_TOKEN,
+	}).Tx(`[
+		{"name": "one", "count": 1, "bytes": 10, "last_modified": "2012-11-11T14:49:47.887250", "storage_policy": "gold"},
+		{"name": "two", "count": 2, "bytes": 20}
+	]`).Url("/proxy?format=json")
+	defer server.Finished()
+	containers, err := c.Containers(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Container{
+		{
+			Name:               "one",
+			Count:              1,
+			Bytes:              10,
+			ServerLastModified: "2012-11-11T14:49:47.887250",
+			LastModified:       time.Date(2012, 11, 11, 14, 49, 47, 0, time.UTC),
+			StoragePolicy:      "gold",
+		},
+		{
+			Name:  "two",
+			Count: 2,
+			Bytes: 20,
+		},
+	}
+	if !reflect.DeepEqual(containers, expected) {
+		t.Errorf("Bad containers\nwant %#v\ngot  %#v", expected, containers)
+	}
+}
+
 func TestInternalObjectPutBytes(t *testing.T) {
 	server.AddCheck(t).In(Headers{
 		"User-Agent":     DefaultUserAgent,
@@ -769,3 +850,511 @@ func testPaging(t *testing.T, conn *Connection, testCases []pagingTest) {
 		}
 	}
 }
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(2, time.Hour)
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("Expecting the first two retries to be allowed")
+	}
+	if b.Allow() {
+		t.Fatal("Expecting the budget to be spent")
+	}
+
+	// Pretend half the refill period has passed
+	b.mu.Lock()
+	b.last = b.last.Add(-30 * time.Minute)
+	b.mu.Unlock()
+	if !b.Allow() {
+		t.Fatal("Expecting a retry to be allowed after a refill")
+	}
+	if b.Allow() {
+		t.Fatal("Expecting the budget to be spent again")
+	}
+
+	// Refilling never goes over the maximum
+	b.mu.Lock()
+	b.last = b.last.Add(-24 * time.Hour)
+	b.mu.Unlock()
+	allowed := 0
+	for b.Allow() {
+		allowed++
+	}
+	if allowed != 2 {
+		t.Errorf("Expecting 2 retries after a long wait got %d", allowed)
+	}
+
+	c := &Connection{}
+	if !c.retryAllowed() {
+		t.Error("Expecting retries to be allowed without a budget")
+	}
+	c.RetryBudget = b
+	if c.retryAllowed() {
+		t.Error("Expecting retries to be refused with a spent budget")
+	}
+}
+
+func TestEffectiveAuthVersion(t *testing.T) {
+	for _, test := range []struct {
+		authUrl     string
+		authVersion int
+		want        int
+	}{
+		{"https://auth.example.com/v1.0", 0, 1},
+		{"https://auth.example.com/v2.0", 0, 2},
+		{"https://auth.example.com/v3", 0, 3},
+		{"https://auth.example.com/v3", 2, 2},
+		{"https://auth.example.com/auth", 1, 1},
+	} {
+		c := &Connection{AuthUrl: test.authUrl, AuthVersion: test.authVersion}
+		if got := c.EffectiveAuthVersion(); got != 0 {
+			t.Errorf("%s: want 0 before authenticating got %d", test.authUrl, got)
+		}
+		auth, err := newAuth(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Auth = auth
+		if got := c.EffectiveAuthVersion(); got != test.want {
+			t.Errorf("%s (AuthVersion %d): want %d got %d", test.authUrl, test.authVersion, test.want, got)
+		}
+	}
+}
+
+func TestInfoUrlFromStorageUrl(t *testing.T) {
+	for _, test := range []struct {
+		storageUrl string
+		want       string
+	}{
+		{"https://host/v1/AUTH_account", "https://host/info"},
+		{"https://host/v1/AUTH_account/", "https://host/info"},
+		{"https://host/swift/v1/AUTH_account", "https://host/swift/info"},
+		{"https://host/swift/v1", "https://host/swift/info"},
+		{"https://host:8080/v1.0", "https://host:8080/info"},
+		{"https://host/a/b/c", "https://host/a/info"},
+		{"https://host/", "https://host/info"},
+	} {
+		got, err := infoUrlFromStorageUrl(test.storageUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("infoUrlFromStorageUrl(%q) want %q got %q", test.storageUrl, test.want, got)
+		}
+	}
+}
+
+func TestSwiftInfoCapabilities(t *testing.T) {
+	var info SwiftInfo
+	err := json.Unmarshal([]byte(`{
+		"swift": {
+			"max_file_size": 5368709122,
+			"max_container_name_length": 256,
+			"max_object_name_length": 1024,
+			"container_listing_limit": 10000,
+			"account_listing_limit": 5000
+		},
+		"symlink": {"static_links": true},
+		"versioned_writes": {}
+	}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.MaxFileSize(); got != 5368709122 {
+		t.Errorf("MaxFileSize want 5368709122 got %d", got)
+	}
+	if got := info.MaxContainerNameLength(); got != 256 {
+		t.Errorf("MaxContainerNameLength want 256 got %d", got)
+	}
+	if got := info.MaxObjectNameLength(); got != 1024 {
+		t.Errorf("MaxObjectNameLength want 1024 got %d", got)
+	}
+	if got := info.ContainerListingLimit(); got != 10000 {
+		t.Errorf("ContainerListingLimit want 10000 got %d", got)
+	}
+	if got := info.AccountListingLimit(); got != 5000 {
+		t.Errorf("AccountListingLimit want 5000 got %d", got)
+	}
+	if !info.SupportsSymlinks() || !info.SupportsStaticSymlinks() || !info.SupportsVersionedWrites() {
+		t.Errorf("capabilities not detected in %v", info)
+	}
+
+	// Absent values give zero values
+	info = SwiftInfo{"symlink": map[string]interface{}{}}
+	if info.MaxFileSize() != 0 || info.ContainerListingLimit() != 0 || info.AccountListingLimit() != 0 {
+		t.Error("expecting zero limits")
+	}
+	if !info.SupportsSymlinks() || info.SupportsStaticSymlinks() || info.SupportsVersionedWrites() {
+		t.Errorf("wrong capabilities detected in %v", info)
+	}
+}
+
+func TestInternalHeadersObjectType(t *testing.T) {
+	for _, test := range []struct {
+		headers Headers
+		want    ObjectType
+	}{
+		{Headers{}, RegularObjectType},
+		{Headers{"X-Object-Manifest": "segments/prefix"}, DynamicLargeObjectType},
+		{Headers{"X-Static-Large-Object": "True"}, StaticLargeObjectType},
+		{Headers{"X-Symlink-Target": "container/object"}, SymlinkObjectType},
+	} {
+		if got := test.headers.ObjectType(); got != test.want {
+			t.Errorf("%v: want %d got %d", test.headers, test.want, got)
+		}
+	}
+}
+
+func TestInternalStoragePolicies(t *testing.T) {
+	var info SwiftInfo
+	err := json.Unmarshal([]byte(`{"swift": {"policies": [
+		{"name": "Policy-0", "aliases": "Policy-0", "default": true},
+		{"name": "gold", "aliases": "gold, ssd"}
+	]}}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StoragePolicy{
+		{Name: "Policy-0", Aliases: []string{"Policy-0"}, Default: true},
+		{Name: "gold", Aliases: []string{"gold", "ssd"}},
+	}
+	if got := info.StoragePolicies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	if got := (SwiftInfo{}).StoragePolicies(); got != nil {
+		t.Errorf("want nil got %+v", got)
+	}
+}
+
+func TestInternalCommonPrefix(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"abc", "abd", "ab"},
+		{"abc", "abc", "abc"},
+		{"ab", "abc", "ab"},
+		{"x", "y", ""},
+		{"dir/\u00e9", "dir/\u00e8", "dir/"},
+	} {
+		if got := commonPrefix(test.a, test.b); got != test.want {
+			t.Errorf("commonPrefix(%q, %q) want %q got %q", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestInternalReadJsonArray(t *testing.T) {
+	stop := errors.New("stop")
+	for _, test := range []struct {
+		body    string
+		stopAt  int
+		want    []int
+		wantErr bool
+	}{
+		{"[1, 2, 3]", -1, []int{1, 2, 3}, false},
+		{"[]", -1, nil, false},
+		{"null", -1, nil, false},
+		{"[1, 2, 3]", 1, []int{1, 2}, true},
+		{`{"a": 1}`, -1, nil, true},
+		{"[1, 2", -1, []int{1, 2}, true},
+	} {
+		resp := &http.Response{Body: io.NopCloser(strings.NewReader(test.body))}
+		var got []int
+		err := readJsonArray(resp, func(decoder *json.Decoder) error {
+			var i int
+			if err := decoder.Decode(&i); err != nil {
+				return err
+			}
+			got = append(got, i)
+			if len(got)-1 == test.stopAt {
+				return stop
+			}
+			return nil
+		})
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: want error %v got %v", test.body, test.wantErr, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: want %v got %v", test.body, test.want, got)
+		}
+	}
+}
+
+func TestInternalRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 30 * time.Second},
+		{"Wed, 21 Oct 2015 07:27:00 GMT", 0},
+		{"soon", 0},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if test.header != "" {
+			resp.Header.Set("Retry-After", test.header)
+		}
+		now := time.Date(2015, 10, 21, 7, 27, 30, 0, time.UTC)
+		if got := retryAfter(resp, now); got != test.want {
+			t.Errorf("%q: want %v got %v", test.header, test.want, got)
+		}
+	}
+}
+
+func TestInternalRetryBackoff(t *testing.T) {
+	for _, test := range []struct {
+		previous int
+		want     time.Duration
+	}{
+		{0, minRetryBackoff},
+		{1, 2 * minRetryBackoff},
+		{3, 8 * minRetryBackoff},
+		{10, maxRetryBackoff},
+		{1000, maxRetryBackoff},
+	} {
+		if got := retryBackoff(test.previous); got != test.want {
+			t.Errorf("%d: want %v got %v", test.previous, test.want, got)
+		}
+	}
+}
+
+// stalledServer accepts connections but never responds on them. It
+// returns the address and a channel which receives each accepted
+// connection once the client has closed it.
+func stalledServer(t *testing.T) (addr string, closed <-chan struct{}) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	closedChan := make(chan struct{}, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(io.Discard, conn)
+				closedChan <- struct{}{}
+			}()
+		}
+	}()
+	return l.Addr().String(), closedChan
+}
+
+func testStalledServer(t *testing.T, scheme string) {
+	addr, closed := stalledServer(t)
+	c := &Connection{
+		UserName:       USERNAME,
+		ApiKey:         APIKEY,
+		AuthUrl:        scheme + "://" + addr + "/v1.0",
+		ConnectTimeout: 100 * time.Millisecond,
+		Timeout:        time.Minute,
+	}
+	start := time.Now()
+	err := c.Authenticate(context.Background())
+	if err == nil {
+		t.Fatal("Expecting error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Took %v to time out", elapsed)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Connection wasn't closed after timing out")
+	}
+}
+
+func TestInternalConnectTimeoutNoResponse(t *testing.T) {
+	testStalledServer(t, "http")
+}
+
+func TestInternalConnectTimeoutTLSHandshake(t *testing.T) {
+	testStalledServer(t, "https")
+}
+
+func TestInternalConnectTimeoutTransport(t *testing.T) {
+	c := &Connection{ConnectTimeout: 7 * time.Second}
+	c.setDefaults()
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expecting *http.Transport got %T", c.Transport)
+	}
+	if tr.TLSHandshakeTimeout != c.ConnectTimeout {
+		t.Errorf("Expecting TLSHandshakeTimeout %v got %v", c.ConnectTimeout, tr.TLSHandshakeTimeout)
+	}
+	if tr.DialContext == nil {
+		t.Error("Expecting DialContext to be set")
+	}
+}
+
+func TestInternalObjectPutKnownLength(t *testing.T) {
+	server.AddCheck(t).In(Headers{
+		"User-Agent":     DefaultUserAgent,
+		"X-Auth-Token":   AUTH_TOKEN,
+		"Content-Length": "5",
+		"Content-Type":   "text/plain",
+	}).Rx("12345")
+	defer server.Finished()
+	_, err := c.ObjectPut(context.Background(), "container", "object", strings.NewReader("12345"), false, "", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInternalKnownLength(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "known-length")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err = f.WriteString("0123456789"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	partial := strings.NewReader("0123456789")
+	_, _ = partial.Seek(3, io.SeekStart)
+	for _, test := range []struct {
+		name   string
+		r      io.Reader
+		wantN  int64
+		wantOK bool
+	}{
+		{"bytes.Reader", bytes.NewReader([]byte("12345")), 5, true},
+		{"bytes.Buffer", bytes.NewBufferString("1234"), 4, true},
+		{"strings.Reader", strings.NewReader("123"), 3, true},
+		{"partial strings.Reader", partial, 7, true},
+		{"os.File", f, 6, true},
+		{"other", io.MultiReader(strings.NewReader("12")), 0, false},
+	} {
+		n, ok := knownLength(test.r)
+		if n != test.wantN || ok != test.wantOK {
+			t.Errorf("%s: want %d, %v got %d, %v", test.name, test.wantN, test.wantOK, n, ok)
+		}
+	}
+}
+
+func TestInternalObjectsPastEndMarker(t *testing.T) {
+	for _, test := range []struct {
+		opts   ObjectsOpts
+		marker string
+		want   bool
+	}{
+		{ObjectsOpts{}, "b", false},
+		{ObjectsOpts{EndMarker: "c"}, "", false},
+		{ObjectsOpts{EndMarker: "c"}, "b", false},
+		{ObjectsOpts{EndMarker: "c"}, "c", true},
+		{ObjectsOpts{EndMarker: "c"}, "d", true},
+		{ObjectsOpts{EndMarker: "c", Reverse: true}, "d", false},
+		{ObjectsOpts{EndMarker: "c", Reverse: true}, "c", true},
+		{ObjectsOpts{EndMarker: "c", Reverse: true}, "b", true},
+	} {
+		if got := test.opts.pastEndMarker(test.marker); got != test.want {
+			t.Errorf("%+v %q: want %v got %v", test.opts, test.marker, test.want, got)
+		}
+	}
+}
+
+// Paging stops at the EndMarker even if the server doesn't
+func TestInternalObjectNamesAllEndMarker(t *testing.T) {
+	server.AddCheck(t).In(Headers{
+		"User-Agent":   DefaultUserAgent,
+		"X-Auth-Token": AUTH_TOKEN,
+	}).Tx("a/1\na/2\n").Url("/proxy/container?end_marker=a%2F4&limit=2&prefix=a%2F")
+	server.AddCheck(t).In(Headers{
+		"User-Agent":   DefaultUserAgent,
+		"X-Auth-Token": AUTH_TOKEN,
+	}).Tx("a/3\na/4\n").Url("/proxy/container?end_marker=a%2F4&limit=2&marker=a%2F2&prefix=a%2F")
+	defer server.Finished()
+	names, err := c.ObjectNamesAll(context.Background(), "container", &ObjectsOpts{Prefix: "a/", EndMarker: "a/4", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/1", "a/2", "a/3", "a/4"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("want %q got %q", want, names)
+	}
+}
+
+func TestInternalAccountStorageUrl(t *testing.T) {
+	for _, test := range []struct {
+		storageUrl string
+		account    string
+		want       string
+		wantErr    bool
+	}{
+		{"https://host/v1/AUTH_a", "AUTH_b", "https://host/v1/AUTH_b", false},
+		{"https://host:8080/swift/v1/AUTH_a/", "AUTH_b", "https://host:8080/swift/v1/AUTH_b", false},
+		{"https://host/v1/AUTH_a", "", "", true},
+		{"https://host/v1/AUTH_a", "AUTH_b/c", "", true},
+	} {
+		got, err := accountStorageUrl(test.storageUrl, test.account)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("%q, %q: want %q, %v got %q, %v", test.storageUrl, test.account, test.want, test.wantErr, got, err)
+		}
+	}
+}
+
+// failingLargeObjectFile fails every Write and counts the Closes
+type failingLargeObjectFile struct {
+	LargeObjectFile
+	closes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (f *failingLargeObjectFile) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func (f *failingLargeObjectFile) Size() int64 {
+	return 0
+}
+
+func (f *failingLargeObjectFile) CloseWithContext(ctx context.Context) error {
+	f.closes++
+	return nil
+}
+
+func TestInternalBufferedLargeObjectFileFlushFails(t *testing.T) {
+	ctx := context.Background()
+	lo := &failingLargeObjectFile{}
+	blo := withBuffer(&LargeObjectOpts{ChunkSize: 16}, lo)
+	_, err := blo.Write([]byte("12345"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closing again must fail too rather than writing a manifest
+	// without the buffered data
+	for i := 0; i < 2; i++ {
+		err = blo.CloseWithContext(ctx)
+		if err != errWriteFailed {
+			t.Errorf("Close %d: want %v got %v", i, errWriteFailed, err)
+		}
+	}
+	if lo.closes != 0 {
+		t.Errorf("Expecting the large object not to be closed but it was closed %d times", lo.closes)
+	}
+}
+
+func TestInternalBufferedLargeObjectFileWriteAfterClose(t *testing.T) {
+	ctx := context.Background()
+	lo := &failingLargeObjectFile{}
+	blo := withBuffer(&LargeObjectOpts{ChunkSize: 16}, lo)
+	err := blo.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = blo.Write([]byte("12345"))
+	if swiftErr, ok := err.(*Error); !ok || swiftErr.StatusCode != 0 {
+		t.Errorf("Expecting a local error got %#v", err)
+	}
+}
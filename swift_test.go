@@ -15,21 +15,28 @@ package swift_test
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -49,6 +56,7 @@ const (
 	SEGMENTS_CONTAINER = "GoSwiftUnitTest_segments"
 	VERSIONS_CONTAINER = "GoSwiftUnitTestVersions"
 	CURRENT_CONTAINER  = "GoSwiftUnitTestCurrent"
+	COPY_CONTAINER     = "GoSwiftUnitTestCopy"
 	OBJECT             = "test_object"
 	OBJECT2            = "test_object2"
 	SYMLINK_OBJECT     = "test_symlink"
@@ -346,6 +354,384 @@ func TestTransport(t *testing.T) {
 	}
 }
 
+// recordingTransport counts the requests made through it
+type recordingTransport struct {
+	http.RoundTripper
+	mu       sync.Mutex
+	requests []string
+}
+
+func (tr *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.requests = append(tr.requests, req.Method+" "+req.URL.Path)
+	tr.mu.Unlock()
+	return tr.RoundTripper.RoundTrip(req)
+}
+
+func TestHTTPClient(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnection(t)
+	defer rollback()
+
+	tr := &recordingTransport{RoundTripper: c.Transport}
+	c.Transport = nil
+	c.HTTPClient = &http.Client{Transport: tr}
+
+	err := c.Authenticate(ctx)
+	if err != nil {
+		t.Fatal("Auth failed", err)
+	}
+	_, err = c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.requests) < 2 {
+		t.Errorf("Expecting auth and listing through the client, got %q", tr.requests)
+	}
+	if c.Transport != tr {
+		t.Errorf("Expecting Transport to default to the client's Transport")
+	}
+}
+
+// userAgentTransport records the User-Agent of the requests made
+// through it
+type userAgentTransport struct {
+	http.RoundTripper
+	mu         sync.Mutex
+	userAgents map[string]string
+}
+
+func (tr *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.userAgents[req.Method+" "+req.URL.Path] = req.Header.Get("User-Agent")
+	tr.mu.Unlock()
+	return tr.RoundTripper.RoundTrip(req)
+}
+
+func TestUserAgent(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnection(t)
+	defer rollback()
+
+	const userAgent = "goswift-test/1.0"
+	tr := &userAgentTransport{RoundTripper: c.Transport, userAgents: map[string]string{}}
+	c.Transport = tr
+	c.UserAgent = userAgent
+
+	err := c.Authenticate(ctx)
+	if err != nil {
+		t.Fatal("Auth failed", err)
+	}
+	_, err = c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.QueryInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.userAgents) < 3 {
+		t.Errorf("Expecting auth, listing and info requests, got %q", tr.userAgents)
+	}
+	for request, got := range tr.userAgents {
+		if got != userAgent {
+			t.Errorf("%s: want User-Agent %q got %q", request, userAgent, got)
+		}
+	}
+}
+
+// headerTransport records the given header of the requests made
+// through it
+type headerTransport struct {
+	http.RoundTripper
+	header string
+	mu     sync.Mutex
+	values []string
+}
+
+func (tr *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.values = append(tr.values, strings.Join(req.Header.Values(tr.header), ","))
+	tr.mu.Unlock()
+	return tr.RoundTripper.RoundTrip(req)
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnection(t)
+	defer rollback()
+
+	tr := &headerTransport{RoundTripper: c.Transport, header: "X-Trace-Id"}
+	c.Transport = tr
+	c.DefaultHeaders = swift.Headers{
+		"x-trace-id":   "default",
+		"X-Auth-Token": "bogus",
+		"user-agent":   "bogus",
+	}
+	err := c.Authenticate(ctx)
+	if err != nil {
+		t.Fatal("Auth failed", err)
+	}
+	tr.mu.Lock()
+	tr.values = nil
+	tr.mu.Unlock()
+
+	// Auth headers can't be overridden
+	_, _, err = c.Account(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = c.Call(ctx, c.StorageUrl, swift.RequestOpts{
+		Operation:  "HEAD",
+		Headers:    swift.Headers{"X-Trace-Id": "call"},
+		NoResponse: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Header names are matched whatever their case
+	_, _, err = c.Call(ctx, c.StorageUrl, swift.RequestOpts{
+		Operation:  "HEAD",
+		Headers:    swift.Headers{"X-TRACE-ID": "upper"},
+		NoResponse: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if want := []string{"default", "call", "upper"}; !reflect.DeepEqual(tr.values, want) {
+		t.Errorf("want X-Trace-Id %q got %q", want, tr.values)
+	}
+}
+
+// failingTransport fails every storage GET once fail is set
+type failingTransport struct {
+	http.RoundTripper
+	mu       sync.Mutex
+	fail     bool
+	attempts int
+}
+
+func (tr *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.fail && req.Method == "GET" {
+		tr.attempts++
+		return nil, errors.New("simulated network failure")
+	}
+	return tr.RoundTripper.RoundTrip(req)
+}
+
+func TestRetryBudgetCall(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnection(t)
+	defer rollback()
+
+	tr := &failingTransport{RoundTripper: c.Transport}
+	c.Transport = nil
+	c.HTTPClient = &http.Client{Transport: tr}
+	c.Retries = 3
+	c.RetryBudget = swift.NewRetryBudget(2, time.Hour)
+
+	err := c.Authenticate(ctx)
+	if err != nil {
+		t.Fatal("Auth failed", err)
+	}
+	tr.mu.Lock()
+	tr.fail = true
+	tr.mu.Unlock()
+
+	// The first call spends the budget, the second can't retry at all
+	for i := 0; i < 2; i++ {
+		_, err = c.ContainerNames(ctx, nil)
+		if err == nil {
+			t.Fatal("Expecting an error")
+		}
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.attempts != 4 {
+		t.Errorf("Expecting 4 attempts (2 + 2 retries) got %d", tr.attempts)
+	}
+}
+
+func TestServerFailure(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to inject failures.")
+		return
+	}
+
+	accountURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT
+	srv.SetFailure(accountURL, 1, http.StatusServiceUnavailable)
+	_, err := c.ContainerNames(ctx, nil)
+	if swiftErr, ok := err.(*swift.Error); !ok || swiftErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expecting 503 error got %v", err)
+	}
+	_, err = c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Errorf("Expecting failure to have cleared got %v", err)
+	}
+
+	// A 401 makes the client reauthenticate and retry
+	srv.SetFailure(accountURL, 1, http.StatusUnauthorized)
+	_, err = c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Errorf("Expecting retry to succeed got %v", err)
+	}
+
+	srv.SetFailure(accountURL, 5, http.StatusInternalServerError)
+	srv.SetFailure(accountURL, 0, 0)
+	_, err = c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Errorf("Expecting failures to have been removed got %v", err)
+	}
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to inject failures.")
+		return
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+	}()
+	objectURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	is503 := func(err error) bool {
+		swiftErr, ok := err.(*swift.Error)
+		return ok && swiftErr.StatusCode == http.StatusServiceUnavailable
+	}
+
+	// Not retried by default
+	srv.SetFailure(objectURL, 1, http.StatusServiceUnavailable)
+	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, strings.NewReader(CONTENTS), true, "", "", nil)
+	if !is503(err) {
+		t.Errorf("Expecting 503 error got %v", err)
+	}
+
+	c.RetryTransientErrors = true
+	defer func() {
+		c.RetryTransientErrors = false
+	}()
+
+	// A seekable body is rewound to where it started and retried
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		body := strings.NewReader("xx" + CONTENTS)
+		_, _ = body.Seek(2, io.SeekStart)
+		srv.SetFailure(objectURL, 2, status)
+		_, err = c.ObjectPut(ctx, CONTAINER, OBJECT, body, true, "", "", nil)
+		if err != nil {
+			t.Fatalf("%d: Expecting retry to succeed got %v", status, err)
+		}
+		contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != CONTENTS {
+			t.Errorf("%d: Bad contents %q", status, contents)
+		}
+	}
+
+	// A body which can't be rewound isn't retried and says why
+	srv.SetFailure(objectURL, 1, http.StatusServiceUnavailable)
+	_, err = c.ObjectPut(ctx, CONTAINER, OBJECT, io.MultiReader(strings.NewReader(CONTENTS)), false, "", "", nil)
+	notRewindable, ok := err.(*swift.BodyNotRewindableError)
+	if !ok {
+		t.Fatalf("Expecting *swift.BodyNotRewindableError got %v", err)
+	}
+	if !is503(notRewindable.Err) {
+		t.Errorf("Expecting 503 error got %v", notRewindable.Err)
+	}
+	var swiftErr *swift.Error
+	if !errors.As(err, &swiftErr) || swiftErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expecting errors.As to find the 503 got %v", swiftErr)
+	}
+
+	// Without Retry-After the retries back off so the context
+	// expires while waiting rather than the retries being used up
+	srv.SetFailure(objectURL, 10, http.StatusTooManyRequests)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = c.ObjectPut(timeoutCtx, CONTAINER, OBJECT, strings.NewReader(CONTENTS), true, "", "", nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expecting context.DeadlineExceeded got %v", err)
+	}
+	srv.SetFailure(objectURL, 0, 0)
+}
+
+func TestLogger(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnection(t)
+	defer rollback()
+
+	var mu sync.Mutex
+	var lines []string
+	c.Logger = func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	_, err := c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) < 2 {
+		t.Fatalf("Expecting auth and listing to be logged got %q", lines)
+	}
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, "swift: GET "+c.StorageUrl) || !strings.Contains(last, ": 200 OK (") {
+		t.Errorf("Bad log line %q", last)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, c.AuthToken) {
+			t.Errorf("Auth token not redacted in %q", line)
+		}
+	}
+}
+
+func TestLoggerTempUrlKey(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	var mu sync.Mutex
+	var lines []string
+	c.Logger = func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	const key2 = "0123456789abcdef0123456789abcdef"
+	err := c.ContainerTempUrlKeySet(ctx, CONTAINER, SECRET_KEY, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) == 0 {
+		t.Fatal("Nothing logged")
+	}
+	for _, line := range lines {
+		if strings.Contains(line, SECRET_KEY) || strings.Contains(line, key2) {
+			t.Errorf("Temp URL key not redacted in %q", line)
+		}
+	}
+}
+
 // The following Test functions are run in order - this one must come before the others!
 func TestV1V2Authenticate(t *testing.T) {
 	ctx := context.Background()
@@ -362,6 +748,9 @@ func TestV1V2Authenticate(t *testing.T) {
 	if !c.Authenticated() {
 		t.Fatal("Not authenticated")
 	}
+	if v := c.EffectiveAuthVersion(); v != 1 && v != 2 {
+		t.Errorf("Expecting auth version 1 or 2 got %d", v)
+	}
 }
 
 func TestV3AuthenticateWithDomainNameAndTenantId(t *testing.T) {
@@ -500,6 +889,63 @@ func TestAuthenticateRace(t *testing.T) {
 	wg.Wait()
 }
 
+// Test requests re-authenticating concurrently are safe
+func TestReAuthenticateRace(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if i == 0 {
+					c.UnAuthenticate()
+					continue
+				}
+				_, _, err := c.Account(ctx)
+				if err != nil {
+					t.Error("Account failed", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Test only one request re-authenticates when the token expires under load
+func TestReAuthenticateSingleFlight(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to count authentications.")
+		return
+	}
+
+	for _, expire := range []func(){c.UnAuthenticate, srv.ExpireSessions} {
+		before := srv.AuthCount()
+		expire()
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, err := c.Account(ctx)
+				if err != nil {
+					t.Error("Account failed", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := srv.AuthCount() - before; got != 1 {
+			t.Errorf("want 1 authentication got %d", got)
+		}
+	}
+}
+
 // Test a connection can be serialized and unserialized with JSON
 func TestSerializeConnectionJson(t *testing.T) {
 	ctx := context.Background()
@@ -638,6 +1084,42 @@ func TestContainerCreate(t *testing.T) {
 	}
 }
 
+func TestContainerCreateWithPolicy(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	info, err := c.QueryInfo(ctx)
+	if err != nil {
+		t.Skip("Server doesn't support querying info")
+	}
+	var policy string
+	for _, p := range info.StoragePolicies() {
+		if !p.Default {
+			policy = p.Name
+		}
+	}
+	if policy == "" {
+		t.Skip("No non default storage policy")
+	}
+	err = c.ContainerCreateWithPolicy(ctx, CONTAINER, policy, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ContainerDelete(ctx, CONTAINER)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	container, _, err := c.Container(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(container.StoragePolicy, policy) {
+		t.Errorf("Bad storage policy want %q got %q", policy, container.StoragePolicy)
+	}
+}
+
 func TestContainer(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
@@ -702,387 +1184,506 @@ func TestContainersAllWithLimit(t *testing.T) {
 	}
 }
 
-func TestContainerUpdate(t *testing.T) {
+func TestContainerACLs(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ContainerUpdate(ctx, CONTAINER, m2.ContainerHeaders())
+	err := c.ContainerSetReadACL(ctx, CONTAINER, swift.JoinACL(swift.ACLPublicRead, swift.ACLListings))
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, headers, err := c.Container(ctx, CONTAINER)
+	err = c.ContainerSetWriteACL(ctx, CONTAINER, swift.ACLUser("test", "tester"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	compareMaps(t, headers.ContainerMetadata(), map[string]string{})
-}
-
-func TestContainerNames(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
-	defer rollback()
-	containers, err := c.ContainerNames(ctx, nil)
+	read, write, err := c.ContainerACLs(ctx, CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	ok := false
-	for _, container := range containers {
-		if container == CONTAINER {
-			ok = true
-			break
-		}
+	if want := []string{".r:*", ".rlistings"}; !reflect.DeepEqual(read, want) {
+		t.Errorf("Bad read ACL want %q got %q", want, read)
 	}
-	if !ok {
-		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
+	if want := []string{"test:tester"}; !reflect.DeepEqual(write, want) {
+		t.Errorf("Bad write ACL want %q got %q", want, write)
 	}
-}
 
-func TestContainerNamesAll(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
-	defer rollback()
-	containers1, err := c.ContainerNamesAll(ctx, nil)
+	err = c.ContainerSetReadACL(ctx, CONTAINER, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	containers2, err := c.ContainerNames(ctx, nil)
+	read, write, err = c.ContainerACLs(ctx, CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(containers1) != len(containers2) {
-		t.Fatal("Wrong length")
-	}
-	for i := range containers1 {
-		if containers1[i] != containers2[i] {
-			t.Fatal("Not the same")
-		}
+	if len(read) != 0 || len(write) != 1 {
+		t.Errorf("Expecting read ACL removed, got %q, %q", read, write)
 	}
 }
 
-func TestContainerNamesAllWithLimit(t *testing.T) {
+func TestExportImportMetadata(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	containers1, err := c.ContainerNamesAll(ctx, &swift.ContainersOpts{Limit: 1})
+	err := c.ContainerUpdate(ctx, CONTAINER, swift.Metadata{"colour": "blue"}.ContainerHeaders())
 	if err != nil {
 		t.Fatal(err)
 	}
-	containers2, err := c.ContainerNames(ctx, nil)
+	_, headers, err := c.Container(ctx, CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(containers1) != len(containers2) {
-		t.Fatal("Wrong length")
+	containerMetadata := headers.ContainerMetadata()
+	objects := map[string]swift.Metadata{
+		OBJECT:  {"author": "nick", "potato-salad": "2"},
+		OBJECT2: {},
 	}
-	for i := range containers1 {
-		if containers1[i] != containers2[i] {
-			t.Fatal("Not the same")
+	for name, metadata := range objects {
+		_, err = c.ObjectPut(ctx, CONTAINER, name, bytes.NewBufferString(CONTENTS), true, "", "", metadata.ObjectHeaders())
+		if err != nil {
+			t.Fatal(err)
 		}
+		defer func(name string) {
+			_ = c.ObjectDelete(ctx, CONTAINER, name)
+		}(name)
 	}
-}
 
-func TestObjectPutString(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
-	defer rollback()
-	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	var buf bytes.Buffer
+	err = c.ExportMetadata(ctx, CONTAINER, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Import onto fresh objects in a different container
+	err = c.ContainerCreate(ctx, CURRENT_CONTAINER, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		_ = c.ContainerDelete(ctx, CURRENT_CONTAINER)
+	}()
+	for name := range objects {
+		err = c.ObjectPutString(ctx, CURRENT_CONTAINER, name, CONTENTS, "")
 		if err != nil {
 			t.Fatal(err)
 		}
-	}()
+		defer func(name string) {
+			_ = c.ObjectDelete(ctx, CURRENT_CONTAINER, name)
+		}(name)
+	}
+	err = c.ImportMetadata(ctx, CURRENT_CONTAINER, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	_, headers, err = c.Container(ctx, CURRENT_CONTAINER)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "application/octet-stream" {
-		t.Error("Bad content type", info.ContentType)
+	if got := headers.ContainerMetadata(); !reflect.DeepEqual(got, containerMetadata) {
+		t.Errorf("Bad container metadata want %v got %v", containerMetadata, got)
 	}
-	if info.Bytes != CONTENT_SIZE {
-		t.Error("Bad length")
+	for name, want := range objects {
+		_, headers, err := c.Object(ctx, CURRENT_CONTAINER, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := headers.ObjectMetadata(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Bad object metadata want %v got %v", name, want, got)
+		}
 	}
-	if info.Hash != CONTENT_MD5 {
-		t.Error("Bad length")
+
+	// Importing onto a missing object should fail
+	err = c.ImportMetadata(ctx, CURRENT_CONTAINER, strings.NewReader(`{"objects": {"missing": {"a": "b"}}}`))
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
 }
 
-func TestObjectPut(t *testing.T) {
+func TestContainerQuota(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-
-	headers := swift.Headers{}
-
-	// Set content size incorrectly - should produce an error
-	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE-1, 10)
-	contents := bytes.NewBufferString(CONTENTS)
-	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
-	if err == nil {
-		t.Fatal("Expecting error but didn't get one")
+	err := c.ContainerSetQuota(ctx, CONTAINER, 1024, 10)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Now set content size correctly
-	contents = bytes.NewBufferString(CONTENTS)
-	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE, 10)
-	h, err := c.ObjectPut(ctx, CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
+	bytes, count, err := c.ContainerQuota(ctx, CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	if h["Etag"] != CONTENT_MD5 {
-		t.Errorf("Bad Etag want %q got %q", CONTENT_MD5, h["Etag"])
+	if bytes != 1024 || count != 10 {
+		t.Errorf("Bad quota want 1024, 10 got %d, %d", bytes, count)
 	}
-
-	// Fetch object info and compare
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	info, _, err := c.Container(ctx, CONTAINER)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "text/plain" {
-		t.Error("Bad content type", info.ContentType)
+	if info.QuotaBytes != 1024 || info.QuotaCount != 10 {
+		t.Errorf("Bad container info quota %d, %d", info.QuotaBytes, info.QuotaCount)
 	}
-	if info.Bytes != CONTENT_SIZE {
-		t.Error("Bad length")
+
+	err = c.ContainerSetQuota(ctx, CONTAINER, -1, 5)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if info.Hash != CONTENT_MD5 {
-		t.Error("Bad length")
+	bytes, count, err = c.ContainerQuota(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes != -1 || count != 5 {
+		t.Errorf("Bad quota want -1, 5 got %d, %d", bytes, count)
 	}
 }
 
-func TestObjectPutWithReauth(t *testing.T) {
+func TestAccountPolicies(t *testing.T) {
 	ctx := context.Background()
-	if !swift.IS_AT_LEAST_GO_16 {
-		return
-	}
-	c, rollback := makeConnectionWithContainer(t)
+	c, rollback := makeConnectionAuth(t)
 	defer rollback()
 
-	// Simulate that our auth token expired
-	c.AuthToken = "expiredtoken"
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to send per policy headers.")
+		return
+	}
 
-	r := strings.NewReader(CONTENTS)
-	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, r, false, "", "text/plain", nil)
+	info, _, err := c.Account(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if info.Policies != nil {
+		t.Errorf("Expecting no policies got %v", info.Policies)
+	}
 
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	accountURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT
+	srv.SetOverride(accountURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		w.Header().Set("X-Account-Storage-Policy-Gold-Bytes-Used", "1000")
+		w.Header().Set("X-Account-Storage-Policy-Gold-Container-Count", "2")
+		w.Header().Set("X-Account-Storage-Policy-Gold-Object-Count", "10")
+		w.Header().Set("X-Account-Storage-Policy-Ec-Cold-Bytes-Used", "5000")
+		w.Header().Set("X-Account-Storage-Policy-Ec-Cold-Container-Count", "1")
+		w.Header().Set("X-Account-Storage-Policy-Ec-Cold-Object-Count", "3")
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(accountURL)
+
+	info, _, err = c.Account(ctx)
 	if err != nil {
-		t.Error(err)
-	}
-	if info.ContentType != "text/plain" {
-		t.Error("Bad content type", info.ContentType)
+		t.Fatal(err)
 	}
-	if info.Bytes != CONTENT_SIZE {
-		t.Error("Bad length")
+	want := map[string]swift.PolicyUsage{
+		"Gold":    {BytesUsed: 1000, Containers: 2, Objects: 10},
+		"Ec-Cold": {BytesUsed: 5000, Containers: 1, Objects: 3},
 	}
-	if info.Hash != CONTENT_MD5 {
-		t.Error("Bad length")
+	if !reflect.DeepEqual(info.Policies, want) {
+		t.Errorf("Bad policies want %+v got %+v", want, info.Policies)
 	}
 }
 
-func TestObjectPutStringWithReauth(t *testing.T) {
+func TestAccountQuota(t *testing.T) {
 	ctx := context.Background()
-	if !swift.IS_AT_LEAST_GO_16 {
-		return
-	}
-	c, rollback := makeConnectionWithContainer(t)
+	c, rollback := makeConnectionAuth(t)
 	defer rollback()
 
-	// Simulate that our auth token expired
-	c.AuthToken = "expiredtoken"
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as setting account quotas needs a reseller admin.")
+		return
+	}
 
-	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	err := c.AccountSetQuota(ctx, 4096)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	bytes, err := c.AccountQuota(ctx)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "application/octet-stream" {
-		t.Error("Bad content type", info.ContentType)
+	if bytes != 4096 {
+		t.Errorf("Bad quota want 4096 got %d", bytes)
 	}
-	if info.Bytes != CONTENT_SIZE {
-		t.Error("Bad length")
+	err = c.AccountSetQuota(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if info.Hash != CONTENT_MD5 {
-		t.Error("Bad length")
+	bytes, err = c.AccountQuota(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes != -1 {
+		t.Errorf("Bad quota want -1 got %d", bytes)
 	}
 }
 
-func TestObjectEmpty(t *testing.T) {
+func TestContainersAllParallel(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(ctx, CONTAINER, EMPTYOBJECT, "", "")
+	for _, suffix := range []string{"-a", "-b", "-c", "-d", "-e"} {
+		err := c.ContainerCreate(ctx, CONTAINER+suffix, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			_ = c.ContainerDelete(ctx, name)
+		}(CONTAINER + suffix)
+	}
+	opts := &swift.ContainersOpts{Prefix: CONTAINER, Limit: 2}
+	serial, err := c.ContainersAll(ctx, opts)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, EMPTYOBJECT)
-		if err != nil {
-			t.Error(err)
-		}
-	}()
-
-	info, _, err := c.Object(ctx, CONTAINER, EMPTYOBJECT)
+	parallel, err := c.ContainersAllParallel(ctx, opts, []string{CONTAINER + "-d", CONTAINER + "-b", CONTAINER + "-bb"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if info.ContentType != "application/octet-stream" {
-		t.Error("Bad content type", info.ContentType)
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("Parallel listing differs from serial listing\nserial   %v\nparallel %v", serial, parallel)
 	}
-	if info.Bytes != 0 {
-		t.Errorf("Bad length want 0 got %v", info.Bytes)
+
+	// Marker and EndMarker bound the ranges
+	for _, reverse := range []bool{false, true} {
+		opts = &swift.ContainersOpts{Prefix: CONTAINER, Marker: CONTAINER + "-b", EndMarker: CONTAINER + "-e", Reverse: reverse}
+		bounded, err := c.ContainersAllParallel(ctx, opts, []string{CONTAINER + "-a", CONTAINER + "-c", CONTAINER + "-d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, container := range bounded {
+			got = append(got, container.Name)
+		}
+		want := []string{CONTAINER + "-c", CONTAINER + "-d"}
+		if reverse {
+			want[0], want[1] = want[1], want[0]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("reverse=%v: want %q got %q", reverse, want, got)
+		}
 	}
-	if info.Hash != EMPTY_MD5 {
-		t.Errorf("Bad MD5 want %v got %v", EMPTY_MD5, info.Hash)
+}
+
+func TestContainersDelimiter(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	for _, suffix := range []string{"-a", "-b-1", "-b-2", "-c"} {
+		err := c.ContainerCreate(ctx, CONTAINER+suffix, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			_ = c.ContainerDelete(ctx, name)
+		}(CONTAINER + suffix)
+	}
+	want := []string{CONTAINER + "-a", CONTAINER + "-b-", CONTAINER + "-c"}
+	for _, limit := range []int{0, 1} {
+		opts := &swift.ContainersOpts{Prefix: CONTAINER + "-", Delimiter: '-', Limit: limit}
+		containers, err := c.ContainersAll(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, container := range containers {
+			got = append(got, container.Name)
+			if container.PseudoDirectory != strings.HasSuffix(container.Name, "-") {
+				t.Errorf("limit %d: bad PseudoDirectory for %q", limit, container.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("limit %d: want %q got %q", limit, want, got)
+		}
 	}
 }
 
-func TestSymlinkObject(t *testing.T) {
+func TestContainerUpdate(t *testing.T) {
 	ctx := context.Background()
-	info, err := getSwinftInfo(t)
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.ContainerUpdate(ctx, CONTAINER, m2.ContainerHeaders())
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, ok := info["symlink"]; !ok {
-		// skip, symlink not supported
-		t.Skip("skip, symlink not supported")
-		return
+	_, headers, err := c.Container(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
 	}
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{})
+}
+
+func TestContainerUpdateRemove(t *testing.T) {
+	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-
-	// write target objects
-	err = c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "text/potato")
+	err := c.ContainerUpdate(ctx, CONTAINER, swift.Headers{
+		"X-Remove-Container-Meta-Hello": "x",
+		"X-Container-Meta-Colour":       "blue",
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Error(err)
-		}
-	}()
-
-	// test dynamic link
-	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
+	_, headers, err := c.Container(ctx, CONTAINER)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT)
-		if err != nil {
-			t.Error(err)
-		}
-	}()
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{"potato-salad": "2", "colour": "blue"})
 
-	md, _, err := c.Object(ctx, CONTAINER, SYMLINK_OBJECT)
+	err = c.AccountUpdate(ctx, swift.Headers{"X-Account-Meta-Colour": "red"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if md.ContentType != "text/potato" {
-		t.Error("Bad content type", md.ContentType)
+	err = c.AccountUpdate(ctx, swift.Headers{"X-Remove-Account-Meta-Colour": "x"})
+	if err != nil {
+		t.Fatal(err)
 	}
-	if md.Bytes != CONTENT_SIZE {
-		t.Errorf("Bad length want 5 got %v", md.Bytes)
+	_, headers, err = c.Account(ctx)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if md.Hash != CONTENT_MD5 {
-		t.Errorf("Bad MD5 want %v got %v", CONTENT_MD5, md.Hash)
+	if _, ok := headers.AccountMetadata()["colour"]; ok {
+		t.Error("Expecting account metadata to be removed")
 	}
-
 }
 
-func TestStaticSymlinkObject(t *testing.T) {
+func TestContainerMergeMetadata(t *testing.T) {
 	ctx := context.Background()
-	info, err := getSwinftInfo(t)
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.ContainerMergeMetadata(ctx, CONTAINER, swift.Metadata{"colour": "blue"}, []string{"hello"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if sym, ok := info["symlink"].(map[string]interface{}); ok {
-		if _, ok := sym["static_links"]; !ok {
-			t.Skip("skip, static symlink not supported")
-			return
-		}
-	} else {
-		t.Skip("skip, symlink not supported")
-		return
+	_, headers, err := c.Container(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ContainerMetadata(), map[string]string{"potato-salad": "2", "colour": "blue"})
+
+	err = c.AccountMergeMetadata(ctx, swift.Metadata{"colour": "red"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.AccountMergeMetadata(ctx, nil, []string{"colour"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Account(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := headers.AccountMetadata()["colour"]; ok {
+		t.Error("Expecting account metadata to be removed")
 	}
+}
 
+func TestContainerNames(t *testing.T) {
+	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-
-	// write target objects
-	err = c.ObjectPutBytes(ctx, CONTAINER, OBJECT2, []byte(CONTENTS2), "text/tomato")
+	containers, err := c.ContainerNames(ctx, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
-		if err != nil {
-			t.Error(err)
+	ok := false
+	for _, container := range containers {
+		if container == CONTAINER {
+			ok = true
+			break
 		}
-	}()
+	}
+	if !ok {
+		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
+	}
+}
 
-	// test static link
-	// first with the wrong target etag
-	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, OBJECT2, CONTENT_MD5)
-	if err == nil {
-		t.Error("Symlink with wrong target etag should have failed")
+func TestContainersAllReverse(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	forward, err := c.ContainerNamesAll(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
+	var want []string
+	for i := len(forward) - 1; i >= 0; i-- {
+		want = append(want, forward[i])
+	}
+	got, err := c.ContainerNamesAll(ctx, &swift.ContainersOpts{Limit: 1, Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect reverse listing want %q got %q", want, got)
+	}
+	containers, err := c.ContainersAll(ctx, &swift.ContainersOpts{Limit: 1, Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, container := range containers {
+		got = append(got, container.Name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect reverse listing want %q got %q", want, got)
+	}
+}
 
-	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, OBJECT2, CONTENT2_MD5)
+func TestContainerNamesAll(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers1, err := c.ContainerNamesAll(ctx, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT2)
-		if err != nil {
-			t.Error(err)
+	containers2, err := c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers1) != len(containers2) {
+		t.Fatal("Wrong length")
+	}
+	for i := range containers1 {
+		if containers1[i] != containers2[i] {
+			t.Fatal("Not the same")
 		}
-	}()
+	}
+}
 
-	md, _, err := c.Object(ctx, CONTAINER, SYMLINK_OBJECT2)
+func TestContainerNamesAllWithLimit(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	containers1, err := c.ContainerNamesAll(ctx, &swift.ContainersOpts{Limit: 1})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if md.ContentType != "text/tomato" {
-		t.Error("Bad content type", md.ContentType)
+	containers2, err := c.ContainerNames(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if md.Bytes != CONTENT_SIZE {
-		t.Errorf("Bad length want 5 got %v", md.Bytes)
+	if len(containers1) != len(containers2) {
+		t.Fatal("Wrong length")
 	}
-	if md.Hash != CONTENT2_MD5 {
-		t.Errorf("Bad MD5 want %v got %v", CONTENT2_MD5, md.Hash)
+	for i := range containers1 {
+		if containers1[i] != containers2[i] {
+			t.Fatal("Not the same")
+		}
 	}
 }
 
-func TestObjectPutBytes(t *testing.T) {
+func TestObjectPutString(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "")
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
 		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
 	}()
 
@@ -1101,1746 +1702,5668 @@ func TestObjectPutBytes(t *testing.T) {
 	}
 }
 
-func TestObjectPutMimeType(t *testing.T) {
+func TestObjectPut(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(ctx, CONTAINER, "test.jpg", CONTENTS, "")
+
+	headers := swift.Headers{}
+
+	// Set content size incorrectly - should produce an error
+	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE-1, 10)
+	contents := bytes.NewBufferString(CONTENTS)
+	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
+	if err == nil {
+		t.Fatal("Expecting error but didn't get one")
+	}
+
+	// Now set content size correctly
+	contents = bytes.NewBufferString(CONTENTS)
+	headers["Content-Length"] = strconv.FormatInt(CONTENT_SIZE, 10)
+	h, err := c.ObjectPut(ctx, CONTAINER, OBJECT, contents, true, CONTENT_MD5, "text/plain", headers)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, "test.jpg")
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
 	}()
 
-	info, _, err := c.Object(ctx, CONTAINER, "test.jpg")
+	if h["Etag"] != CONTENT_MD5 {
+		t.Errorf("Bad Etag want %q got %q", CONTENT_MD5, h["Etag"])
+	}
+
+	// Fetch object info and compare
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Error(err)
 	}
-	if info.ContentType != "image/jpeg" {
+	if info.ContentType != "text/plain" {
 		t.Error("Bad content type", info.ContentType)
 	}
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
+	}
+	if info.Hash != CONTENT_MD5 {
+		t.Error("Bad length")
+	}
 }
 
-func TestObjectCreate(t *testing.T) {
+func TestObjectPutIfNotExists(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	out, err := c.ObjectCreate(ctx, CONTAINER, OBJECT2, true, "", "", nil)
+	_, err := c.ObjectPutIfNotExists(ctx, CONTAINER, OBJECT, bytes.NewBufferString(CONTENTS), true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
 	}()
-	buf := &bytes.Buffer{}
-	hash := md5.New()
-	out2 := io.MultiWriter(out, buf, hash)
-	for i := 0; i < 100; i++ {
-		_, _ = fmt.Fprintf(out2, "%d %s\n", i, CONTENTS)
+	_, err = c.ObjectPutIfNotExists(ctx, CONTAINER, OBJECT, bytes.NewBufferString(CONTENTS2), true, "", "", nil)
+	if err != swift.PreconditionFailed {
+		t.Fatal("Expecting PreconditionFailed", err)
 	}
-	// Ensure Headers fails if called prematurely
-	_, err = out.Headers()
-	if err == nil {
-		t.Error("Headers should fail if called before Close()")
-	}
-	err = out.Close()
-	if err != nil {
-		t.Error(err)
-	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if contents != CONTENTS {
+		t.Errorf("Object was overwritten: got %q", contents)
 	}
+}
 
-	// Ensure Headers succeeds when called after a good upload
-	headers, err := out.Headers()
-	if err != nil {
-		t.Error(err)
-	}
-	if len(headers) < 1 {
-		t.Error("The Headers returned by Headers() should not be empty")
+func TestObjectPutWithReauth(t *testing.T) {
+	ctx := context.Background()
+	if !swift.IS_AT_LEAST_GO_16 {
+		return
 	}
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
 
-	// Test writing on closed file
-	n, err := out.Write([]byte{0})
-	if err == nil || n != 0 {
-		t.Error("Expecting error and n == 0 writing on closed file", err, n)
-	}
+	// Simulate that our auth token expired
+	c.AuthToken = "expiredtoken"
 
-	// Now with hash instead
-	out, err = c.ObjectCreate(ctx, CONTAINER, OBJECT2, false, fmt.Sprintf("%x", hash.Sum(nil)), "", nil)
+	r := strings.NewReader(CONTENTS)
+	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, r, false, "", "text/plain", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = out.Write(buf.Bytes())
-	if err != nil {
-		t.Error(err)
-	}
-	err = out.Close()
-	if err != nil {
-		t.Error(err)
-	}
-	contents, err = c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Error(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if info.ContentType != "text/plain" {
+		t.Error("Bad content type", info.ContentType)
 	}
-
-	// Now with bad hash
-	out, err = c.ObjectCreate(ctx, CONTAINER, OBJECT2, false, CONTENT_MD5, "", nil)
-	if err != nil {
-		t.Fatal(err)
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
 	}
-	// FIXME: work around bug which produces 503 not 422 for empty corrupted files
-	_, _ = fmt.Fprintf(out, "Sausage")
-	err = out.Close()
-	if err != swift.ObjectCorrupted {
-		t.Error("Expecting object corrupted not", err)
+	if info.Hash != CONTENT_MD5 {
+		t.Error("Bad length")
 	}
 }
 
-func TestObjectCreateAbort(t *testing.T) {
+func TestObjectPutStringWithReauth(t *testing.T) {
 	ctx := context.Background()
+	if !swift.IS_AT_LEAST_GO_16 {
+		return
+	}
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
 
-	out, err := c.ObjectCreate(ctx, CONTAINER, OBJECT2, true, "", "", nil)
+	// Simulate that our auth token expired
+	c.AuthToken = "expiredtoken"
+
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2) // Ignore error
-	}()
 
-	expectedContents := "foo"
-	_, err = out.Write([]byte(expectedContents))
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Error(err)
 	}
-
-	errAbort := fmt.Errorf("abort")
-	err = out.CloseWithError(errAbort)
-	if err != nil {
-		t.Errorf("Unexpected error %#v", err)
+	if info.ContentType != "application/octet-stream" {
+		t.Error("Bad content type", info.ContentType)
 	}
-
-	_, err = c.ObjectGetString(ctx, CONTAINER, OBJECT2)
-	if err != swift.ObjectNotFound {
-		t.Errorf("Unexpected error: %#v", err)
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
+	}
+	if info.Hash != CONTENT_MD5 {
+		t.Error("Bad length")
 	}
 }
 
-func TestObjectGetString(t *testing.T) {
+func TestObjectEmpty(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	err := c.ObjectPutString(ctx, CONTAINER, EMPTYOBJECT, "", "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if contents != CONTENTS {
-		t.Error("Contents wrong")
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, EMPTYOBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	info, _, err := c.Object(ctx, CONTAINER, EMPTYOBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if info.ContentType != "application/octet-stream" {
+		t.Error("Bad content type", info.ContentType)
+	}
+	if info.Bytes != 0 {
+		t.Errorf("Bad length want 0 got %v", info.Bytes)
+	}
+	if info.Hash != EMPTY_MD5 {
+		t.Errorf("Bad MD5 want %v got %v", EMPTY_MD5, info.Hash)
 	}
 }
 
-func TestObjectGetBytes(t *testing.T) {
+func TestSymlinkObject(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	contents, err := c.ObjectGetBytes(ctx, CONTAINER, OBJECT)
+	info, err := getSwinftInfo(t)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(contents) != CONTENTS {
-		t.Error("Contents wrong")
+	if !info.SupportsSymlinks() {
+		// skip, symlink not supported
+		t.Skip("skip, symlink not supported")
+		return
 	}
-}
-
-func TestObjectOpen(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+
+	// write target objects
+	err = c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "text/potato")
 	if err != nil {
 		t.Fatal(err)
 	}
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, file)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// test dynamic link
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != CONTENT_SIZE {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	md, _, err := c.Object(ctx, CONTAINER, SYMLINK_OBJECT)
+	if err != nil {
+		t.Error(err)
 	}
-	if buf.String() != CONTENTS {
-		t.Error("Contents wrong")
+	if md.ContentType != "text/potato" {
+		t.Error("Bad content type", md.ContentType)
 	}
-	err = file.Close()
-	if err != nil {
-		t.Fatal(err)
+	if md.Bytes != CONTENT_SIZE {
+		t.Errorf("Bad length want 5 got %v", md.Bytes)
+	}
+	if md.Hash != CONTENT_MD5 {
+		t.Errorf("Bad MD5 want %v got %v", CONTENT_MD5, md.Hash)
 	}
+
 }
 
-func TestObjectOpenPartial(t *testing.T) {
+func TestStaticSymlinkObject(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	info, err := getSwinftInfo(t)
 	if err != nil {
 		t.Fatal(err)
 	}
-	var buf bytes.Buffer
-	n, err := io.CopyN(&buf, file, 1)
+	if !info.SupportsStaticSymlinks() {
+		t.Skip("skip, static symlink not supported")
+		return
+	}
+
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	// write target objects
+	err = c.ObjectPutBytes(ctx, CONTAINER, OBJECT2, []byte(CONTENTS2), "text/tomato")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 1 {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
-	}
-	if buf.String() != CONTENTS[:1] {
-		t.Error("Contents wrong")
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// test static link
+	// first with the wrong target etag
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, OBJECT2, CONTENT_MD5)
+	if err == nil {
+		t.Error("Symlink with wrong target etag should have failed")
 	}
-	err = file.Close()
+
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, OBJECT2, CONTENT2_MD5)
 	if err != nil {
 		t.Fatal(err)
 	}
-}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
 
-func TestObjectOpenLength(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	md, _, err := c.Object(ctx, CONTAINER, SYMLINK_OBJECT2)
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	// FIXME ideally this would check both branches of the Length() code
-	n, err := file.Length(ctx)
-	if err != nil {
-		t.Fatal(err)
+	if md.ContentType != "text/tomato" {
+		t.Error("Bad content type", md.ContentType)
 	}
-	if n != CONTENT_SIZE {
-		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	if md.Bytes != CONTENT_SIZE {
+		t.Errorf("Bad length want 5 got %v", md.Bytes)
 	}
-	err = file.Close()
-	if err != nil {
-		t.Fatal(err)
+	if md.Hash != CONTENT2_MD5 {
+		t.Errorf("Bad MD5 want %v got %v", CONTENT2_MD5, md.Hash)
 	}
 }
 
-func TestObjectOpenNotModified(t *testing.T) {
+func TestObjectSymlinkTarget(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	_, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, swift.Headers{
-		"If-None-Match": CONTENT_MD5,
-	})
-	if err != swift.NotModified {
+	info, err := getSwinftInfo(t)
+	if err != nil {
 		t.Fatal(err)
 	}
-}
-
-func TestObjectOpenSeek(t *testing.T) {
-	ctx := context.Background()
+	if !info.SupportsSymlinks() {
+		t.Skip("skip, symlink not supported")
+		return
+	}
 	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
 
-	plan := []struct {
-		whence int
-		offset int64
-		result int64
-	}{
-		{-1, 0, 0},
-		{-1, 0, 1},
-		{-1, 0, 2},
-		{0, 0, 0},
-		{0, 0, 0},
-		{0, 1, 1},
-		{0, 2, 2},
-		{1, 0, 3},
-		{1, -2, 2},
-		{1, 1, 4},
-		{2, -1, 4},
-		{2, -3, 2},
-		{2, -2, 3},
-		{2, -5, 0},
-		{2, -4, 1},
-	}
-
-	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	for _, p := range plan {
-		if p.whence >= 0 {
-			var result int64
-			result, err = file.Seek(ctx, p.offset, p.whence)
-			if err != nil {
-				t.Fatal(err, p)
-			}
-			if result != p.result {
-				t.Fatal("Seek result was", result, "expecting", p.result, p)
-			}
-
-		}
-		var buf bytes.Buffer
-		var n int64
-		n, err = io.CopyN(&buf, file, 1)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT)
 		if err != nil {
-			t.Fatal(err, p)
-		}
-		if n != 1 {
-			t.Fatal("Wrong length", n, p)
-		}
-		actual := buf.String()
-		expected := CONTENTS[p.result : p.result+1]
-		if actual != expected {
-			t.Error("Contents wrong, expecting", expected, "got", actual, p)
+			t.Error(err)
 		}
-	}
+	}()
 
-	err = file.Close()
+	target, err := c.ObjectSymlinkTarget(ctx, CONTAINER, SYMLINK_OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
-}
+	want := swift.SymlinkTarget{Container: CONTAINER, Object: OBJECT}
+	if target != want {
+		t.Errorf("Bad dynamic target want %+v got %+v", want, target)
+	}
 
-// Test seeking to the end to find the file size
-func TestObjectOpenSeekEnd(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	// Static symlink
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, OBJECT, CONTENT_MD5)
 	if err != nil {
 		t.Fatal(err)
 	}
-	n, err := file.Seek(ctx, 0, 2) // seek to end
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	target, err = c.ObjectSymlinkTarget(ctx, CONTAINER, SYMLINK_OBJECT2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != CONTENT_SIZE {
-		t.Fatal("Wrong offset", n)
+	want = swift.SymlinkTarget{Container: CONTAINER, Object: OBJECT, Etag: CONTENT_MD5, IsStatic: true}
+	if target != want {
+		t.Errorf("Bad static target want %+v got %+v", want, target)
 	}
 
-	// Now check reading returns EOF
-	buf := make([]byte, 16)
-	nn, err := io.ReadFull(file, buf)
-	if err != io.EOF {
+	// Cross-account symlink
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "AUTH_shared", CONTAINER, OBJECT, "")
+	if err != nil {
 		t.Fatal(err)
 	}
-	if nn != 0 {
-		t.Fatal("wrong length", n)
+	target, err = c.ObjectSymlinkTarget(ctx, CONTAINER, SYMLINK_OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = swift.SymlinkTarget{Account: "AUTH_shared", Container: CONTAINER, Object: OBJECT}
+	if target != want {
+		t.Errorf("Bad cross-account target want %+v got %+v", want, target)
 	}
 
-	// Now seek back to start and check we can read the file
-	n, err = file.Seek(ctx, 0, 0) // seek to start
+	// Object follows the symlink, ObjectNoFollow doesn't
+	info2, _, err := c.Object(ctx, CONTAINER, SYMLINK_OBJECT2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 0 {
-		t.Fatal("Wrong offset", n)
+	if info2.Bytes != CONTENT_SIZE {
+		t.Errorf("Object: want size %d got %d", CONTENT_SIZE, info2.Bytes)
 	}
-
-	// read file and check contents
-	buf, err = io.ReadAll(file)
+	info2, headers, err := c.ObjectNoFollow(ctx, CONTAINER, SYMLINK_OBJECT2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(buf) != CONTENTS {
-		t.Fatal("wrong contents", string(buf))
+	if info2.Bytes != 0 {
+		t.Errorf("ObjectNoFollow: want size 0 got %d", info2.Bytes)
 	}
-}
-
-func TestObjectUpdate(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObject(t)
-	defer rollback()
-	err := c.ObjectUpdate(ctx, CONTAINER, OBJECT, m1.ObjectHeaders())
+	if got := headers["X-Symlink-Target"]; got != CONTAINER+"/"+OBJECT {
+		t.Errorf("ObjectNoFollow: bad X-Symlink-Target %q", got)
+	}
+	if info2.ObjectType != swift.SymlinkObjectType || headers.ObjectType() != swift.SymlinkObjectType {
+		t.Errorf("ObjectNoFollow: want SymlinkObjectType got %d", info2.ObjectType)
+	}
+	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Prefix: SYMLINK_OBJECT2})
 	if err != nil {
 		t.Fatal(err)
 	}
-}
-
-func checkTime(t *testing.T, when time.Time, low, high int) {
-	dt := time.Since(when)
-	if dt < time.Duration(low)*time.Second || dt > time.Duration(high)*time.Second {
-		t.Errorf("Time is wrong: dt=%q, when=%q", dt, when)
+	if len(objects) != 1 || objects[0].ObjectType != swift.SymlinkObjectType {
+		t.Errorf("Objects: want one SymlinkObjectType got %+v", objects)
 	}
-}
 
-func TestObject(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	object, headers, err := c.Object(ctx, CONTAINER, OBJECT)
-	if err != nil {
-		t.Fatal(err)
+	_, err = c.ObjectSymlinkTarget(ctx, CONTAINER, OBJECT)
+	if err != swift.NotSymlink {
+		t.Errorf("Expecting NotSymlink got %v", err)
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
-	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
-		t.Error("Bad object info", object)
+
+	_, err = c.ObjectSymlinkTarget(ctx, CONTAINER, "notfound")
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
-	checkTime(t, object.LastModified, -10, 10)
 }
 
-func TestObjectUpdate2(t *testing.T) {
+func TestObjectCopyFollowSymlink(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	err := c.ObjectUpdate(ctx, CONTAINER, OBJECT, m2.ObjectHeaders())
+	info, err := getSwinftInfo(t)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if !info.SupportsSymlinks() {
+		t.Skip("skip, symlink not supported")
+		return
+	}
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	// SYMLINK_OBJECT2 -> SYMLINK_OBJECT -> OBJECT
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "", "potato-salad": ""})
-}
-
-func TestContainers(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	containers, err := c.Containers(ctx, nil)
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT2, "", CONTAINER, SYMLINK_OBJECT, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	ok := false
-	for _, container := range containers {
-		if container.Name == CONTAINER {
-			ok = true
-			// Container may or may not have the file contents in it
-			// Swift updates may be behind
-			if container.Count == 0 && container.Bytes == 0 {
-				break
-			}
-			if container.Count == 1 && container.Bytes == CONTENT_SIZE {
-				break
+	defer func() {
+		for _, name := range []string{SYMLINK_OBJECT, SYMLINK_OBJECT2, OBJECT2} {
+			err = c.ObjectDelete(ctx, CONTAINER, name)
+			if err != nil {
+				t.Error(err)
 			}
-			t.Errorf("Bad size of Container %q: %q", CONTAINER, container)
-			break
 		}
-	}
-	if !ok {
-		t.Errorf("Didn't find container %q in listing %q", CONTAINER, containers)
-	}
-}
+	}()
 
-func TestObjectNames(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectNames(ctx, CONTAINER, nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	for _, src := range []string{SYMLINK_OBJECT2, SYMLINK_OBJECT, OBJECT} {
+		_, err = c.ObjectCopyFollowSymlink(ctx, CONTAINER, src, CONTAINER, OBJECT2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.ObjectSymlinkTarget(ctx, CONTAINER, OBJECT2)
+		if err != swift.NotSymlink {
+			t.Errorf("%s: Expecting copy not to be a symlink got %v", src, err)
+		}
+		contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != CONTENTS {
+			t.Errorf("%s: Bad contents %q", src, contents)
+		}
 	}
 }
 
-func TestObjectNamesAll(t *testing.T) {
+func TestObjectGetParallel(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	objects, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+
+	contents := make([]byte, 100*1024+7)
+	_, _ = rand.Read(contents)
+	err := c.ObjectPutBytes(ctx, CONTAINER, OBJECT, contents, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	for _, concurrency := range []int{1, 4, 7} {
+		out, err := os.CreateTemp(t.TempDir(), "parallel")
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers, err := c.ObjectGetParallel(ctx, CONTAINER, OBJECT, out, concurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers["Content-Length"] != strconv.Itoa(len(contents)) {
+			t.Errorf("Bad headers %v", headers)
+		}
+		got, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = out.Close()
+		if !bytes.Equal(got, contents) {
+			t.Errorf("concurrency %d: contents differ, got %d bytes want %d", concurrency, len(got), len(contents))
+		}
 	}
-}
 
-func TestObjectNamesAllWithLimit(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectNamesAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	out, err := os.CreateTemp(t.TempDir(), "parallel")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+	defer func() {
+		_ = out.Close()
+	}()
+	_, err = c.ObjectGetParallel(ctx, CONTAINER, "notfound", out, 4)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
 }
 
-func TestObjectsWalk(t *testing.T) {
+func TestObjectServeHTTP(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	objects := make([]string, 0)
-	err := c.ObjectsWalk(ctx, container, nil, func(ctx context.Context, opts *swift.ObjectsOpts) (interface{}, error) {
-		newObjects, err := c.ObjectNames(ctx, CONTAINER, opts)
-		if err == nil {
-			objects = append(objects, newObjects...)
+
+	serve := func(method string, objectName string, h http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/"+objectName, nil)
+		for k, v := range h {
+			req.Header[k] = v
 		}
-		return newObjects, err
-	})
-	if err != nil {
-		t.Fatal(err)
+		w := httptest.NewRecorder()
+		_ = c.ObjectServeHTTP(ctx, CONTAINER, objectName, w, req)
+		return w
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Incorrect listing", objects)
+
+	w := serve("GET", OBJECT, nil)
+	if w.Code != http.StatusOK || w.Body.String() != CONTENTS {
+		t.Errorf("Bad full response %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(CONTENTS)) {
+		t.Errorf("Bad Content-Length %q", got)
+	}
+	if got := strings.Trim(w.Header().Get("Etag"), `"`); got != CONTENT_MD5 {
+		t.Errorf("Bad Etag %q", got)
+	}
+	if w.Header().Get("Last-Modified") == "" || w.Header().Get("Content-Type") == "" {
+		t.Errorf("Missing headers %v", w.Header())
 	}
-}
 
-func TestObjects(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
-	if err != nil {
-		t.Fatal(err)
+	w = serve("GET", OBJECT, http.Header{"Range": {"bytes=1-3"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != CONTENTS[1:4] {
+		t.Errorf("Bad range response %d %q", w.Code, w.Body.String())
 	}
-	if len(objects) != 1 {
-		t.Fatal("Should only be 1 object")
+	if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes 1-3/%d", len(CONTENTS)); got != want {
+		t.Errorf("Bad Content-Range want %q got %q", want, got)
 	}
-	object := objects[0]
-	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
-		t.Error("Bad object info", object)
+	if got := w.Header().Get("Content-Length"); got != "3" {
+		t.Errorf("Bad range Content-Length %q", got)
+	}
+
+	w = serve("HEAD", OBJECT, nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != strconv.Itoa(len(CONTENTS)) {
+		t.Errorf("Bad HEAD response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = serve("GET", "notfound", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expecting 404 got %d", w.Code)
 	}
-	checkTime(t, object.LastModified, -10, 10)
 }
 
-func TestObjectsDirectory(t *testing.T) {
+func TestObjectPutBytes(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(ctx, CONTAINER, "directory", "", "application/directory")
+	err := c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		_ = c.ObjectDelete(ctx, CONTAINER, "directory")
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
 	}()
 
-	// Look for the directory object and check we aren't confusing
-	// it with a pseudo directory object
-	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	if len(objects) != 2 {
-		t.Fatal("Should only be 2 objects")
+	if info.ContentType != "application/octet-stream" {
+		t.Error("Bad content type", info.ContentType)
 	}
-	found := false
-	for i := range objects {
-		object := objects[i]
-		if object.Name == "directory" {
-			found = true
-			if object.Bytes != 0 || object.ContentType != "application/directory" || object.Hash != "d41d8cd98f00b204e9800998ecf8427e" || object.PseudoDirectory != false || object.SubDir != "" {
-				t.Error("Bad object info", object)
-			}
-			checkTime(t, object.LastModified, -10, 10)
-		}
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
 	}
-	if !found {
-		t.Error("Didn't find directory object")
+	if info.Hash != CONTENT_MD5 {
+		t.Error("Bad length")
 	}
 }
 
-func TestObjectsPseudoDirectory(t *testing.T) {
+func TestObjectPutMimeType(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	err := c.ObjectPutString(ctx, CONTAINER, "directory/puppy.jpg", "cute puppy", "")
+	err := c.ObjectPutString(ctx, CONTAINER, "test.jpg", CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		_ = c.ObjectDelete(ctx, CONTAINER, "directory/puppy.jpg")
+		err = c.ObjectDelete(ctx, CONTAINER, "test.jpg")
+		if err != nil {
+			t.Error(err)
+		}
 	}()
 
-	// Look for the pseudo directory
-	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	info, _, err := c.Object(ctx, CONTAINER, "test.jpg")
 	if err != nil {
-		t.Fatal(err)
-	}
-	if len(objects) != 2 {
-		t.Fatal("Should only be 2 objects", objects)
-	}
-	found := false
-	for i := range objects {
-		object := objects[i]
-		if object.Name == "directory/" {
-			found = true
-			if object.Bytes != 0 || object.ContentType != "application/directory" || object.Hash != "" || object.PseudoDirectory != true || object.SubDir != "directory/" && object.LastModified.IsZero() {
-				t.Error("Bad object info", object)
-			}
-		}
+		t.Error(err)
 	}
-	if !found {
-		t.Error("Didn't find directory object", objects)
+	if info.ContentType != "image/jpeg" {
+		t.Error("Bad content type", info.ContentType)
 	}
+}
 
-	// Look in the pseudo directory now
-	objects, err = c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: "directory/"})
+func TestObjectCreate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	out, err := c.ObjectCreate(ctx, CONTAINER, OBJECT2, true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 {
-		t.Fatal("Should only be 1 object", objects)
-	}
-	object := objects[0]
-	if object.Name != "directory/puppy.jpg" || object.Bytes != 10 || object.ContentType != "image/jpeg" || object.Hash != "87a12ea22fca7f54f0cefef1da535489" || object.PseudoDirectory != false || object.SubDir != "" {
-		t.Error("Bad object info", object)
-	}
-	checkTime(t, object.LastModified, -10, 10)
-}
-
-func TestObjectsAll(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectsAll(ctx, CONTAINER, nil)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	buf := &bytes.Buffer{}
+	hash := md5.New()
+	out2 := io.MultiWriter(out, buf, hash)
+	for i := 0; i < 100; i++ {
+		_, _ = fmt.Fprintf(out2, "%d %s\n", i, CONTENTS)
+	}
+	// Ensure Headers and StatusCode fail if called prematurely
+	_, err = out.Headers()
+	if err == nil {
+		t.Error("Headers should fail if called before Close()")
+	}
+	_, err = out.StatusCode()
+	if err == nil {
+		t.Error("StatusCode should fail if called before Close()")
+	}
+	err = out.Close()
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	if len(objects) != 1 || objects[0].Name != OBJECT {
-		t.Error("Incorrect listing", objects)
+	statusCode, err := out.StatusCode()
+	if err != nil {
+		t.Error(err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Errorf("Bad status code %d", statusCode)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
 	}
-}
 
-func TestObjectsAllWithLimit(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectsAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	// Ensure Headers succeeds when called after a good upload
+	headers, err := out.Headers()
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
 	}
-	if len(objects) != 1 || objects[0].Name != OBJECT {
-		t.Error("Incorrect listing", objects)
+	if len(headers) < 1 {
+		t.Error("The Headers returned by Headers() should not be empty")
 	}
-}
 
-func TestObjectNamesWithPath(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	objects, err := c.ObjectNames(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: ""})
+	// Test writing on closed file
+	n, err := out.Write([]byte{0})
+	if err == nil || n != 0 {
+		t.Error("Expecting error and n == 0 writing on closed file", err, n)
+	}
+
+	// Now with hash instead
+	out, err = c.ObjectCreate(ctx, CONTAINER, OBJECT2, false, fmt.Sprintf("%x", hash.Sum(nil)), "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 1 || objects[0] != OBJECT {
-		t.Error("Bad listing with path", objects)
+	_, err = out.Write(buf.Bytes())
+	if err != nil {
+		t.Error(err)
 	}
-	// fmt.Println(objects)
-	objects, err = c.ObjectNames(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: "Downloads/"})
+	err = out.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	contents, err = c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+
+	// Now with bad hash
+	out, err = c.ObjectCreate(ctx, CONTAINER, OBJECT2, false, CONTENT_MD5, "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(objects) != 0 {
-		t.Error("Bad listing with path", objects)
+	// FIXME: work around bug which produces 503 not 422 for empty corrupted files
+	_, _ = fmt.Fprintf(out, "Sausage")
+	err = out.Close()
+	if err != swift.ObjectCorrupted {
+		t.Error("Expecting object corrupted not", err)
 	}
 }
 
-func TestObjectCopy(t *testing.T) {
+func TestObjectCreateAbort(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, nil)
+
+	out, err := c.ObjectCreate(ctx, CONTAINER, OBJECT2, true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2) // Ignore error
+	}()
+
+	expectedContents := "foo"
+	_, err = out.Write([]byte(expectedContents))
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
+	}
+
+	errAbort := fmt.Errorf("abort")
+	err = out.CloseWithError(errAbort)
+	if err != nil {
+		t.Errorf("Unexpected error %#v", err)
+	}
+
+	_, err = c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Unexpected error: %#v", err)
 	}
 }
 
-func TestObjectCopyDifficultName(t *testing.T) {
+func TestObjectCreateStatusCodeFailed(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionAuth(t)
 	defer rollback()
-	const dest = OBJECT + "?param %30%31%32 £100"
-	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, dest, nil)
+
+	out, err := c.ObjectCreate(ctx, CONTAINER+"-missing", OBJECT, true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = c.ObjectDelete(ctx, CONTAINER, dest)
+	_, _ = out.Write([]byte(CONTENTS))
+	err = out.Close()
+	if err == nil {
+		t.Fatal("Expecting error uploading to missing container")
+	}
+	statusCode, err := out.StatusCode()
 	if err != nil {
-		t.Fatal(err)
+		t.Error(err)
+	}
+	if statusCode != http.StatusNotFound {
+		t.Errorf("Bad status code %d", statusCode)
 	}
 }
 
-func TestObjectCopyWithMetadata(t *testing.T) {
+func TestObjectPutProgress(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	m := swift.Metadata{}
-	m["copy-special-metadata"] = "hello"
-	m["hello"] = "9"
-	h := m.ObjectHeaders()
-	h["Content-Type"] = "image/jpeg"
-	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, h)
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2) // Ignore error
+	}()
+
+	var written, total int64
+	progressCtx := swift.WithProgress(ctx, func(bytesWritten int64, totalBytes int64) {
+		written, total = bytesWritten, totalBytes
+	})
+	err := c.ObjectPutString(progressCtx, CONTAINER, OBJECT2, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	if written != CONTENT_SIZE || total != CONTENT_SIZE {
+		t.Errorf("ObjectPut: want progress %d/%d got %d/%d", CONTENT_SIZE, CONTENT_SIZE, written, total)
+	}
+
+	for _, checkHash := range []bool{false, true} {
+		written, total = 0, 0
+		_, err = c.ObjectPut(progressCtx, CONTAINER, OBJECT2, strings.NewReader(CONTENTS), checkHash, "", "", nil)
 		if err != nil {
 			t.Fatal(err)
 		}
-	}()
-	// Re-read the metadata to see if it is correct
-	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if headers["Content-Type"] != "image/jpeg" {
-		t.Error("Didn't change content type")
+		if written != CONTENT_SIZE || total != CONTENT_SIZE {
+			t.Errorf("ObjectPut checkHash=%v: want progress %d/%d got %d/%d", checkHash, CONTENT_SIZE, CONTENT_SIZE, written, total)
+		}
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "9", "potato-salad": "2", "copy-special-metadata": "hello"})
-}
 
-func TestObjectMove(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
-	defer rollback()
-	err := c.ObjectMove(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	written, total = 0, 0
+	out, err := c.ObjectCreate(progressCtx, CONTAINER, OBJECT2, true, "", "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
-	_, _, err = c.Object(ctx, CONTAINER, OBJECT2)
+	_, err = out.Write([]byte(CONTENTS + CONTENTS))
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	err = c.ObjectMove(ctx, CONTAINER, OBJECT2, CONTAINER, OBJECT)
+	err = out.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	testExistenceAfterDelete(t, c, CONTAINER, OBJECT2)
-	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
-	if err != nil {
-		t.Fatal(err)
+	if written != 2*CONTENT_SIZE || total != -1 {
+		t.Errorf("ObjectCreate: want progress %d/-1 got %d/%d", 2*CONTENT_SIZE, written, total)
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
 }
 
-func TestObjectUpdateContentType(t *testing.T) {
+func BenchmarkObjectPut(b *testing.B) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithObjectHeaders(t)
+	c, rollback := makeConnection(nil)
 	defer rollback()
-	err := c.ObjectUpdateContentType(ctx, CONTAINER, OBJECT, "text/potato")
+	err := c.Authenticate(ctx)
 	if err != nil {
-		t.Fatal(err)
+		b.Fatal(err)
 	}
-	// Re-read the metadata to see if it is correct
-	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	err = c.ContainerCreate(ctx, CONTAINER, nil)
 	if err != nil {
-		t.Fatal(err)
+		b.Fatal(err)
 	}
-	if headers["Content-Type"] != "text/potato" {
-		t.Error("Didn't change content type")
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		_ = c.ContainerDelete(ctx, CONTAINER)
+	}()
+	data := make([]byte, 8*1024*1024)
+	for _, checkHash := range []bool{false, true} {
+		b.Run(fmt.Sprintf("checkHash=%v", checkHash), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := c.ObjectPut(ctx, CONTAINER, OBJECT, bytes.NewReader(data), checkHash, "", "", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
 	}
-	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
 }
 
-func TestVersionContainerCreate(t *testing.T) {
+func TestObjectGetString(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionAuth(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	err := c.VersionContainerCreate(ctx, CURRENT_CONTAINER, VERSIONS_CONTAINER)
-	defer func() {
-		_ = c.ContainerDelete(ctx, CURRENT_CONTAINER)
-		_ = c.ContainerDelete(ctx, VERSIONS_CONTAINER)
-	}()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
 	if err != nil {
-		if err == swift.Forbidden {
-			t.Log("Server doesn't support Versions - skipping test")
-			return
-		}
 		t.Fatal(err)
 	}
+	if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
 }
 
-func TestVersionObjectAdd(t *testing.T) {
+func TestObjectGetBytes(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithVersionsContainer(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	if skipVersionTests {
-		t.Log("Server doesn't support Versions - skipping test")
-		return
-	}
-	// Version 1
-	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS, ""); err != nil {
+	contents, err := c.ObjectGetBytes(ctx, CONTAINER, OBJECT)
+	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
-		t.Fatal(err)
-	} else if contents != CONTENTS {
+	if string(contents) != CONTENTS {
 		t.Error("Contents wrong")
 	}
+}
 
-	// Version 2
-	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS2, ""); err != nil {
+func TestObjectPutGetFile(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "upload.txt")
+	err := os.WriteFile(src, []byte(CONTENTS), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ObjectPutFile(ctx, CONTAINER, OBJECT, src, "")
+	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	}()
-	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
 		t.Fatal(err)
-	} else if contents != CONTENTS2 {
-		t.Error("Contents wrong")
+	}
+	if !strings.HasPrefix(info.ContentType, "text/plain") {
+		t.Error("Bad content type", info.ContentType)
+	}
+	if info.Bytes != CONTENT_SIZE {
+		t.Error("Bad length")
 	}
 
-	// Version 3
-	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS2, ""); err != nil {
+	dst := filepath.Join(dir, "download.txt")
+	headers, err := c.ObjectGetFile(ctx, CONTAINER, OBJECT, dst)
+	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-}
-
-func TestVersionObjectList(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithVersionsObject(t)
-	defer rollback()
-	if skipVersionTests {
-		t.Log("Server doesn't support Versions - skipping test")
-		return
+	if headers["Etag"] != CONTENT_MD5 {
+		t.Error("Bad Etag", headers["Etag"])
 	}
-	list, err := c.VersionObjectList(ctx, VERSIONS_CONTAINER, OBJECT)
+	contents, err := os.ReadFile(dst)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if string(contents) != CONTENTS {
+		t.Error("Contents wrong")
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(info.LastModified.Truncate(time.Second)) {
+		t.Errorf("Bad modification time want %v got %v", info.LastModified, fi.ModTime())
+	}
 
-	if len(list) != 2 {
-		t.Error("Version list should return 2 objects")
+	// A failed download doesn't leave a file behind
+	missing := filepath.Join(dir, "missing.txt")
+	_, err = c.ObjectGetFile(ctx, CONTAINER, OBJECT2, missing)
+	if err != swift.ObjectNotFound {
+		t.Error("Expecting ObjectNotFound", err)
+	}
+	if _, err = os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("Expecting file to be removed", err)
 	}
-}
 
-func TestVersionObjectDelete(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithVersionsObject(t)
-	defer rollback()
-	if skipVersionTests {
-		t.Log("Server doesn't support Versions - skipping test")
-		return
+	// A failed download leaves an existing file alone
+	_, err = c.ObjectGetFile(ctx, CONTAINER, OBJECT2, dst)
+	if err != swift.ObjectNotFound {
+		t.Error("Expecting ObjectNotFound", err)
 	}
-	// Delete Version 3
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+	contents, err = os.ReadFile(dst)
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Delete Version 2
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+	if string(contents) != CONTENTS {
+		t.Errorf("Existing file changed to %q", contents)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
 		t.Fatal(err)
 	}
+	if len(entries) != 2 {
+		t.Errorf("Expecting only the uploaded and downloaded files got %d entries", len(entries))
+	}
+}
 
-	// Contents should be reverted to Version 1
-	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+func TestObjectOpen(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	if err != nil {
 		t.Fatal(err)
-	} else if contents != CONTENTS {
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != CONTENT_SIZE {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
+	}
+	if buf.String() != CONTENTS {
 		t.Error("Contents wrong")
 	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
-func TestVersionDeleteContent(t *testing.T) {
+func TestObjectOpenPartial(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithVersionsObject(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	if skipVersionTests {
-		t.Log("Server doesn't support Versions - skipping test")
-		return
-	}
-	// Delete Version 3
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Delete Version 2
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, file, 1)
+	if err != nil {
 		t.Fatal(err)
 	}
-	// Delete Version 1
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
-		t.Fatal(err)
+	if n != 1 {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
 	}
-	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != swift.ObjectNotFound {
-		t.Fatalf("Expecting Object not found error, got: %v", err)
+	if buf.String() != CONTENTS[:1] {
+		t.Error("Contents wrong")
+	}
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-// Check for non existence after delete
-// May have to do it a few times to wait for swift to be consistent.
-func testExistenceAfterDelete(t *testing.T, c *swift.Connection, container, object string) {
+func TestObjectGetRanges(t *testing.T) {
 	ctx := context.Background()
-	for i := 10; i <= 0; i-- {
-		_, _, err := c.Object(ctx, container, object)
-		if err == swift.ObjectNotFound {
-			break
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	for _, test := range []struct {
+		ranges []swift.Range
+		want   []string
+		starts []int64
+	}{
+		{[]swift.Range{{Start: 1, Length: 3}}, []string{"234"}, []int64{1}},
+		{[]swift.Range{{Start: 0, Length: 2}, {Start: 3}}, []string{"12", "45"}, []int64{0, 3}},
+	} {
+		ranges, _, err := c.ObjectGetRanges(ctx, CONTAINER, OBJECT, test.ranges)
+		if err != nil {
+			t.Fatal(err)
 		}
-		if i == 0 {
-			t.Fatalf("Expecting object %q/%q not found not: err=%v", container, object, err)
+		var got []string
+		var starts []int64
+		for {
+			part, err := ranges.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if part.End-part.Start+1 != int64(len(data)) {
+				t.Errorf("Bad part range %d-%d for %q", part.Start, part.End, data)
+			}
+			got = append(got, string(data))
+			starts = append(starts, part.Start)
+		}
+		err = ranges.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) || !reflect.DeepEqual(starts, test.starts) {
+			t.Errorf("Ranges %v: want %q at %v, got %q at %v", test.ranges, test.want, test.starts, got, starts)
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
-func TestObjectDelete(t *testing.T) {
+func TestObjectGetWithRange(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	err := c.ObjectDelete(ctx, CONTAINER, OBJECT)
-	if err != nil {
-		t.Fatal(err)
-	}
-	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
-	err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
-	if err != swift.ObjectNotFound {
-		t.Fatal("Expecting Object not found", err)
+	for _, test := range []struct {
+		r    swift.Range
+		want string
+		cr   swift.ContentRange
+	}{
+		{swift.Range{Start: 1, Length: 3}, "234", swift.ContentRange{Start: 1, End: 3, Size: CONTENT_SIZE}},
+		{swift.Range{Start: 3}, "45", swift.ContentRange{Start: 3, End: 4, Size: CONTENT_SIZE}},
+	} {
+		body, cr, _, err := c.ObjectGetWithRange(ctx, CONTAINER, OBJECT, test.r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.want {
+			t.Errorf("Range %v: want %q got %q", test.r, test.want, data)
+		}
+		if cr != test.cr {
+			t.Errorf("Range %v: want %+v got %+v", test.r, test.cr, cr)
+		}
 	}
 }
 
-func TestBulkDelete(t *testing.T) {
+func TestObjectGetWithInfo(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
+	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
-	result, err := c.BulkDelete(ctx, CONTAINER, []string{OBJECT})
-	if err == swift.Forbidden {
-		t.Log("Server doesn't support BulkDelete - skipping test")
-		return
-	}
+	var buf bytes.Buffer
+	info, headers, err := c.ObjectGetWithInfo(ctx, CONTAINER, OBJECT, &buf, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if result.NumberNotFound != 1 {
-		t.Error("Expected 1, actual:", result.NumberNotFound)
-	}
-	if result.NumberDeleted != 0 {
-		t.Error("Expected 0, actual:", result.NumberDeleted)
+	if buf.String() != CONTENTS {
+		t.Error("Contents wrong")
 	}
-	err = c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	headInfo, _, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
-	result, err = c.BulkDelete(ctx, CONTAINER, []string{OBJECT2, OBJECT})
+	if info != headInfo {
+		t.Errorf("Info from GET differs from HEAD\nGET  %#v\nHEAD %#v", info, headInfo)
+	}
+	if info.Name != OBJECT || info.Bytes != CONTENT_SIZE || info.Hash != CONTENT_MD5 || info.ContentType == "" || info.LastModified.IsZero() {
+		t.Errorf("Info not fully populated: %#v", info)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+
+	// Bytes should be the size of the whole object when reading a range
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, false, swift.Headers{"Range": "bytes=1-2"})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if result.NumberNotFound != 1 {
-		t.Error("Expected 1, actual:", result.NumberNotFound)
+	if file.Info().Bytes != CONTENT_SIZE {
+		t.Errorf("Bad size for range: want %d got %d", CONTENT_SIZE, file.Info().Bytes)
 	}
-	if result.NumberDeleted != 1 {
-		t.Error("Expected 1, actual:", result.NumberDeleted)
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
-	t.Log("Errors:", result.Errors)
 }
 
-func TestBulkUpload(t *testing.T) {
+func TestObjectGetWithOpts(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	buffer := new(bytes.Buffer)
-	ds := tar.NewWriter(buffer)
-	var files = []struct{ Name, Body string }{
-		{OBJECT, CONTENTS},
-		{OBJECT2, CONTENTS2},
-	}
-	for _, file := range files {
-		hdr := &tar.Header{
-			Name: file.Name,
-			Size: int64(len(file.Body)),
-		}
-		if err := ds.WriteHeader(hdr); err != nil {
-			t.Fatal(err)
-		}
-		if _, err := ds.Write([]byte(file.Body)); err != nil {
-			t.Fatal(err)
-		}
-	}
-	if err := ds.Close(); err != nil {
-		t.Fatal(err)
-	}
-
-	result, err := c.BulkUpload(ctx, CONTAINER, buffer, swift.UploadTar, nil)
-	if err == swift.Forbidden {
-		t.Log("Server doesn't support BulkUpload - skipping test")
-		return
-	}
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte(CONTENTS)))
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
 		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	}()
+	for _, test := range []struct {
+		name string
+		meta string
+		opts swift.DownloadOpts
+		want error
+	}{
+		{"header", sum, swift.DownloadOpts{CheckHash: true, Hash: sha256.New, HashHeader: "X-Object-Meta-Sha256"}, nil},
+		{"bad header", strings.Repeat("0", 64), swift.DownloadOpts{CheckHash: true, Hash: sha256.New, HashHeader: "X-Object-Meta-Sha256"}, swift.ObjectCorrupted},
+		{"missing header", "", swift.DownloadOpts{Hash: sha256.New, HashHeader: "X-Object-Meta-Sha256"}, nil},
+		{"expected", "", swift.DownloadOpts{Hash: sha256.New, ExpectedHash: strings.ToUpper(sum)}, nil},
+		{"bad expected", "", swift.DownloadOpts{Hash: sha256.New, ExpectedHash: CONTENT_MD5}, swift.ObjectCorrupted},
+	} {
+		err = c.ObjectUpdate(ctx, CONTAINER, OBJECT, swift.Headers{"X-Object-Meta-Sha256": test.meta})
 		if err != nil {
 			t.Fatal(err)
 		}
-	}()
-	if result.NumberCreated != 2 {
-		t.Error("Expected 2, actual:", result.NumberCreated)
+		var buf bytes.Buffer
+		_, err = c.ObjectGetWithOpts(ctx, CONTAINER, OBJECT, &buf, &test.opts)
+		if err != test.want {
+			t.Errorf("%s: want %v got %v", test.name, test.want, err)
+		}
+		if buf.String() != CONTENTS {
+			t.Errorf("%s: contents wrong", test.name)
+		}
 	}
-	t.Log("Errors:", result.Errors)
+}
 
-	_, _, err = c.Object(ctx, CONTAINER, OBJECT)
+func TestObjectGetWithOptsNil(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	var buf bytes.Buffer
+	_, err := c.ObjectGetWithOpts(ctx, CONTAINER, OBJECT, &buf, nil)
 	if err != nil {
-		t.Error("Expecting object to be found")
+		t.Fatal(err)
 	}
-	_, _, err = c.Object(ctx, CONTAINER, OBJECT2)
-	if err != nil {
-		t.Error("Expecting object to be found")
+	if buf.String() != CONTENTS {
+		t.Errorf("Bad contents %q", buf.String())
 	}
 }
 
-func TestObjectDifficultName(t *testing.T) {
+func TestObjectOpenProgress(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	const name = `hello? sausage/êé/Hello, 世界/ " ' @ < > & ?/`
-	err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+
+	var read, total int64
+	file, _, err := c.ObjectOpenWithOpts(ctx, CONTAINER, OBJECT, &swift.DownloadOpts{
+		Progress: func(bytesRead int64, totalBytes int64) {
+			read, total = bytesRead, totalBytes
+		},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, name)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-	objects, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(file, buf)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	found := false
-	for _, object := range objects {
-		if object == name {
-			found = true
-			break
-		}
+	if read != 2 || total != CONTENT_SIZE {
+		t.Errorf("Read: want progress 2/%d got %d/%d", CONTENT_SIZE, read, total)
 	}
-	if !found {
-		t.Errorf("Couldn't find %q in listing %q", name, objects)
+	_, err = file.Seek(ctx, 3, 0)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestTempUrl(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
-	defer rollback()
-	err := c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "")
+	_, err = io.ReadFull(file, buf)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	m := swift.Metadata{}
-	m["temp-url-key"] = SECRET_KEY
-	err = c.AccountUpdate(ctx, m.AccountHeaders())
+	if read != 5 || total != CONTENT_SIZE {
+		t.Errorf("Seek: want progress 5/%d got %d/%d", CONTENT_SIZE, read, total)
+	}
+	err = file.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	expiresTime := time.Now().Add(20 * time.Minute)
-	tempUrl := c.ObjectTempUrl(CONTAINER, OBJECT, SECRET_KEY, "GET", expiresTime)
-	resp, err := http.Get(tempUrl)
+	read, total = 0, 0
+	progressCtx := swift.WithProgress(ctx, func(bytesRead int64, totalBytes int64) {
+		read, total = bytesRead, totalBytes
+	})
+	_, err = c.ObjectGetString(progressCtx, CONTAINER, OBJECT)
 	if err != nil {
-		t.Fatal("Failed to retrieve file from temporary url")
+		t.Fatal(err)
 	}
-	defer func() {
-		err := resp.Body.Close()
-		if err != nil {
-			t.Error("Close failed", err)
-		}
-	}()
-	if resp.StatusCode == 401 {
-		t.Log("Server doesn't support tempurl")
-	} else if resp.StatusCode != 200 {
-		t.Fatal("HTTP Error retrieving file from temporary url", resp.StatusCode)
-	} else {
-		var content []byte
-		if content, err = io.ReadAll(resp.Body); err != nil || string(content) != CONTENTS {
-			t.Error("Bad content", err)
-		}
-
-		resp, err = http.Post(tempUrl, "image/jpeg", bytes.NewReader([]byte(CONTENTS)))
-		if err != nil {
-			t.Fatal("Failed to retrieve file from temporary url")
-		}
-		defer func() {
-			err := resp.Body.Close()
-			if err != nil {
-				t.Error("Close failed", err)
-			}
-		}()
-		if resp.StatusCode != 401 {
-			t.Fatal("Expecting server to forbid access to object")
-		}
+	if read != CONTENT_SIZE || total != CONTENT_SIZE {
+		t.Errorf("ObjectGet: want progress %d/%d got %d/%d", CONTENT_SIZE, CONTENT_SIZE, read, total)
 	}
 }
 
-func TestQueryInfo(t *testing.T) {
+func TestObjectGetDecompress(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionAuth(t)
+	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
-	infos, err := c.QueryInfo(ctx)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(CONTENTS))
 	if err != nil {
-		t.Log("Server doesn't support querying info")
-		return
-	}
-	if _, ok := infos["swift"]; !ok {
-		t.Fatal("No 'swift' section found in configuration")
+		t.Fatal(err)
 	}
-}
-
-func TestDLOCreate(t *testing.T) {
-	ctx := context.Background()
-	c, rollback := makeConnectionWithSegmentsContainer(t)
-	defer rollback()
-
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
-	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	_, err = c.ObjectPut(ctx, CONTAINER, OBJECT, bytes.NewReader(compressed.Bytes()), true, "", "text/plain", swift.Headers{"Content-Encoding": "gzip"})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	}()
-
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+	for _, test := range []struct {
+		name       string
+		decompress bool
+		want       string
+	}{
+		{"Off", false, compressed.String()},
+		{"On", true, CONTENTS},
+	} {
+		var buf bytes.Buffer
+		headers, err := c.ObjectGetWithOpts(ctx, CONTAINER, OBJECT, &buf, &swift.DownloadOpts{CheckHash: true, Decompress: test.decompress})
 		if err != nil {
-			t.Fatal(err)
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s: contents wrong %q", test.name, buf.String())
+		}
+		if headers["Content-Encoding"] != "gzip" {
+			t.Errorf("%s: Content-Encoding wrong %q", test.name, headers["Content-Encoding"])
 		}
 	}
-	err = out.CloseWithContext(ctx)
-	if err != nil {
-		t.Error(err)
-	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+
+	// Objects which aren't compressed are returned as is
+	err = c.ObjectPutString(ctx, CONTAINER, OBJECT2, CONTENTS, "")
 	if err != nil {
-		t.Error(err)
-	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+		t.Fatal(err)
 	}
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	var buf bytes.Buffer
+	_, err = c.ObjectGetWithOpts(ctx, CONTAINER, OBJECT2, &buf, &swift.DownloadOpts{CheckHash: true, Decompress: true})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if info.ObjectType != swift.DynamicLargeObjectType {
-		t.Errorf("Wrong ObjectType, expected %d, got: %d", swift.DynamicLargeObjectType, info.ObjectType)
-	}
-	if info.Bytes != int64(len(expected)) {
-		t.Errorf("Wrong Bytes size, expected %d, got: %d", len(expected), info.Bytes)
+	if buf.String() != CONTENTS {
+		t.Errorf("contents wrong %q", buf.String())
 	}
 }
 
-func TestDLOInsert(t *testing.T) {
+func TestObjectOpenLength(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithDLO(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		CheckHash:   true,
-		ContentType: "image/jpeg",
-	}
-	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	_, err = fmt.Fprintf(multi, "%d%s\n", 0, CONTENTS)
+	// FIXME ideally this would check both branches of the Length() code
+	n, err := file.Length(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, _ = fmt.Fprintf(buf, "\n%d %s\n", 1, CONTENTS)
-	err = out.CloseWithContext(ctx)
-	if err != nil {
-		t.Error(err)
+	if n != CONTENT_SIZE {
+		t.Fatal("Wrong length", n, CONTENT_SIZE)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	err = file.Close()
 	if err != nil {
-		t.Error(err)
-	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+		t.Fatal(err)
 	}
 }
 
-func TestDLOAppend(t *testing.T) {
+func TestObjectOpenNotModified(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithDLO(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		Flags:       os.O_APPEND,
-		CheckHash:   true,
-		ContentType: "image/jpeg",
-	}
-	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
-	if err != nil {
+	_, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, swift.Headers{
+		"If-None-Match": CONTENT_MD5,
+	})
+	if err != swift.NotModified {
 		t.Fatal(err)
 	}
+}
 
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+func TestObjectOpenSeek(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	plan := []struct {
+		whence int
+		offset int64
+		result int64
+	}{
+		{-1, 0, 0},
+		{-1, 0, 1},
+		{-1, 0, 2},
+		{0, 0, 0},
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, 2, 2},
+		{1, 0, 3},
+		{1, -2, 2},
+		{1, 1, 4},
+		{2, -1, 4},
+		{2, -3, 2},
+		{2, -2, 3},
+		{2, -5, 0},
+		{2, -4, 1},
+	}
+
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	buf := bytes.NewBuffer([]byte(contents))
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i+10, CONTENTS)
+
+	for _, p := range plan {
+		if p.whence >= 0 {
+			var result int64
+			result, err = file.Seek(ctx, p.offset, p.whence)
+			if err != nil {
+				t.Fatal(err, p)
+			}
+			if result != p.result {
+				t.Fatal("Seek result was", result, "expecting", p.result, p)
+			}
+
+		}
+		var buf bytes.Buffer
+		var n int64
+		n, err = io.CopyN(&buf, file, 1)
 		if err != nil {
-			t.Fatal(err)
+			t.Fatal(err, p)
+		}
+		if n != 1 {
+			t.Fatal("Wrong length", n, p)
+		}
+		actual := buf.String()
+		expected := CONTENTS[p.result : p.result+1]
+		if actual != expected {
+			t.Error("Contents wrong, expecting", expected, "got", actual, p)
 		}
 	}
-	err = out.CloseWithContext(ctx)
-	if err != nil {
-		t.Error(err)
-	}
-	expected := buf.String()
-	contents, err = c.ObjectGetString(ctx, CONTAINER, OBJECT)
+
+	err = file.Close()
 	if err != nil {
-		t.Error(err)
-	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+		t.Fatal(err)
 	}
 }
 
-func TestDLOTruncate(t *testing.T) {
+// Test seeking to the end to find the file size
+func TestObjectOpenSeekEnd(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithDLO(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		Flags:       os.O_TRUNC,
-		CheckHash:   true,
-		ContentType: "image/jpeg",
+	file, _, err := c.ObjectOpen(ctx, CONTAINER, OBJECT, true, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
+	n, err := file.Seek(ctx, 0, 2) // seek to end
 	if err != nil {
 		t.Fatal(err)
 	}
+	if n != CONTENT_SIZE {
+		t.Fatal("Wrong offset", n)
+	}
 
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	_, err = fmt.Fprintf(multi, "%s", CONTENTS)
-	if err != nil {
+	// Now check reading returns EOF
+	buf := make([]byte, 16)
+	nn, err := io.ReadFull(file, buf)
+	if err != io.EOF {
 		t.Fatal(err)
 	}
-	err = out.CloseWithContext(ctx)
+	if nn != 0 {
+		t.Fatal("wrong length", n)
+	}
+
+	// Now seek back to start and check we can read the file
+	n, err = file.Seek(ctx, 0, 0) // seek to start
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if n != 0 {
+		t.Fatal("Wrong offset", n)
+	}
+
+	// read file and check contents
+	buf, err = io.ReadAll(file)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if string(buf) != CONTENTS {
+		t.Fatal("wrong contents", string(buf))
 	}
 }
 
-func TestDLOMove(t *testing.T) {
+func TestObjectUpdate(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithDLO(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	err := c.ObjectUpdate(ctx, CONTAINER, OBJECT, m1.ObjectHeaders())
 	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	err = c.DynamicLargeObjectMove(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+func TestObjectMergeMetadata(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectUpdateContentType(ctx, CONTAINER, OBJECT, "text/plain")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT2)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	contents2, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	err = c.ObjectMergeMetadata(ctx, CONTAINER, OBJECT, swift.Metadata{"Colour": "blue", "hello": "3"}, []string{"Potato-Salad"})
 	if err != nil {
 		t.Fatal(err)
 	}
+	info, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "3", "colour": "blue"})
+	if info.ContentType != "text/plain" {
+		t.Error("Content type changed", info.ContentType)
+	}
 
-	if contents2 != contents {
-		t.Error("Contents wrong")
+	err = c.ObjectMergeMetadata(ctx, CONTAINER, OBJECT2, swift.Metadata{"colour": "blue"}, nil)
+	if err != swift.ObjectNotFound {
+		t.Error("Expecting ObjectNotFound", err)
 	}
 }
 
-func TestDLONoSegmentContainer(t *testing.T) {
+func checkTime(t *testing.T, when time.Time, low, high int) {
+	dt := time.Since(when)
+	if dt < time.Duration(low)*time.Second || dt > time.Duration(high)*time.Second {
+		t.Errorf("Time is wrong: dt=%q, when=%q", dt, when)
+	}
+}
+
+func TestObject(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithDLO(t)
+	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
-	opts := swift.LargeObjectOpts{
-		Container:        CONTAINER,
-		ObjectName:       OBJECT,
-		ContentType:      "image/jpeg",
-		SegmentContainer: CONTAINER,
-	}
-	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	object, headers, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
+		t.Error("Bad object info", object)
+	}
+	checkTime(t, object.LastModified, -10, 10)
+}
 
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
-		if err != nil {
-			t.Fatal(err)
-		}
+func TestObjectStat(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	details, err := c.ObjectStat(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
 	}
-	err = out.CloseWithContext(ctx)
+	compareMaps(t, details.Metadata, map[string]string{"hello": "1", "potato-salad": "2"})
+	if details.Name != OBJECT || details.Bytes != CONTENT_SIZE || details.Hash != CONTENT_MD5 {
+		t.Error("Bad object info", details.Object)
+	}
+	if details.Headers["Etag"] != CONTENT_MD5 {
+		t.Error("Bad headers", details.Headers)
+	}
+	if !details.DeleteAt.IsZero() {
+		t.Error("Expecting no DeleteAt got", details.DeleteAt)
+	}
+
+	deleteAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	h := m1.ObjectHeaders()
+	h.SetDeleteAt(deleteAt)
+	err = c.ObjectUpdate(ctx, CONTAINER, OBJECT, h)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	details, err = c.ObjectStat(ctx, CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if !details.DeleteAt.Equal(deleteAt) {
+		t.Errorf("Bad DeleteAt want %v got %v", deleteAt, details.DeleteAt)
+	}
+
+	_, err = c.ObjectStat(ctx, CONTAINER, "notfound")
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
 }
 
-func TestDLOCreateMissingSegmentsInList(t *testing.T) {
+func TestObjectUpdate2(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithContainer(t)
+	c, rollback := makeConnectionWithObjectHeaders(t)
 	defer rollback()
-
-	if srv == nil {
-		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
-		return
+	err := c.ObjectUpdate(ctx, CONTAINER, OBJECT, m2.ObjectHeaders())
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + SEGMENTS_CONTAINER
-	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
-		for k, v := range recorder.Result().Header {
-			w.Header().Set(k, v[0])
-		}
-		w.WriteHeader(recorder.Code)
-		_, _ = w.Write([]byte("null\n"))
-	})
-	defer srv.UnsetOverride(listURL)
-
-	headers := swift.Headers{}
-	err := c.ContainerCreate(ctx, SEGMENTS_CONTAINER, headers)
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ContainerDelete(ctx, SEGMENTS_CONTAINER)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "", "potato-salad": ""})
+}
 
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
-	}
-	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+func TestContainers(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	containers, err := c.Containers(ctx, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
-		if err != nil {
-			t.Fatal(err)
+	ok := false
+	for _, container := range containers {
+		if container.Name == CONTAINER {
+			ok = true
+			// Container may or may not have the file contents in it
+			// Swift updates may be behind
+			if container.Count == 0 && container.Bytes == 0 {
+				break
+			}
+			if container.Count == 1 && container.Bytes == CONTENT_SIZE {
+				break
+			}
+			t.Errorf("Bad size of Container %q: %+v", CONTAINER, container)
+			break
 		}
 	}
-	err = out.CloseWithContext(ctx)
-	if err != nil {
-		t.Error(err)
+	if !ok {
+		t.Errorf("Didn't find container %q in listing %+v", CONTAINER, containers)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+}
+
+func TestObjectNames(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNames(ctx, CONTAINER, nil)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
 	}
 }
 
-func TestDLOCreateIncorrectSize(t *testing.T) {
+func TestObjectNamesJSON(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
 	defer rollback()
 
 	if srv == nil {
-		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a gateway returning JSON.")
 		return
 	}
 
-	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
-	headCount := 0
-	expectedHeadCount := 5
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER
 	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
-		for k, v := range recorder.Result().Header {
-			w.Header().Set(k, v[0])
-		}
-		if r.Method == "HEAD" {
-			headCount++
-			if headCount < expectedHeadCount {
-				w.Header().Set("Content-Length", "7")
-			}
-		}
-		w.WriteHeader(recorder.Code)
-		_, _ = w.Write(recorder.Body.Bytes())
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`[{"name":"` + OBJECT + `","bytes":5},{"subdir":"dir/"}]`))
 	})
 	defer srv.UnsetOverride(listURL)
 
-	headers := swift.Headers{}
-	err := c.ContainerCreate(ctx, SEGMENTS_CONTAINER, headers)
+	objects, err := c.ObjectNames(ctx, CONTAINER, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.ContainerDelete(ctx, SEGMENTS_CONTAINER)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
+	if want := []string{OBJECT, "dir/"}; !reflect.DeepEqual(objects, want) {
+		t.Errorf("Incorrect listing want %q got %q", want, objects)
 	}
-	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+}
+
+func TestObjectNamesAll(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectNamesAllWithLimit(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNamesAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsWalk(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects := make([]string, 0)
+	err := c.ObjectsWalk(ctx, container, nil, func(ctx context.Context, opts *swift.ObjectsOpts) (interface{}, error) {
+		newObjects, err := c.ObjectNames(ctx, CONTAINER, opts)
+		if err == nil {
+			objects = append(objects, newObjects...)
+		}
+		return newObjects, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsWalkCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT2, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(context.Background(), CONTAINER, OBJECT2)
+	}()
+	pages := 0
+	err = c.ObjectsWalk(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1}, func(ctx context.Context, opts *swift.ObjectsOpts) (interface{}, error) {
+		pages++
+		newObjects, err := c.ObjectNames(ctx, CONTAINER, opts)
+		// Cancel after the first page is fetched
+		cancel()
+		return newObjects, err
+	})
+	if err != context.Canceled {
+		t.Errorf("Expecting context.Canceled got %v", err)
+	}
+	if pages != 1 {
+		t.Errorf("Expecting 1 page to be fetched got %d", pages)
+	}
+}
+
+func TestObjectsAllPrefixEndMarker(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{"a/1", "a/2", "a/3", "a/4", "a/5", "b/1"}
+	for _, name := range names {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			_ = c.ObjectDelete(ctx, CONTAINER, name)
+		}
+	}()
+	for _, fetchUntilEmptyPage := range []bool{false, true} {
+		c.FetchUntilEmptyPage = fetchUntilEmptyPage
+		for _, test := range []struct {
+			opts swift.ObjectsOpts
+			want []string
+		}{
+			{swift.ObjectsOpts{Prefix: "a/", Marker: "a/1", KeepMarker: true, EndMarker: "a/4", Limit: 1}, []string{"a/2", "a/3"}},
+			{swift.ObjectsOpts{Prefix: "a/", EndMarker: "a/4", Limit: 2}, []string{"a/1", "a/2", "a/3"}},
+			{swift.ObjectsOpts{Prefix: "a/", EndMarker: "a/2", Reverse: true, Limit: 1}, []string{"a/5", "a/4", "a/3"}},
+		} {
+			objects, err := c.ObjectsAll(ctx, CONTAINER, &test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, object := range objects {
+				got = append(got, object.Name)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FetchUntilEmptyPage=%v %+v: want %q got %q", fetchUntilEmptyPage, test.opts, test.want, got)
+			}
+		}
+	}
+	c.FetchUntilEmptyPage = false
+}
+
+func TestObjects(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatal("Should only be 1 object")
+	}
+	object := objects[0]
+	if object.Name != OBJECT || object.Bytes != CONTENT_SIZE || object.ContentType != "application/octet-stream" || object.Hash != CONTENT_MD5 || object.PseudoDirectory != false || object.SubDir != "" {
+		t.Error("Bad object info", object)
+	}
+	checkTime(t, object.LastModified, -10, 10)
+}
+
+func TestObjectsEmpty(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	objects, err := c.Objects(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objects == nil || len(objects) != 0 {
+		t.Errorf("Expecting empty non nil listing got %#v", objects)
+	}
+}
+
+func TestObjectsPartialPage(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to return a broken listing.")
+		return
+	}
+
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER
+	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`[{"name":"` + OBJECT + `","bytes":5},{"name":`))
+	})
+	defer srv.UnsetOverride(listURL)
+
+	objects, err := c.Objects(ctx, CONTAINER, nil)
+	if err == nil {
+		t.Fatal("Expecting error from truncated listing")
+	}
+	if len(objects) != 1 || objects[0].Name != OBJECT {
+		t.Errorf("Expecting the objects before the error got %v", objects)
+	}
+}
+
+func TestObjectsDirectory(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectPutString(ctx, CONTAINER, "directory", "", "application/directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, "directory")
+	}()
+
+	// Look for the directory object and check we aren't confusing
+	// it with a pseudo directory object
+	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatal("Should only be 2 objects")
+	}
+	found := false
+	for i := range objects {
+		object := objects[i]
+		if object.Name == "directory" {
+			found = true
+			if object.Bytes != 0 || object.ContentType != "application/directory" || object.Hash != "d41d8cd98f00b204e9800998ecf8427e" || object.PseudoDirectory != false || object.SubDir != "" {
+				t.Error("Bad object info", object)
+			}
+			checkTime(t, object.LastModified, -10, 10)
+		}
+	}
+	if !found {
+		t.Error("Didn't find directory object")
+	}
+}
+
+func TestObjectsPseudoDirectory(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectPutString(ctx, CONTAINER, "directory/puppy.jpg", "cute puppy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, "directory/puppy.jpg")
+	}()
+
+	// Look for the pseudo directory
+	objects, err := c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 {
+		t.Fatal("Should only be 2 objects", objects)
+	}
+	found := false
+	for i := range objects {
+		object := objects[i]
+		if object.Name == "directory/" {
+			found = true
+			if object.Bytes != 0 || object.ContentType != "application/directory" || object.Hash != "" || object.PseudoDirectory != true || object.SubDir != "directory/" && object.LastModified.IsZero() {
+				t.Error("Bad object info", object)
+			}
+		}
+	}
+	if !found {
+		t.Error("Didn't find directory object", objects)
+	}
+
+	// Look in the pseudo directory now
+	objects, err = c.Objects(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: "directory/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatal("Should only be 1 object", objects)
+	}
+	object := objects[0]
+	if object.Name != "directory/puppy.jpg" || object.Bytes != 10 || object.ContentType != "image/jpeg" || object.Hash != "87a12ea22fca7f54f0cefef1da535489" || object.PseudoDirectory != false || object.SubDir != "" {
+		t.Error("Bad object info", object)
+	}
+	checkTime(t, object.LastModified, -10, 10)
+}
+
+func TestObjectsAll(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectsAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsInfo(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{"info/a", "info/b", "info/c", "other"}
+	for _, name := range names {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS+name, "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			_ = c.ObjectDelete(ctx, CONTAINER, name)
+		}(name)
+	}
+	infos, err := c.ObjectsInfo(ctx, CONTAINER, []string{"info/c", "info/a", "info/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("want 2 objects got %+v", infos)
+	}
+	for _, name := range []string{"info/a", "info/c"} {
+		want, _, err := c.Object(ctx, CONTAINER, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := infos[name]
+		if got.Name != name || got.Bytes != want.Bytes || got.Hash != want.Hash || !strings.HasPrefix(got.ContentType, "text/plain") {
+			t.Errorf("%s: want %+v got %+v", name, want, got)
+		}
+	}
+}
+
+func TestObjectsAllWithLimit(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectsAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Name != OBJECT {
+		t.Error("Incorrect listing", objects)
+	}
+}
+
+func TestObjectsPage(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			_ = c.ObjectDelete(ctx, CONTAINER, name)
+		}(name)
+	}
+
+	var pages [][]string
+	opts := swift.ObjectsOpts{Limit: 2}
+	for {
+		objects, nextMarker, err := c.ObjectsPage(ctx, CONTAINER, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var page []string
+		for _, object := range objects {
+			page = append(page, object.Name)
+		}
+		pages = append(pages, page)
+		if nextMarker == "" {
+			break
+		}
+		opts.Marker = nextMarker
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("Bad pages want %q got %q", want, pages)
+	}
+}
+
+func TestObjectsAllReverse(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	names := []string{OBJECT, OBJECT2, OBJECT + "3"}
+	for _, name := range names {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, name := range names {
+			err := c.ObjectDelete(ctx, CONTAINER, name)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	forward, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := len(forward) - 1; i >= 0; i-- {
+		want = append(want, forward[i])
+	}
+	got, err := c.ObjectNamesAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 1, Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect reverse listing want %q got %q", want, got)
+	}
+	objects, err := c.ObjectsAll(ctx, CONTAINER, &swift.ObjectsOpts{Limit: 2, Reverse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, object := range objects {
+		got = append(got, object.Name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect reverse listing want %q got %q", want, got)
+	}
+}
+
+func TestObjectNamesWithPath(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	objects, err := c.ObjectNames(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != OBJECT {
+		t.Error("Bad listing with path", objects)
+	}
+	// fmt.Println(objects)
+	objects, err = c.ObjectNames(ctx, CONTAINER, &swift.ObjectsOpts{Delimiter: '/', Path: "Downloads/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 0 {
+		t.Error("Bad listing with path", objects)
+	}
+}
+
+func TestObjectSetExpiry(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	deleteAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := c.ObjectSetExpiry(ctx, CONTAINER, OBJECT, deleteAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.FormatInt(deleteAt.Unix(), 10); headers["X-Delete-At"] != want {
+		t.Errorf("Bad X-Delete-At: want %q got %q", want, headers["X-Delete-At"])
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+
+	err = c.ObjectSetExpiryAfter(ctx, CONTAINER, OBJECT, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := strconv.ParseInt(headers["X-Delete-At"], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Now().Add(2 * time.Hour).Unix(); got < want-60 || got > want+60 {
+		t.Errorf("Bad X-Delete-At: want about %d got %d", want, got)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+}
+
+func TestObjectSetExpiryKeepsHeaders(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	keep := swift.Headers{
+		"Cache-Control":       "max-age=60",
+		"Content-Disposition": "attachment; filename=potato.txt",
+		"Content-Encoding":    "identity",
+	}
+	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT2, strings.NewReader(CONTENTS), true, "", "", keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2) // Ignore error
+	}()
+	err = c.ObjectSetExpiry(ctx, CONTAINER, OBJECT2, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range keep {
+		if headers[key] != want {
+			t.Errorf("%s: want %q got %q", key, want, headers[key])
+		}
+	}
+}
+
+func TestObjectPutWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	deleteAt := time.Now().Add(time.Hour)
+	want := strconv.FormatInt(deleteAt.Unix(), 10)
+	h := swift.Headers{}
+	h.SetDeleteAt(deleteAt)
+	_, err := c.ObjectPut(ctx, CONTAINER, OBJECT2, bytes.NewBufferString(CONTENTS), true, "", "", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	}()
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Delete-At"] != want {
+		t.Errorf("Bad X-Delete-At: want %q got %q", want, headers["X-Delete-At"])
+	}
+
+	out, err := c.DynamicLargeObjectCreate(ctx, &swift.LargeObjectOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+		DeleteAt:   deleteAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprint(out, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	}()
+	_, headers, err = c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Delete-At"] != want {
+		t.Errorf("Bad manifest X-Delete-At: want %q got %q", want, headers["X-Delete-At"])
+	}
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments {
+		_, headers, err = c.Object(ctx, segmentContainer, segment.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers["X-Delete-At"] != want {
+			t.Errorf("Bad segment X-Delete-At: want %q got %q", want, headers["X-Delete-At"])
+		}
+	}
+}
+
+func TestObjectCopy(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectCopyDifficultName(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	const dest = OBJECT + "?param %30%31%32 £100"
+	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, dest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDelete(ctx, CONTAINER, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectCopyWithMetadata(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	m := swift.Metadata{}
+	m["copy-special-metadata"] = "hello"
+	m["hello"] = "9"
+	h := m.ObjectHeaders()
+	h["Content-Type"] = "image/jpeg"
+	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Re-read the metadata to see if it is correct
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Content-Type"] != "image/jpeg" {
+		t.Error("Didn't change content type")
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "9", "potato-salad": "2", "copy-special-metadata": "hello"})
+}
+
+func TestObjectCopyRange(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	_, srcHeaders, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := swift.Headers{
+		"Content-Disposition": "attachment; filename=potato.txt",
+		"Content-Encoding":    "identity",
+	}
+	h := srcHeaders.ObjectMetadata().ObjectHeaders()
+	for key, value := range keep {
+		h[key] = value
+	}
+	err = c.ObjectUpdate(ctx, CONTAINER, OBJECT, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		start  int64
+		length int64
+		want   string
+	}{
+		{0, 3, CONTENTS[:3]},
+		{1, 2, CONTENTS[1:3]},
+		{2, 0, CONTENTS[2:]},
+		{0, 100, CONTENTS},
+	} {
+		_, err := c.ObjectCopyRange(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, test.start, test.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != test.want {
+			t.Errorf("start %d length %d: want %q got %q", test.start, test.length, test.want, contents)
+		}
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+	for key, want := range keep {
+		if headers[key] != want {
+			t.Errorf("%s: want %q got %q", key, want, headers[key])
+		}
+	}
+
+	_, err = c.ObjectCopyRange(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, -1, 0)
+	if err == nil {
+		t.Error("Expecting error for bad range")
+	}
+}
+
+func TestObjectCopyWithExpiry(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	deleteAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := c.ObjectSetExpiry(ctx, CONTAINER, OBJECT, deleteAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strconv.FormatInt(deleteAt.Unix(), 10)
+	checkDeleteAt := func(objectName string, want string) {
+		t.Helper()
+		_, headers, err := c.Object(ctx, CONTAINER, objectName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if headers["X-Delete-At"] != want {
+			t.Errorf("%s: bad X-Delete-At: want %q got %q", objectName, want, headers["X-Delete-At"])
+		}
+		compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+	}
+
+	// Expiry is carried over by default
+	_, err = c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	checkDeleteAt(OBJECT2, want)
+
+	// Expiry is dropped if requested
+	_, err = c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, swift.Headers{"X-Delete-At": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDeleteAt(OBJECT2, "")
+
+	// Expiry survives a move
+	err = c.ObjectMove(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT+"-moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDeleteAt(OBJECT+"-moved", want)
+	err = c.ObjectMove(ctx, CONTAINER, OBJECT+"-moved", CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectCopySymlinkExpiry(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	err := c.ObjectSetExpiry(ctx, CONTAINER, OBJECT, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ObjectSymlinkCreate(ctx, CONTAINER, SYMLINK_OBJECT, "", CONTAINER, OBJECT, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT)
+		_ = c.ObjectDelete(ctx, CONTAINER, SYMLINK_OBJECT2)
+	}()
+
+	// The copy of the link doesn't pick up the expiry of its target
+	_, err = c.ObjectCopy(ctx, CONTAINER, SYMLINK_OBJECT, CONTAINER, SYMLINK_OBJECT2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.ObjectNoFollow(ctx, CONTAINER, SYMLINK_OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Delete-At"] != "" {
+		t.Errorf("Expecting no X-Delete-At on the copied link got %q", headers["X-Delete-At"])
+	}
+}
+
+func TestObjectCopyHeadFails(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to inject failures.")
+		return
+	}
+	defer func() {
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+	}()
+
+	// The HEAD for the expiry fails but the copy goes ahead
+	objectURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	srv.SetFailure(objectURL, 1, http.StatusInternalServerError)
+	_, err := c.ObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
+	}
+}
+
+func TestObjectMove(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectMove(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ObjectMove(ctx, CONTAINER, OBJECT2, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT2)
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+}
+
+func TestObjectMoveAccount(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it needs a second account.")
+		return
+	}
+	const account = "AUTH_GoSwiftUnitTestOther"
+	srv.AddAccount(strings.TrimPrefix(account, "AUTH_"))
+	otherUrl := c.StorageUrl[:strings.LastIndex(c.StorageUrl, "/")+1] + account
+	call := func(operation string, objectName string) (swift.Headers, error) {
+		_, headers, err := c.Call(ctx, otherUrl, swift.RequestOpts{
+			Container:  CONTAINER,
+			ObjectName: objectName,
+			Operation:  operation,
+			ErrorMap:   swift.ObjectErrorMap,
+			NoResponse: true,
+		})
+		return headers, err
+	}
+
+	_, err := c.ObjectCopyAccount(ctx, CONTAINER, OBJECT, account, CONTAINER, OBJECT2, nil)
+	if err != swift.ContainerNotFound {
+		t.Fatalf("Expecting ContainerNotFound got %v", err)
+	}
+
+	_, err = call("PUT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = call("DELETE", OBJECT2)
+		_, _ = call("DELETE", "")
+	}()
+	err = c.ObjectMoveAccount(ctx, CONTAINER, OBJECT, account, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
+	headers, err := call("HEAD", OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+	// Put the object back for the rollback
+	err = c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectMoveVerified(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectMoveVerified(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+
+	err = c.ObjectMove(ctx, CONTAINER, OBJECT2, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectMoveVerifiedBadCopy(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a bad copy.")
+		return
+	}
+
+	// Make the copied object look different to the source
+	dstURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT2
+	srv.SetOverride(dstURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		if r.Method == "HEAD" {
+			w.Header().Set("Etag", "00000000000000000000000000000000")
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(dstURL)
+
+	err := c.ObjectMoveVerified(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	if err != swift.ObjectCopyMismatch {
+		t.Fatalf("Expecting ObjectCopyMismatch got %v", err)
+	}
+	srv.UnsetOverride(dstURL)
+
+	// The bad copy is removed but the source must still be there
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT2)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting bad copy to be deleted got %v", err)
+	}
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObjectWeakEtag(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to send weak ETags.")
+		return
+	}
+
+	// Send the ETag back weak and quoted as some clusters do
+	objectURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	srv.SetOverride(objectURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		if etag := recorder.Header().Get("Etag"); etag != "" {
+			w.Header().Set("Etag", `W/"`+strings.ToUpper(etag)+`"`)
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(objectURL)
+
+	headers, err := c.ObjectPut(ctx, CONTAINER, OBJECT, bytes.NewBufferString(CONTENTS), true, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if headers["Etag"] != strings.ToUpper(CONTENT_MD5) {
+		t.Errorf("Bad Etag header want %q got %q", strings.ToUpper(CONTENT_MD5), headers["Etag"])
+	}
+
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS {
+		t.Errorf("Bad contents %q", contents)
+	}
+
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(info.Hash, CONTENT_MD5) {
+		t.Errorf("Bad hash want %q got %q", CONTENT_MD5, info.Hash)
+	}
+}
+
+func TestObjectUpdateContentType(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObjectHeaders(t)
+	defer rollback()
+	err := c.ObjectUpdateContentType(ctx, CONTAINER, OBJECT, "text/potato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-read the metadata to see if it is correct
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Content-Type"] != "text/potato" {
+		t.Error("Didn't change content type")
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+}
+
+func TestVersionContainerCreate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	err := c.VersionContainerCreate(ctx, CURRENT_CONTAINER, VERSIONS_CONTAINER)
+	defer func() {
+		_ = c.ContainerDelete(ctx, CURRENT_CONTAINER)
+		_ = c.ContainerDelete(ctx, VERSIONS_CONTAINER)
+	}()
+	if err != nil {
+		if err == swift.Forbidden {
+			t.Log("Server doesn't support Versions - skipping test")
+			return
+		}
+		t.Fatal(err)
+	}
+}
+
+func TestVersionEnableHistory(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	for _, container := range []string{CURRENT_CONTAINER, VERSIONS_CONTAINER} {
+		err := c.ContainerCreate(ctx, container, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		_ = c.ContainerDelete(ctx, CURRENT_CONTAINER)
+		_ = c.ContainerDelete(ctx, VERSIONS_CONTAINER)
+	}()
+	err := c.VersionEnableMode(ctx, CURRENT_CONTAINER, VERSIONS_CONTAINER, swift.VersioningHistory)
+	if err != nil {
+		if err == swift.Forbidden {
+			t.Log("Server doesn't support History - skipping test")
+			return
+		}
+		t.Fatal(err)
+	}
+	_, headers, err := c.Container(ctx, CURRENT_CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-History-Location"] != VERSIONS_CONTAINER {
+		t.Errorf("Bad X-History-Location %q", headers["X-History-Location"])
+	}
+	err = c.VersionDisable(ctx, CURRENT_CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Container(ctx, CURRENT_CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-History-Location"] != "" {
+		t.Errorf("X-History-Location not removed: %q", headers["X-History-Location"])
+	}
+}
+
+func TestVersionEnableObjects(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.VersionEnableMode(ctx, CONTAINER, "", swift.VersioningObject)
+	if err == swift.VersioningNotSupported {
+		t.Log("Server doesn't support object versioning - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err := c.Container(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(headers["X-Versions-Enabled"], "true") {
+		t.Errorf("Bad X-Versions-Enabled %q", headers["X-Versions-Enabled"])
+	}
+	err = c.VersionDisableObjects(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, headers, err = c.Container(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(headers["X-Versions-Enabled"], "false") {
+		t.Errorf("Bad X-Versions-Enabled %q", headers["X-Versions-Enabled"])
+	}
+}
+
+func TestQueryInfoUrl(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it needs a second info URL.")
+		return
+	}
+
+	// The info is served from a path prefix the storage URL doesn't have
+	infoPath := "/prefix/info"
+	srv.SetOverride(infoPath, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"swift": {"version": "9.9"}}`))
+	})
+	defer srv.UnsetOverride(infoPath)
+	c.InfoUrl = strings.TrimSuffix(srv.AuthURL, "/v1.0") + infoPath
+
+	info, err := c.QueryInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swiftInfo, _ := info["swift"].(map[string]interface{})
+	if swiftInfo["version"] != "9.9" {
+		t.Errorf("Expecting info from InfoUrl got %v", info)
+	}
+}
+
+func TestInfoCache(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+
+	info, err := c.QueryInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, fetched := c.CachedInfo()
+	if cached == nil || time.Since(fetched) > time.Minute {
+		t.Fatalf("Expecting info to be cached got %v at %v", cached, fetched)
+	}
+	c.InvalidateInfo()
+	if cached, _ = c.CachedInfo(); cached != nil {
+		t.Errorf("Expecting info to be invalidated got %v", cached)
+	}
+
+	// Stale info is read again when it is needed
+	old := time.Now().Add(-2 * time.Hour)
+	c.SetCachedInfo(info, old)
+	_ = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	if _, fetched = c.CachedInfo(); !fetched.After(old) {
+		t.Error("Expecting stale info to be refreshed")
+	}
+
+	// Unless it is cached for ever
+	c.InfoCacheTTL = -1
+	c.SetCachedInfo(info, old)
+	_ = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	if _, fetched = c.CachedInfo(); !fetched.Equal(old) {
+		t.Error("Expecting info not to be refreshed")
+	}
+}
+
+func TestVersionEnableObjectsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to hide object versioning.")
+		return
+	}
+
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		_, _ = w.Write([]byte(`{"swift": {"version": "1.2"}}`))
+	})
+	defer srv.UnsetOverride("/info")
+	_, _ = c.QueryInfo(ctx)
+
+	err := c.VersionEnableObjects(ctx, CONTAINER)
+	if err != swift.VersioningNotSupported {
+		t.Fatalf("Expecting VersioningNotSupported got %v", err)
+	}
+}
+
+func TestObjectVersions(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a versions listing.")
+		return
+	}
+
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER
+	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		if _, ok := r.URL.Query()["versions"]; !ok || r.URL.Query().Get("prefix") != OBJECT {
+			t.Errorf("Bad versions listing request %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"name": "` + OBJECT + `", "version_id": "2", "is_latest": true, "bytes": 5, "hash": "` + CONTENT2_MD5 + `", "content_type": "text/plain", "last_modified": "2021-01-02T03:04:05.678900"},
+			{"name": "` + OBJECT + `", "version_id": "1", "is_latest": false, "bytes": 5, "hash": "` + CONTENT_MD5 + `", "content_type": "text/plain", "last_modified": "2021-01-01T03:04:05.678900"},
+			{"name": "` + OBJECT2 + `", "version_id": "3", "is_latest": true, "bytes": 5, "hash": "` + CONTENT_MD5 + `", "content_type": "text/plain", "last_modified": "2021-01-01T03:04:05.678900"}
+		]`))
+	})
+	defer srv.UnsetOverride(listURL)
+
+	versions, err := c.ObjectVersions(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expecting 2 versions got %d: %+v", len(versions), versions)
+	}
+	if versions[0].VersionId != "2" || !versions[0].IsLatest || versions[0].Hash != CONTENT2_MD5 || versions[0].Bytes != 5 {
+		t.Errorf("Bad latest version %+v", versions[0])
+	}
+	if versions[1].VersionId != "1" || versions[1].IsLatest || versions[1].Hash != CONTENT_MD5 {
+		t.Errorf("Bad old version %+v", versions[1])
+	}
+	want := time.Date(2021, 1, 1, 3, 4, 5, 0, time.UTC)
+	if !versions[1].LastModified.Equal(want) {
+		t.Errorf("Bad LastModified want %v got %v", want, versions[1].LastModified)
+	}
+}
+
+func TestObjectVersionsNotSupported(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to hide object versioning.")
+		return
+	}
+
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		_, _ = w.Write([]byte(`{"swift": {"version": "1.2"}}`))
+	})
+	defer srv.UnsetOverride("/info")
+	_, _ = c.QueryInfo(ctx)
+
+	_, err := c.ObjectVersions(ctx, CONTAINER, OBJECT)
+	if err != swift.VersioningNotSupported {
+		t.Fatalf("Expecting VersioningNotSupported got %v", err)
+	}
+}
+
+func TestObjectDeleteRestoreVersion(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate object versions.")
+		return
+	}
+
+	// Pretend version "1" of OBJECT exists
+	var requests []string
+	objectURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	srv.SetOverride(objectURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		requests = append(requests, r.Method+" "+r.URL.RawQuery+" "+r.Header.Get("Destination"))
+		if r.URL.Query().Get("version-id") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "COPY":
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	defer srv.UnsetOverride(objectURL)
+
+	err := c.ObjectRestoreVersion(ctx, CONTAINER, OBJECT, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDeleteVersion(ctx, CONTAINER, OBJECT, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectRestoreVersion(ctx, CONTAINER, OBJECT, "2")
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
+	}
+	err = c.ObjectDeleteVersion(ctx, CONTAINER, OBJECT, "2")
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
+	}
+
+	want := []string{
+		"COPY version-id=1 " + CONTAINER + "/" + OBJECT,
+		"DELETE version-id=1 ",
+		"COPY version-id=2 " + CONTAINER + "/" + OBJECT,
+		"DELETE version-id=2 ",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Bad requests want %q got %q", want, requests)
+	}
+}
+
+func TestVersionObjectAdd(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithVersionsContainer(t)
+	defer rollback()
+	if skipVersionTests {
+		t.Log("Server doesn't support Versions - skipping test")
+		return
+	}
+	// Version 1
+	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+
+	// Version 2
+	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS2, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS2 {
+		t.Error("Contents wrong")
+	}
+
+	// Version 3
+	if err := c.ObjectPutString(ctx, CURRENT_CONTAINER, OBJECT, CONTENTS2, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+}
+
+func TestVersionObjectList(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithVersionsObject(t)
+	defer rollback()
+	if skipVersionTests {
+		t.Log("Server doesn't support Versions - skipping test")
+		return
+	}
+	list, err := c.VersionObjectList(ctx, VERSIONS_CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 {
+		t.Error("Version list should return 2 objects")
+	}
+}
+
+func TestVersionObjectDelete(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithVersionsObject(t)
+	defer rollback()
+	if skipVersionTests {
+		t.Log("Server doesn't support Versions - skipping test")
+		return
+	}
+	// Delete Version 3
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete Version 2
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+
+	// Contents should be reverted to Version 1
+	if contents, err := c.ObjectGetString(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	} else if contents != CONTENTS {
+		t.Error("Contents wrong")
+	}
+}
+
+func TestVersionDeleteContent(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithVersionsObject(t)
+	defer rollback()
+	if skipVersionTests {
+		t.Log("Server doesn't support Versions - skipping test")
+		return
+	}
+	// Delete Version 3
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+	// Delete Version 2
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+	// Delete Version 1
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ObjectDelete(ctx, CURRENT_CONTAINER, OBJECT); err != swift.ObjectNotFound {
+		t.Fatalf("Expecting Object not found error, got: %v", err)
+	}
+}
+
+// Check for non existence after delete
+// May have to do it a few times to wait for swift to be consistent.
+func testExistenceAfterDelete(t *testing.T, c *swift.Connection, container, object string) {
+	ctx := context.Background()
+	for i := 10; i <= 0; i-- {
+		_, _, err := c.Object(ctx, container, object)
+		if err == swift.ObjectNotFound {
+			break
+		}
+		if i == 0 {
+			t.Fatalf("Expecting object %q/%q not found not: err=%v", container, object, err)
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func TestObjectDelete(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	err := c.ObjectDelete(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testExistenceAfterDelete(t, c, CONTAINER, OBJECT)
+	err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+	if err != swift.ObjectNotFound {
+		t.Fatal("Expecting Object not found", err)
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	result, err := c.BulkDelete(ctx, CONTAINER, []string{OBJECT})
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkDelete - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+	if result.NumberDeleted != 0 {
+		t.Error("Expected 0, actual:", result.NumberDeleted)
+	}
+	err = c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = c.BulkDelete(ctx, CONTAINER, []string{OBJECT2, OBJECT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+	if result.NumberDeleted != 1 {
+		t.Error("Expected 1, actual:", result.NumberDeleted)
+	}
+	t.Log("Errors:", result.Errors)
+}
+
+func TestBulkDeleteChunked(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to lower the bulk delete limit.")
+		return
+	}
+
+	srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		_, _ = w.Write([]byte(`{"swift": {"version": "1.2"}, "bulk_delete": {"max_deletes_per_request": 2}}`))
+	})
+	_, err := c.QueryInfo(ctx)
+	srv.UnsetOverride("/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	accountURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT
+	srv.SetOverride(accountURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		if r.Method == "DELETE" {
+			atomic.AddInt32(&requests, 1)
+		}
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(accountURL)
+
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names[:4] {
+		err = c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := c.BulkDelete(ctx, CONTAINER, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, actual: %d", requests)
+	}
+	if result.NumberDeleted != 4 {
+		t.Error("Expected 4, actual:", result.NumberDeleted)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+}
+
+func TestBulkDeletePaths(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	if srv != nil {
+		// Lower the limit so the paths are split between requests
+		srv.SetOverride("/info", func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+			_, _ = w.Write([]byte(`{"swift": {"version": "1.2"}, "bulk_delete": {"max_deletes_per_request": 2}}`))
+		})
+		_, err := c.QueryInfo(ctx)
+		srv.UnsetOverride("/info")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := []string{
+		CONTAINER + "/" + OBJECT,
+		SEGMENTS_CONTAINER + "/" + OBJECT,
+		CONTAINER + "/" + OBJECT2,
+		"/" + SEGMENTS_CONTAINER + "/" + OBJECT2,
+		CONTAINER + "/missing",
+	}
+	for _, path := range paths[:4] {
+		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+		err := c.ObjectPutString(ctx, parts[0], parts[1], CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := c.BulkDeletePaths(ctx, paths, 2)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkDelete - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NumberDeleted != 4 {
+		t.Error("Expected 4, actual:", result.NumberDeleted)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+}
+
+func TestBulkDeleteContainers(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	err := c.ContainerCreate(ctx, CONTAINER+"Empty", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	result, err := c.BulkDeletePaths(ctx, []string{CONTAINER + "Empty", CONTAINER, CONTAINER + "Missing"}, 1)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkDelete - skipping test")
+		return
+	}
+	if err == nil {
+		t.Error("Expecting error deleting non empty container")
+	}
+	if result.NumberDeleted != 1 {
+		t.Error("Expected 1, actual:", result.NumberDeleted)
+	}
+	if result.NumberNotFound != 1 {
+		t.Error("Expected 1, actual:", result.NumberNotFound)
+	}
+	if swiftErr, ok := result.Errors["/"+CONTAINER].(*swift.Error); len(result.Errors) != 1 || !ok || swiftErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expecting 409 error for %q got %v", CONTAINER, result.Errors)
+	}
+	_, _, err = c.Container(ctx, CONTAINER+"Empty")
+	if err != swift.ContainerNotFound {
+		t.Errorf("Expecting ContainerNotFound got %v", err)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	for _, name := range []string{"dir/a", "dir/b", "other"} {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		err := c.ObjectDelete(ctx, CONTAINER, "other")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	out, err := c.DynamicLargeObjectCreate(ctx, &swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  "dir/dlo",
+		ContentType: "image/jpeg",
+		ChunkSize:   6,
+		NoBuffer:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(out, "%s %s\n", CONTENTS, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, failures, err := c.DeletePrefix(ctx, CONTAINER, "dir/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 || len(failures) != 0 {
+		t.Errorf("Expecting 3 deleted with no failures, got %d, %v", deleted, failures)
+	}
+	names, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"other"}) {
+		t.Errorf("Wrong objects left %q", names)
+	}
+	segments, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("Segments not deleted %q", segments)
+	}
+}
+
+func TestContainerDeleteRecursive(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	for _, name := range []string{"dir/a", "dir/b", "other"} {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	createMultiSegmentObject(t, c.StaticLargeObjectCreate)
+
+	deleted, failures, err := c.ContainerDeleteRecursive(ctx, CONTAINER, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 4 || len(failures) != 0 {
+		t.Errorf("Expecting 4 deleted with no failures, got %d, %v", deleted, failures)
+	}
+	_, _, err = c.Container(ctx, CONTAINER)
+	if err != swift.ContainerNotFound {
+		t.Errorf("Expecting ContainerNotFound got %v", err)
+	}
+	segments, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("Segments not deleted %q", segments)
+	}
+
+	_, _, err = c.ContainerDeleteRecursive(ctx, CONTAINER, 2)
+	if err != swift.ContainerNotFound {
+		t.Errorf("Expecting ContainerNotFound got %v", err)
+	}
+}
+
+func TestContainerDeleteRecursiveListConsistencyDelay(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
+		return
+	}
+
+	err := c.ObjectPutString(ctx, CONTAINER, OBJECT, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The object only shows up in the listing after the first two
+	// passes have been tried so it relies on the wait between them
+	srv.SetListConsistencyDelay(150 * time.Millisecond)
+	defer srv.SetListConsistencyDelay(0)
+
+	deleted, failures, err := c.ContainerDeleteRecursive(ctx, CONTAINER, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 || len(failures) != 0 {
+		t.Errorf("Expecting 1 deleted with no failures, got %d, %v", deleted, failures)
+	}
+	_, _, err = c.Container(ctx, CONTAINER)
+	if err != swift.ContainerNotFound {
+		t.Errorf("Expecting ContainerNotFound got %v", err)
+	}
+}
+
+func TestContainerCopy(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	err := c.ContainerCreate(ctx, COPY_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _, _ = c.ContainerDeleteRecursive(ctx, COPY_CONTAINER, 1)
+		_, _, _ = c.DeletePrefix(ctx, CONTAINER, "", 1)
+	}()
+
+	_, err = c.ObjectPut(ctx, CONTAINER, "a", strings.NewReader(CONTENTS), true, "", "text/plain", m1.ObjectHeaders())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectPutString(ctx, CONTAINER, "b", CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createMultiSegmentObject(t, c.StaticLargeObjectCreate)
+
+	checkResults := func(results []swift.CopyResult, skipped map[string]bool) {
+		t.Helper()
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Name)
+			if result.Err != nil {
+				t.Errorf("%s: copy failed: %v", result.Name, result.Err)
+			}
+			if result.Skipped != skipped[result.Name] {
+				t.Errorf("%s: expecting skipped %v got %v", result.Name, skipped[result.Name], result.Skipped)
+			}
+		}
+		if !reflect.DeepEqual(names, []string{"a", "b", OBJECT}) {
+			t.Errorf("Bad results %q", names)
+		}
+	}
+
+	opts := &swift.CopyOpts{Concurrency: 2, Deep: true}
+	results, err := c.ContainerCopy(ctx, CONTAINER, COPY_CONTAINER, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(results, nil)
+	info, headers, err := c.Object(ctx, COPY_CONTAINER, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "text/plain" {
+		t.Errorf("Bad content type %q", info.ContentType)
+	}
+	compareMaps(t, headers.ObjectMetadata(), map[string]string{"hello": "1", "potato-salad": "2"})
+	contents, err := c.ObjectGetString(ctx, COPY_CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS+" "+CONTENTS+"\n" {
+		t.Errorf("Bad large object contents %q", contents)
+	}
+
+	// Only the changed object is copied when mirroring
+	err = c.ObjectPutString(ctx, CONTAINER, "b", CONTENTS2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Mirror = true
+	results, err = c.ContainerCopy(ctx, CONTAINER, COPY_CONTAINER, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResults(results, map[string]bool{"a": true, OBJECT: true})
+	contents, err = c.ObjectGetString(ctx, COPY_CONTAINER, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS2 {
+		t.Errorf("Bad contents %q", contents)
+	}
+}
+
+func TestBulkUpload(t *testing.T) {
+	testBulkUpload(t, swift.UploadTar, nil)
+}
+
+func TestBulkUploadTarGzip(t *testing.T) {
+	testBulkUpload(t, swift.UploadTarGzip, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	})
+}
+
+// testBulkUpload uploads a tar in format, compressed with compress if
+// set, and checks the objects in it were created
+func testBulkUpload(t *testing.T, format string, compress func(w io.Writer) io.WriteCloser) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	buffer := new(bytes.Buffer)
+	var out io.Writer = buffer
+	var compressor io.WriteCloser
+	if compress != nil {
+		compressor = compress(buffer)
+		out = compressor
+	}
+	ds := tar.NewWriter(out)
+	var files = []struct{ Name, Body string }{
+		{OBJECT, CONTENTS},
+		{OBJECT2, CONTENTS2},
+	}
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name: file.Name,
+			Size: int64(len(file.Body)),
+		}
+		if err := ds.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ds.Write([]byte(file.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := c.BulkUpload(ctx, CONTAINER, buffer, format, nil)
+	if err == swift.Forbidden {
+		t.Log("Server doesn't support BulkUpload - skipping test")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if result.NumberCreated != 2 {
+		t.Error("Expected 2, actual:", result.NumberCreated)
+	}
+	t.Log("Errors:", result.Errors)
+
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error("Expecting object to be found")
+	}
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Error("Expecting object to be found")
+	}
+}
+
+func TestObjectDifficultName(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	const name = `hello? sausage/êé/Hello, 世界/ " ' @ < > & ?/`
+	err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	objects, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	found := false
+	for _, object := range objects {
+		if object == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("Couldn't find %q in listing %q", name, objects)
+	}
+}
+
+func TestTempUrl(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+	err := c.ObjectPutBytes(ctx, CONTAINER, OBJECT, []byte(CONTENTS), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiresTime := time.Now().Add(20 * time.Minute)
+	tempUrl := c.ObjectTempUrl(CONTAINER, OBJECT, SECRET_KEY, "GET", expiresTime)
+	resp, err := http.Get(tempUrl)
+	if err != nil {
+		t.Fatal("Failed to retrieve file from temporary url")
+	}
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			t.Error("Close failed", err)
+		}
+	}()
+	if resp.StatusCode == 401 && srv == nil {
+		t.Log("Server doesn't support tempurl")
+	} else if resp.StatusCode != 200 {
+		t.Fatal("HTTP Error retrieving file from temporary url", resp.StatusCode)
+	} else {
+		var content []byte
+		if content, err = io.ReadAll(resp.Body); err != nil || string(content) != CONTENTS {
+			t.Error("Bad content", err)
+		}
+
+		resp, err = http.Post(tempUrl, "image/jpeg", bytes.NewReader([]byte(CONTENTS)))
+		if err != nil {
+			t.Fatal("Failed to retrieve file from temporary url")
+		}
+		defer func() {
+			err := resp.Body.Close()
+			if err != nil {
+				t.Error("Close failed", err)
+			}
+		}()
+		if resp.StatusCode != 401 {
+			t.Fatal("Expecting server to forbid access to object")
+		}
+
+		expiredUrl := c.ObjectTempUrl(CONTAINER, OBJECT, SECRET_KEY, "GET", time.Now().Add(-time.Minute))
+		resp, err = http.Get(expiredUrl)
+		if err != nil {
+			t.Fatal("Failed to retrieve file from expired temporary url")
+		}
+		defer func() {
+			err := resp.Body.Close()
+			if err != nil {
+				t.Error("Close failed", err)
+			}
+		}()
+		if resp.StatusCode != 401 {
+			t.Fatal("Expecting server to forbid access to object with expired url")
+		}
+	}
+}
+
+func TestTempUrlWithDigest(t *testing.T) {
+	// Reference signatures made with the algorithm from the Swift
+	// tempurl middleware documentation
+	c := &swift.Connection{StorageUrl: "https://example.com/v1/AUTH_account"}
+	expires := time.Unix(1440619048, 0)
+	for _, test := range []struct {
+		digest swift.TempUrlDigest
+		sig    string
+	}{
+		{swift.TempUrlSHA1, "da720a7e11f9f2c7b0fe46039811229c1c7a9cb4"},
+		{swift.TempUrlSHA256, "sha256%3AnvjESNQYT9Ft1AE6HjNJFJ-JVVVlViHb9tahZvWFr3I%3D"},
+		{swift.TempUrlSHA512, "sha512%3ACCIGWJL8qM43wwYDy65pa4KL0u0ayGe5Za6i3hjLRIxTv3EiVypmhAt54WtLfkKu4wltDg5C4RXeRD-fzHgIwA%3D%3D"},
+	} {
+		want := "https://example.com/v1/AUTH_account/container/object?temp_url_sig=" + test.sig + "&temp_url_expires=1440619048"
+		got := c.ObjectTempUrlWithDigest("container", "object", "mykey", "GET", expires, test.digest)
+		if got != want {
+			t.Errorf("%s: want %q got %q", test.digest, want, got)
+		}
+	}
+	if got := c.ObjectTempUrl("container", "object", "mykey", "GET", expires); !strings.Contains(got, "temp_url_sig=da720a7e11f9f2c7b0fe46039811229c1c7a9cb4&") {
+		t.Errorf("ObjectTempUrl should default to SHA-1 got %q", got)
+	}
+	if got := c.ObjectTempUrlWithDigest("container", "object", "mykey", "GET", expires, "md5"); got != "" {
+		t.Errorf("Expecting empty URL for unknown digest got %q", got)
+	}
+}
+
+func TestTempUrlPrefix(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	// Reference signature made with the algorithm from the Swift
+	// tempurl middleware documentation
+	ref := &swift.Connection{StorageUrl: "https://example.com/v1/AUTH_account"}
+	want := "https://example.com/v1/AUTH_account/container/dir/?temp_url_sig=1c77662e8def06b89959120eb8764f8453c36de9&temp_url_expires=1440619048&temp_url_prefix=dir%2F"
+	got := ref.ObjectTempUrlPrefix("container", "dir/", "mykey", "GET", time.Unix(1440619048, 0))
+	if got != want {
+		t.Errorf("Bad prefix temp url want %q got %q", want, got)
+	}
+
+	for _, name := range []string{"dir/a", "dir/b", "other"} {
+		err := c.ObjectPutString(ctx, CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func(name string) {
+			_ = c.ObjectDelete(ctx, CONTAINER, name)
+		}(name)
+	}
+	err := c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tempUrl := c.ObjectTempUrlPrefix(CONTAINER, "dir/", SECRET_KEY, "GET", time.Now().Add(20*time.Minute))
+	// Swap the prefix in the URL for the object to fetch
+	i := strings.Index(tempUrl, "?")
+	base, query := strings.TrimSuffix(tempUrl[:i], "dir/"), tempUrl[i:]
+	get := func(name string) int {
+		resp, err := http.Get(base + name + query)
+		if err != nil {
+			t.Fatal("Failed to retrieve file from temporary url")
+		}
+		defer func() {
+			err := resp.Body.Close()
+			if err != nil {
+				t.Error("Close failed", err)
+			}
+		}()
+		if resp.StatusCode == 200 {
+			content, err := io.ReadAll(resp.Body)
+			if err != nil || string(content) != CONTENTS {
+				t.Error("Bad content", err)
+			}
+		}
+		return resp.StatusCode
+	}
+	if code := get("dir/a"); code == 401 {
+		t.Log("Server doesn't support tempurl with a prefix")
+		return
+	} else if code != 200 {
+		t.Fatal("HTTP Error retrieving file from temporary url", code)
+	}
+	if code := get("dir/b"); code != 200 {
+		t.Error("HTTP Error retrieving file from temporary url", code)
+	}
+	if code := get("other"); code != 401 {
+		t.Errorf("Expecting server to forbid access to object outside the prefix got %d", code)
+	}
+}
+
+func TestTempUrlIP(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	// Reference signature made with the algorithm from the Swift
+	// tempurl middleware documentation
+	ref := &swift.Connection{StorageUrl: "https://example.com/v1/AUTH_account"}
+	want := "https://example.com/v1/AUTH_account/container/object?temp_url_sig=6b3b11806dbb5da5c353cdcc06324907b72f38af&temp_url_expires=1440619048&temp_url_ip=1.2.3.4"
+	got := ref.ObjectTempUrlWithOpts("container", "object", "mykey", "GET", time.Unix(1440619048, 0), &swift.TempUrlOpts{IP: "1.2.3.4"})
+	if got != want {
+		t.Errorf("Bad IP temp url want %q got %q", want, got)
+	}
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as the client IP isn't known.")
+		return
+	}
+
+	err := c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		ip   string
+		code int
+	}{
+		{"127.0.0.1", 200},
+		{"127.0.0.0/8", 200},
+		{"10.0.0.1", 401},
+	} {
+		tempUrl := c.ObjectTempUrlWithOpts(CONTAINER, OBJECT, SECRET_KEY, "GET", time.Now().Add(20*time.Minute), &swift.TempUrlOpts{IP: test.ip})
+		resp, err := http.Get(tempUrl)
+		if err != nil {
+			t.Fatal("Failed to retrieve file from temporary url")
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("%s: want status %d got %d", test.ip, test.code, resp.StatusCode)
+		}
+	}
+}
+
+func TestTempUrlSHA512(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	err := c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tempUrl := c.ObjectTempUrlWithDigest(CONTAINER, OBJECT, SECRET_KEY, "GET", time.Now().Add(20*time.Minute), swift.TempUrlSHA512)
+	resp, err := http.Get(tempUrl)
+	if err != nil {
+		t.Fatal("Failed to retrieve file from temporary url")
+	}
+	defer func() {
+		err := resp.Body.Close()
+		if err != nil {
+			t.Error("Close failed", err)
+		}
+	}()
+	if resp.StatusCode == 401 {
+		t.Log("Server doesn't support tempurl with SHA-512")
+		return
+	} else if resp.StatusCode != 200 {
+		t.Fatal("HTTP Error retrieving file from temporary url", resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil || string(content) != CONTENTS {
+		t.Error("Bad content", err)
+	}
+}
+
+func TestTempUrlKeys(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	err := c.AccountTempUrlKeySet(ctx, SECRET_KEY, "account-key-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	accountKey, accountKey2, err := c.TempUrlKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountKey != SECRET_KEY || accountKey2 != "account-key-2" {
+		t.Errorf("Bad account keys, got %q, %q", accountKey, accountKey2)
+	}
+
+	err = c.ContainerTempUrlKeySet(ctx, CONTAINER, "container-key", "container-key-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerKey, containerKey2, err := c.ContainerTempUrlKeys(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containerKey != "container-key" || containerKey2 != "container-key-2" {
+		t.Errorf("Bad container keys, got %q, %q", containerKey, containerKey2)
+	}
+}
+
+func TestTempUrlKeyRotate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	key1, err := swift.GenerateTempUrlKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := swift.GenerateTempUrlKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key1) != 64 || key1 == key2 {
+		t.Errorf("Bad generated keys %q, %q", key1, key2)
+	}
+
+	err = c.AccountTempUrlKeySet(ctx, key1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	expires := time.Now().Add(20 * time.Minute)
+	oldUrl := c.ObjectTempUrl(CONTAINER, OBJECT, key1, "GET", expires)
+
+	newKey, err := c.AccountTempUrlKeyRotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKey, accountKey2, err := c.TempUrlKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountKey != newKey || accountKey2 != key1 {
+		t.Errorf("Bad keys after rotation, got %q, %q", accountKey, accountKey2)
+	}
+
+	newKey, err = c.ContainerTempUrlKeyRotate(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerKey, containerKey2, err := c.ContainerTempUrlKeys(ctx, CONTAINER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containerKey != newKey || containerKey2 != "" {
+		t.Errorf("Bad container keys after rotation, got %q, %q", containerKey, containerKey2)
+	}
+
+	if srv == nil {
+		return
+	}
+	// URLs signed with the old key must still work
+	resp, err := http.Get(oldUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Expecting URL signed with the old key to work got %d", resp.StatusCode)
+	}
+}
+
+func TestQueryInfo(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionAuth(t)
+	defer rollback()
+	infos, err := c.QueryInfo(ctx)
+	if err != nil {
+		t.Log("Server doesn't support querying info")
+		return
+	}
+	if _, ok := infos["swift"]; !ok {
+		t.Fatal("No 'swift' section found in configuration")
+	}
+}
+
+func TestDLOCreate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ObjectType != swift.DynamicLargeObjectType {
+		t.Errorf("Wrong ObjectType, expected %d, got: %d", swift.DynamicLargeObjectType, info.ObjectType)
+	}
+	if info.Bytes != int64(len(expected)) {
+		t.Errorf("Wrong Bytes size, expected %d, got: %d", len(expected), info.Bytes)
+	}
+}
+
+func TestDLOInsert(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		CheckHash:   true,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	_, err = fmt.Fprintf(multi, "%d%s\n", 0, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fmt.Fprintf(buf, "\n%d %s\n", 1, CONTENTS)
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOAppend(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		Flags:       os.O_APPEND,
+		CheckHash:   true,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer([]byte(contents))
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i+10, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err = c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOTruncate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		Flags:       os.O_TRUNC,
+		CheckHash:   true,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreateFile(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	_, err = fmt.Fprintf(multi, "%s", CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOMove(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.DynamicLargeObjectMove(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	contents2, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contents2 != contents {
+		t.Error("Contents wrong")
+	}
+}
+
+func TestDLOMergeMetadata(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ObjectMergeMetadata(ctx, CONTAINER, OBJECT, swift.Metadata{"colour": "blue"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ObjectType != swift.DynamicLargeObjectType {
+		t.Errorf("Wrong ObjectType, expected %d, got: %d", swift.DynamicLargeObjectType, info.ObjectType)
+	}
+	if headers.ObjectMetadata()["colour"] != "blue" {
+		t.Errorf("Metadata not merged: %v", headers.ObjectMetadata())
+	}
+	contents2, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents2 != contents {
+		t.Errorf("Contents wrong, expected %q, got: %q", contents, contents2)
+	}
+}
+
+func TestDLONoSegmentContainer(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+	opts := swift.LargeObjectOpts{
+		Container:        CONTAINER,
+		ObjectName:       OBJECT,
+		ContentType:      "image/jpeg",
+		SegmentContainer: CONTAINER,
+	}
+	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOSegmentsListConsistencyDelay(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
+		return
+	}
+
+	srv.SetListConsistencyDelay(time.Hour)
+	defer srv.SetListConsistencyDelay(0)
+
+	names, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Expecting segments to be hidden from listing got %v", names)
+	}
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segmentContainer != SEGMENTS_CONTAINER || len(segments) == 0 {
+		t.Fatalf("Expecting segments to be found by HEAD got %q %v", segmentContainer, segments)
+	}
+	for i, segment := range segments {
+		if want := fmt.Sprintf("/%016d", i+1); !strings.HasSuffix(segment.Name, want) {
+			t.Errorf("Segment %d: expecting name ending %q got %q", i, want, segment.Name)
+		}
+	}
+}
+
+func TestDLOCreateMissingSegmentsInList(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
+		return
+	}
+
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + SEGMENTS_CONTAINER
+	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write([]byte("null\n"))
+	})
+	defer srv.UnsetOverride(listURL)
+
+	headers := swift.Headers{}
+	err := c.ContainerCreate(ctx, SEGMENTS_CONTAINER, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ContainerDelete(ctx, SEGMENTS_CONTAINER)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOCreateIncorrectSize(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate eventual consistency problems.")
+		return
+	}
+
+	listURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	headCount := 0
+	expectedHeadCount := 5
+	srv.SetOverride(listURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		if r.Method == "HEAD" {
+			headCount++
+			if headCount < expectedHeadCount {
+				w.Header().Set("Content-Length", "7")
+			}
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(listURL)
+
+	headers := swift.Headers{}
+	err := c.ContainerCreate(ctx, SEGMENTS_CONTAINER, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.ContainerDelete(ctx, SEGMENTS_CONTAINER)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
+	for i := 0; i < 2; i++ {
+		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if headCount != expectedHeadCount {
+		t.Errorf("Unexpected HEAD requests count, expected %d, got: %d", expectedHeadCount, headCount)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+}
+
+func TestDLOConcurrentWrite(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	nConcurrency := 5
+	nChunks := 100
+	var chunkSize int64 = 1024
+
+	writeFn := func(i int) {
+		objName := fmt.Sprintf("%s_concurrent_dlo_%d", OBJECT, i)
+		opts := swift.LargeObjectOpts{
+			Container:   CONTAINER,
+			ObjectName:  objName,
+			ContentType: "image/jpeg",
+		}
+		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err = c.DynamicLargeObjectDelete(ctx, CONTAINER, objName)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		buf := &bytes.Buffer{}
+		for j := 0; j < nChunks; j++ {
+			var data []byte
+			var n int
+			data, err = io.ReadAll(io.LimitReader(rand.Reader, chunkSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			multi := io.MultiWriter(buf, out)
+			n, err = multi.Write(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(n) != chunkSize {
+				t.Fatalf("expected to write %d, got: %d", chunkSize, n)
+			}
+		}
+		err = out.CloseWithContext(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		expected := buf.String()
+		contents, err := c.ObjectGetString(ctx, CONTAINER, objName)
+		if err != nil {
+			t.Error(err)
+		}
+		if contents != expected {
+			t.Error("Contents wrong")
+		}
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < nConcurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			writeFn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDLOSegmentation(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+		ChunkSize:   6,
+		NoBuffer:    true,
+	}
+
+	testSegmentation(t, c, func() swift.LargeObjectFile {
+		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}, []segmentTest{
+		{
+			writes:        []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+			expectedSegs:  []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+			expectedValue: "012345678",
+		},
+		{
+			writes:        []string{"012345", "012345"},
+			expectedSegs:  []string{"012345", "012345"},
+			expectedValue: "012345012345",
+		},
+		{
+			writes:        []string{"0123456", "0123456"},
+			expectedSegs:  []string{"012345", "6", "012345", "6"},
+			expectedValue: "01234560123456",
+		},
+		{
+			writes:        []string{"0123456", "0123456"},
+			seeks:         []int{-4, 0},
+			expectedSegs:  []string{"012012", "3456"},
+			expectedValue: "0120123456",
+		},
+		{
+			writes:        []string{"0123456", "0123456", "abcde"},
+			seeks:         []int{0, -11, 0},
+			expectedSegs:  []string{"012abc", "d", "e12345", "6"},
+			expectedValue: "012abcde123456",
+		},
+		{
+			writes:        []string{"0123456", "ab"},
+			seeks:         []int{-4, 0},
+			expectedSegs:  []string{"012ab5", "6"},
+			expectedValue: "012ab56",
+		},
+	})
+}
+
+func TestDLOSegmentationChunkSizeFn(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	// Segments of 2, 4, 8, ... bytes
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+		ChunkSizeFn: func(segment int) int64 {
+			return 2 << segment
+		},
+	}
+	createObj := func() swift.LargeObjectFile {
+		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	var chars []string
+	for _, char := range "0123456789abcdefghij" {
+		chars = append(chars, string(char))
+	}
+	testSegmentation(t, c, createObj, []segmentTest{
+		{
+			writes:        chars,
+			expectedSegs:  []string{"01", "2345", "6789abcd", "efghij"},
+			expectedValue: "0123456789abcdefghij",
+		},
+		{
+			writes:        []string{"0123456789", "abcdefghij"},
+			expectedSegs:  []string{"01", "2345", "6789abcd", "efghij"},
+			expectedValue: "0123456789abcdefghij",
+		},
+	})
+
+	// Unbuffered the ramp is applied within each write
+	opts.NoBuffer = true
+	testSegmentation(t, c, createObj, []segmentTest{
+		{
+			writes:        []string{"0123456789abcdefghij"},
+			expectedSegs:  []string{"01", "2345", "6789abcd", "efghij"},
+			expectedValue: "0123456789abcdefghij",
+		},
+	})
+}
+
+func TestDLOSegmentationBuffered(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+		ChunkSize:   6,
+	}
+
+	testSegmentation(t, c, func() swift.LargeObjectFile {
+		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}, []segmentTest{
+		{
+			writes:        []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+			expectedSegs:  []string{"012345", "678"},
+			expectedValue: "012345678",
+		},
+		{
+			writes:        []string{"012345", "012345"},
+			expectedSegs:  []string{"012345", "012345"},
+			expectedValue: "012345012345",
+		},
+		{
+			writes:        []string{"0123456", "0123456"},
+			expectedSegs:  []string{"012345", "6", "012345", "6"},
+			expectedValue: "01234560123456",
+		},
+		{
+			writes:        []string{"0123456", "0123456"},
+			seeks:         []int{-4, 0},
+			expectedSegs:  []string{"012012", "3456"},
+			expectedValue: "0120123456",
+		},
+		{
+			writes:        []string{"0123456", "0123456", "abcde"},
+			seeks:         []int{0, -11, 0},
+			expectedSegs:  []string{"012abc", "d", "e12345", "6"},
+			expectedValue: "012abcde123456",
+		},
+		{
+			writes:        []string{"0123456", "ab"},
+			seeks:         []int{-4, 0},
+			expectedSegs:  []string{"012ab5", "6"},
+			expectedValue: "012ab56",
+		},
+	})
+}
+
+func TestObjectPutAuto(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	for _, test := range []struct {
+		name     string
+		contents string
+		isLarge  bool
+	}{
+		{"Small", CONTENTS, false},
+		{"Threshold", "0123456789", false},
+		{"Large", strings.Repeat("0123456789", 3) + "x", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := swift.LargeObjectOpts{
+				Container:   CONTAINER,
+				ObjectName:  OBJECT,
+				ContentType: "text/plain",
+				ChunkSize:   10,
+			}
+			err := c.ObjectPutAuto(ctx, &opts, strings.NewReader(test.contents))
+			if err == swift.SLONotSupported {
+				t.Skip("SLO not supported")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err = c.LargeObjectDelete(ctx, CONTAINER, OBJECT)
+				if err == swift.NotLargeObject {
+					err = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+				}
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+			_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if headers.IsLargeObjectSLO() != test.isLarge {
+				t.Errorf("want large object %v got %v", test.isLarge, headers.IsLargeObjectSLO())
+			}
+			contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contents != test.contents {
+				t.Errorf("want %q got %q", test.contents, contents)
+			}
+		})
+	}
+}
+
+func TestSLOProgress(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	var progress []int64
+	opts := swift.LargeObjectOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+		ChunkSize:  4,
+		NoBuffer:   true,
+		Progress: func(bytesWritten int64, totalBytes int64) {
+			if totalBytes != -1 {
+				t.Errorf("want total -1 got %d", totalBytes)
+			}
+			progress = append(progress, bytesWritten)
+		},
+	}
+	out, err := c.StaticLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+			return
+		}
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// The segments and manifest shouldn't report progress themselves
+	progressCtx := swift.WithProgress(ctx, func(int64, int64) {
+		t.Error("segment progress shouldn't be reported")
+	})
+	_, err = out.WriteWithContext(progressCtx, []byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(progressCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{4, 8, 10}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("want progress %v got %v", want, progress)
+	}
+}
+
+func TestSLOWriteAfterClose(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	out, err := c.StaticLargeObjectCreate(ctx, &swift.LargeObjectOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+		ChunkSize:  1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	}()
+	_, err = out.Write([]byte(CONTENTS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The buffer has gone back to the pool so writes must fail
+	_, err = out.Write([]byte(CONTENTS))
+	if err == nil {
+		t.Error("Expecting error writing to closed large object")
+	}
+	if out.Size() != CONTENT_SIZE {
+		t.Errorf("Bad size want %d got %d", CONTENT_SIZE, out.Size())
+	}
+}
+
+func BenchmarkSLOCreate(b *testing.B) {
+	ctx := context.Background()
+	c, rollback := makeConnection(nil)
+	defer rollback()
+	err := c.Authenticate(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, container := range []string{CONTAINER, SEGMENTS_CONTAINER} {
+		err = c.ContainerCreate(ctx, container, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer func(container string) {
+			_ = c.ContainerDelete(ctx, container)
+		}(container)
+	}
+	const chunkSize = 1024 * 1024
+	data := make([]byte, 4*chunkSize)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, err := c.StaticLargeObjectCreate(ctx, &swift.LargeObjectOpts{
+			Container:  CONTAINER,
+			ObjectName: OBJECT,
+			ChunkSize:  chunkSize,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for offset := 0; offset < len(data); offset += 64 * 1024 {
+			_, err = out.Write(data[offset : offset+64*1024])
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		err = out.CloseWithContext(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSLOCreate(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+	}
+	out, err := c.StaticLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+			return
+		}
+		t.Fatal(err)
+	}
+	defer func() {
+		err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	buf := &bytes.Buffer{}
+	multi := io.MultiWriter(buf, out)
 	for i := 0; i < 2; i++ {
 		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
-	err = out.CloseWithContext(ctx)
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := buf.String()
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Error(err)
+	}
+	if contents != expected {
+		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	}
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ObjectType != swift.StaticLargeObjectType {
+		t.Errorf("Wrong ObjectType, expected %d, got: %d", swift.StaticLargeObjectType, info.ObjectType)
+	}
+	if info.Bytes != int64(len(expected)) {
+		t.Errorf("Wrong Bytes size, expected %d, got: %d", len(expected), info.Bytes)
+	}
+}
+
+func TestSLOManifestValidation(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to exercise its manifest handling.")
+		return
+	}
+
+	for _, name := range []string{"seg1", "seg2"} {
+		err := c.ObjectPutString(ctx, SEGMENTS_CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	putManifest := func(manifest string) error {
+		_, _, err := c.Call(ctx, c.StorageUrl, swift.RequestOpts{
+			Container:  CONTAINER,
+			ObjectName: OBJECT,
+			Operation:  "PUT",
+			Parameters: url.Values{"multipart-manifest": {"put"}},
+			Body:       strings.NewReader(manifest),
+			NoResponse: true,
+		})
+		return err
+	}
+
+	for _, manifest := range []string{
+		`[{"path": "` + SEGMENTS_CONTAINER + `/missing"}]`,
+		`[{"path": "` + SEGMENTS_CONTAINER + `/seg1", "etag": "` + CONTENT2_MD5 + `"}]`,
+		`[{"path": "` + SEGMENTS_CONTAINER + `/seg1", "size_bytes": 6}]`,
+	} {
+		err := putManifest(manifest)
+		if swiftErr, ok := err.(*swift.Error); !ok || swiftErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expecting 400 error got %v", manifest, err)
+		}
+	}
+
+	err := putManifest(`[{"path": "` + SEGMENTS_CONTAINER + `/seg1", "etag": "` + CONTENT_MD5 + `", "size_bytes": 5}, {"path": "` + SEGMENTS_CONTAINER + `/seg2"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contents != CONTENTS+CONTENTS {
+		t.Errorf("Contents wrong, expected %q, got: %q", CONTENTS+CONTENTS, contents)
+	}
+
+	// Deleting with multipart-manifest=delete removes the segments
+	_, _, err = c.Call(ctx, c.StorageUrl, swift.RequestOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+		Operation:  "DELETE",
+		Parameters: url.Values{"multipart-manifest": {"delete"}},
+		NoResponse: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Segments not deleted: %v", names)
+	}
+	_, _, err = c.Object(ctx, CONTAINER, OBJECT)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
+	}
+}
+
+func TestSLOCleanupOnFailure(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	if srv == nil {
+		t.Skipf("This test only runs with the fake swift server as it's needed to simulate a manifest failure.")
+		return
+	}
+
+	// Make the manifest PUT fail
+	manifestURL := "/v1/AUTH_" + swifttest.TEST_ACCOUNT + "/" + CONTAINER + "/" + OBJECT
+	srv.SetOverride(manifestURL, func(w http.ResponseWriter, r *http.Request, recorder *httptest.ResponseRecorder) {
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for k, v := range recorder.Result().Header {
+			w.Header().Set(k, v[0])
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+	defer srv.UnsetOverride(manifestURL)
+
+	opts := swift.LargeObjectOpts{
+		Container:        CONTAINER,
+		ObjectName:       OBJECT,
+		ContentType:      "image/jpeg",
+		ChunkSize:        6,
+		NoBuffer:         true,
+		CleanupOnFailure: true,
+	}
+	out, err := c.StaticLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+			return
+		}
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(out, "%s %s\n", CONTENTS, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	cleanupErr, ok := err.(*swift.LargeObjectCleanupError)
+	if !ok {
+		t.Fatalf("Expecting *LargeObjectCleanupError got %v", err)
+	}
+	if cleanupErr.CleanupErr != nil {
+		t.Error(cleanupErr.CleanupErr)
+	}
+	if len(cleanupErr.Deleted) != 2 || cleanupErr.Container != SEGMENTS_CONTAINER {
+		t.Errorf("Bad cleanup report %v", cleanupErr)
+	}
+
+	// The fake server stored the manifest before the override failed it
+	srv.UnsetOverride(manifestURL)
+	_ = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+
+	segments, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("Segments not cleaned up: %v", segments)
+	}
+}
+
+func TestSLOResume(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	const data = "0123456789abcdefgh"
+	const prefix = "resume/upload"
+	for _, test := range []struct {
+		segments     []string // segments left by the interrupted upload
+		verify       bool     // check the segments against the data
+		expectedSize int64    // position the upload resumes from
+		expected     string   // contents of the finished object
+	}{
+		{segments: nil, expectedSize: 0, expected: data},
+		{segments: []string{"012345", "6789ab", "cd"}, expectedSize: 12, expected: data},
+		{segments: []string{"012345", "6789aX", "cdefgh"}, expectedSize: 18, expected: "0123456789aXcdefgh"},
+		{segments: []string{"012345", "6789aX", "cdefgh"}, verify: true, expectedSize: 6, expected: data},
+	} {
+		for i, segment := range test.segments {
+			err := c.ObjectPutString(ctx, SEGMENTS_CONTAINER, fmt.Sprintf("%s/%016d", prefix, i+1), segment, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := swift.LargeObjectOpts{
+			Container:     CONTAINER,
+			ObjectName:    OBJECT,
+			ContentType:   "image/jpeg",
+			ChunkSize:     6,
+			SegmentPrefix: prefix,
+			NoBuffer:      true,
+		}
+		if test.verify {
+			opts.ResumeSource = strings.NewReader(data)
+		}
+		out, err := c.LargeObjectResume(ctx, &opts)
+		if err != nil {
+			if err == swift.SLONotSupported {
+				t.Skip("SLO not supported")
+			}
+			t.Fatal(err)
+		}
+		if out.Size() != test.expectedSize {
+			t.Errorf("%v: expecting to resume from %d got %d", test.segments, test.expectedSize, out.Size())
+		}
+		_, err = out.Write([]byte(data[out.Size():]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = out.CloseWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents != test.expected {
+			t.Errorf("%v: contents wrong, expected %q, got: %q", test.segments, test.expected, contents)
+		}
+		err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := c.LargeObjectResume(ctx, &swift.LargeObjectOpts{Container: CONTAINER, ObjectName: OBJECT})
+	if err != swift.LargeObjectNoSegmentPrefix {
+		t.Errorf("Expecting LargeObjectNoSegmentPrefix got %v", err)
+	}
+}
+
+func TestLargeObjectCleanupSegments(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+
+	opts := swift.LargeObjectOpts{
+		Container:        CONTAINER,
+		ObjectName:       OBJECT,
+		ContentType:      "image/jpeg",
+		ChunkSize:        6,
+		NoBuffer:         true,
+		ObjectSegmentDir: true,
+	}
+	out, err := c.StaticLargeObjectCreate(ctx, &opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+		}
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(out, "%s %s\n", CONTENTS, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := "segments/" + CONTAINER + "/" + OBJECT + "/"
+	if len(segments) != 2 || !strings.HasPrefix(segments[0].Name, dir) {
+		t.Fatalf("Bad segments %v", segments)
+	}
+
+	// Segments from an abandoned upload of the object and from an
+	// object below it
+	orphan := dir + "0123456789abcdef0123456789abcdef/0000000000000001"
+	other := dir + "sub/0123456789abcdef0123456789abcdef/0000000000000001"
+	for _, name := range []string{orphan, other} {
+		err = c.ObjectPutString(ctx, SEGMENTS_CONTAINER, name, CONTENTS, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The orphan is too new to be cleaned up if an age is given as
+	// it may belong to an upload which is still running
+	err = c.LargeObjectCleanupSegmentsOlderThan(ctx, CONTAINER, OBJECT, time.Hour)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if headCount != expectedHeadCount {
-		t.Errorf("Unexpected HEAD requests count, expected %d, got: %d", expectedHeadCount, headCount)
+	names, err := c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	expected := []string{segments[0].Name, segments[1].Name, orphan, other}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Wrong segments left, expected %v, got %v", expected, names)
+	}
+
+	err = c.LargeObjectCleanupSegments(ctx, CONTAINER, OBJECT)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	names, err = c.ObjectNamesAll(ctx, SEGMENTS_CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{segments[0].Name, segments[1].Name, other}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Wrong segments left, expected %v, got %v", expected, names)
+	}
+
+	err = c.ObjectDelete(ctx, SEGMENTS_CONTAINER, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.LargeObjectCleanupSegments(ctx, CONTAINER, OBJECT2)
+	if err != swift.ObjectNotFound {
+		t.Errorf("Expecting ObjectNotFound got %v", err)
 	}
 }
 
-func TestDLOConcurrentWrite(t *testing.T) {
+func TestSLOGetSegmentsMetadata(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithSegmentsContainer(t)
+	c, rollback := makeConnectionWithSLO(t)
 	defer rollback()
 
-	nConcurrency := 5
-	nChunks := 100
-	var chunkSize int64 = 1024
-
-	writeFn := func(i int) {
-		objName := fmt.Sprintf("%s_concurrent_dlo_%d", OBJECT, i)
-		opts := swift.LargeObjectOpts{
-			Container:   CONTAINER,
-			ObjectName:  objName,
-			ContentType: "image/jpeg",
-		}
-		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) == 0 {
+		t.Fatal("No segments")
+	}
+	for _, segment := range segments {
+		info, _, err := c.Object(ctx, segmentContainer, segment.Name)
 		if err != nil {
 			t.Fatal(err)
 		}
-		defer func() {
-			err = c.DynamicLargeObjectDelete(ctx, CONTAINER, objName)
+		if segment.ContentType != info.ContentType {
+			t.Errorf("Bad ContentType for %q: want %q got %q", segment.Name, info.ContentType, segment.ContentType)
+		}
+		if segment.LastModified.IsZero() || segment.ServerLastModified == "" {
+			t.Errorf("LastModified not set for %q", segment.Name)
+		}
+	}
+}
+
+func TestSLOObjectOpenParameters(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+	file, _, err := c.ObjectOpenWithOpts(ctx, CONTAINER, OBJECT, &swift.DownloadOpts{
+		Parameters: url.Values{"multipart-manifest": {"get"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	manifest, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var segments []map[string]interface{}
+	err = json.Unmarshal(manifest, &segments)
+	if err != nil {
+		t.Fatalf("Expecting manifest got %q: %v", manifest, err)
+	}
+	if len(segments) == 0 {
+		t.Fatal("No segments in manifest")
+	}
+
+	// Seek must read the manifest again too
+	_, err = file.Seek(ctx, 1, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != string(manifest[1:]) {
+		t.Errorf("Expecting %q after seek got %q", manifest[1:], rest)
+	}
+}
+
+func TestSLOObjectManifest(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSLO(t)
+	defer rollback()
+
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := c.ObjectManifest(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != len(segments) || len(manifest) == 0 {
+		t.Fatalf("Expecting %d entries got %d", len(segments), len(manifest))
+	}
+	for i, segment := range segments {
+		got := manifest[i]
+		if want := segmentContainer + "/" + segment.Name; got.Path != want {
+			t.Errorf("%d: Bad Path: want %q got %q", i, want, got.Path)
+		}
+		if got.Etag != segment.Hash || got.Size != segment.Bytes || got.ContentType != segment.ContentType {
+			t.Errorf("%d: Bad entry: want %+v got %+v", i, segment, got)
+		}
+		if got.LastModified.IsZero() {
+			t.Errorf("%d: LastModified not set", i)
+		}
+	}
+}
+
+func TestDLOObjectManifest(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithDLO(t)
+	defer rollback()
+
+	_, headers, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := c.ObjectManifest(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []swift.SegmentInfo{{Path: headers["X-Object-Manifest"]}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("Bad manifest: want %+v got %+v", want, manifest)
+	}
+}
+
+func TestObjectManifestNotLarge(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+
+	_, err := c.ObjectManifest(ctx, CONTAINER, OBJECT)
+	if err != swift.NotLargeObject {
+		t.Errorf("Expecting NotLargeObject got %v", err)
+	}
+}
+
+// createMultiSegmentObject creates OBJECT with create split into 2
+// segments
+func createMultiSegmentObject(t *testing.T, create func(context.Context, *swift.LargeObjectOpts) (swift.LargeObjectFile, error)) {
+	ctx := context.Background()
+	opts := swift.LargeObjectOpts{
+		Container:   CONTAINER,
+		ObjectName:  OBJECT,
+		ContentType: "image/jpeg",
+		ChunkSize:   6,
+		NoBuffer:    true,
+	}
+	out, err := create(ctx, &opts)
+	if err != nil {
+		if err == swift.SLONotSupported {
+			t.Skip("SLO not supported")
+		}
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(out, "%s %s\n", CONTENTS, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSLOCutSegment(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		noBuffer    bool
+		chunkSizeFn func(int) int64
+	}{
+		{name: "buffered"},
+		{name: "segment buffered", chunkSizeFn: func(int) int64 { return 6 }},
+		{name: "no buffer", noBuffer: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, rollback := makeConnectionWithSegmentsContainer(t)
+			defer rollback()
+			opts := swift.LargeObjectOpts{
+				Container:   CONTAINER,
+				ObjectName:  OBJECT,
+				ContentType: "text/plain",
+				ChunkSize:   6,
+				NoBuffer:    test.noBuffer,
+				ChunkSizeFn: test.chunkSizeFn,
+			}
+			out, err := c.StaticLargeObjectCreate(ctx, &opts)
 			if err != nil {
+				if err == swift.SLONotSupported {
+					t.Skip("SLO not supported")
+				}
 				t.Fatal(err)
 			}
-		}()
-		buf := &bytes.Buffer{}
-		for j := 0; j < nChunks; j++ {
-			var data []byte
-			var n int
-			data, err = io.ReadAll(io.LimitReader(rand.Reader, chunkSize))
+			defer func() {
+				_ = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+			}()
+			cutter, ok := out.(swift.SegmentCutter)
+			if !ok {
+				t.Fatalf("%T isn't a SegmentCutter", out)
+			}
+			_, err = io.WriteString(out, "ab")
 			if err != nil {
 				t.Fatal(err)
 			}
-			multi := io.MultiWriter(buf, out)
-			n, err = multi.Write(data)
+			err = cutter.CutSegment(ctx)
 			if err != nil {
 				t.Fatal(err)
 			}
-			if int64(n) != chunkSize {
-				t.Fatalf("expected to write %d, got: %d", chunkSize, n)
+			_, err = io.WriteString(out, "cdefghij")
+			if err != nil {
+				t.Fatal(err)
 			}
-		}
-		err = out.CloseWithContext(ctx)
-		if err != nil {
-			t.Error(err)
-		}
-		expected := buf.String()
-		contents, err := c.ObjectGetString(ctx, CONTAINER, objName)
-		if err != nil {
-			t.Error(err)
-		}
-		if contents != expected {
-			t.Error("Contents wrong")
-		}
+			err = out.CloseWithContext(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizes []int64
+			for _, segment := range segments {
+				sizes = append(sizes, segment.Bytes)
+			}
+			if want := []int64{2, 6, 2}; !reflect.DeepEqual(sizes, want) {
+				t.Errorf("Bad segment sizes: want %v got %v", want, sizes)
+			}
+			contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contents != "abcdefghij" {
+				t.Errorf("Bad contents %q", contents)
+			}
+			report, err := c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.OK() {
+				t.Errorf("Bad manifest: %+v", report.Problems)
+			}
+		})
+	}
+}
+
+func TestSLOLargeObjectVerify(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	createMultiSegmentObject(t, c.StaticLargeObjectCreate)
+	defer func() {
+		_ = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	}()
+
+	report, err := c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Type != swift.StaticLargeObjectType || report.Segments != 2 {
+		t.Fatalf("Bad report for intact object %+v", report)
+	}
+	if report.Etag != report.ComputedEtag {
+		t.Errorf("Etag mismatch %q != %q", report.Etag, report.ComputedEtag)
+	}
+
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDelete(ctx, segmentContainer, segments[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectPutString(ctx, segmentContainer, segments[1].Name, "corrupted", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := map[string]int{}
+	for _, problem := range report.Problems {
+		problems[problem.Path]++
+	}
+	if problems[segmentContainer+"/"+segments[0].Name] != 1 {
+		t.Errorf("Missing segment not reported: %+v", report.Problems)
+	}
+	if problems[segmentContainer+"/"+segments[1].Name] != 2 {
+		t.Errorf("Corrupted segment size and hash not reported: %+v", report.Problems)
+	}
+	if len(report.Problems) != 3 {
+		t.Errorf("Expecting 3 problems got %+v", report.Problems)
+	}
+}
+
+func TestDLOLargeObjectVerify(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	createMultiSegmentObject(t, c.DynamicLargeObjectCreate)
+	defer func() {
+		_ = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	}()
+
+	report, err := c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Type != swift.DynamicLargeObjectType || report.Segments != 2 {
+		t.Fatalf("Bad report for intact object %+v", report)
 	}
 
-	wg := sync.WaitGroup{}
-	for i := 0; i < nConcurrency; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			writeFn(i)
-		}(i)
+	segmentContainer, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ObjectDelete(ctx, segmentContainer, segments[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []swift.SegmentProblem{{Path: segmentContainer + "/" + segments[0].Name, Problem: "segment is missing"}}
+	if !reflect.DeepEqual(report.Problems, want) {
+		t.Errorf("Bad problems: want %+v got %+v", want, report.Problems)
 	}
-	wg.Wait()
 }
 
-func TestDLOSegmentation(t *testing.T) {
+func TestLargeObjectVerifyNotLarge(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithSegmentsContainer(t)
+	c, rollback := makeConnectionWithObject(t)
 	defer rollback()
 
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
-		ChunkSize:   6,
-		NoBuffer:    true,
+	_, err := c.LargeObjectVerify(ctx, CONTAINER, OBJECT)
+	if err != swift.NotLargeObject {
+		t.Errorf("Expecting NotLargeObject got %v", err)
 	}
-
-	testSegmentation(t, c, func() swift.LargeObjectFile {
-		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
-		if err != nil {
-			t.Fatal(err)
-		}
-		return out
-	}, []segmentTest{
-		{
-			writes:        []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
-			expectedSegs:  []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
-			expectedValue: "012345678",
-		},
-		{
-			writes:        []string{"012345", "012345"},
-			expectedSegs:  []string{"012345", "012345"},
-			expectedValue: "012345012345",
-		},
-		{
-			writes:        []string{"0123456", "0123456"},
-			expectedSegs:  []string{"012345", "6", "012345", "6"},
-			expectedValue: "01234560123456",
-		},
-		{
-			writes:        []string{"0123456", "0123456"},
-			seeks:         []int{-4, 0},
-			expectedSegs:  []string{"012012", "3456"},
-			expectedValue: "0120123456",
-		},
-		{
-			writes:        []string{"0123456", "0123456", "abcde"},
-			seeks:         []int{0, -11, 0},
-			expectedSegs:  []string{"012abc", "d", "e12345", "6"},
-			expectedValue: "012abcde123456",
-		},
-		{
-			writes:        []string{"0123456", "ab"},
-			seeks:         []int{-4, 0},
-			expectedSegs:  []string{"012ab5", "6"},
-			expectedValue: "012ab56",
-		},
-	})
 }
 
-func TestDLOSegmentationBuffered(t *testing.T) {
+func TestSLOComputeManifestEtag(t *testing.T) {
 	ctx := context.Background()
-	c, rollback := makeConnectionWithSegmentsContainer(t)
+	c, rollback := makeConnectionWithSLO(t)
 	defer rollback()
 
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
-		ChunkSize:   6,
+	_, segments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := swift.ComputeManifestEtag(segments); got != info.Hash {
+		t.Errorf("Bad manifest etag: want %q got %q", info.Hash, got)
 	}
 
-	testSegmentation(t, c, func() swift.LargeObjectFile {
-		out, err := c.DynamicLargeObjectCreate(ctx, &opts)
-		if err != nil {
-			t.Fatal(err)
+	// Quoted and upper case hashes are normalised
+	segments = []swift.Object{
+		{Hash: "d41d8cd98f00b204e9800998ecf8427e"},
+		{Hash: `"827CCB0EEA8A706C4C34A16891F84E7B"`},
+	}
+	const want = "4d481243a5964ec05a8a3bcfccd362bd"
+	if got := swift.ComputeManifestEtag(segments); got != want {
+		t.Errorf("Bad manifest etag: want %q got %q", want, got)
+	}
+}
+
+func TestLargeObjectCopy(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		make func(t *testing.T) (*swift.Connection, func())
+	}{
+		{"DLO", makeConnectionWithDLO},
+		{"SLO", makeConnectionWithSLO},
+	} {
+		for _, deep := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/deep=%v", test.name, deep), func(t *testing.T) {
+				ctx := context.Background()
+				c, rollback := test.make(t)
+				defer rollback()
+
+				err := c.LargeObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, deep)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+				if err != nil {
+					t.Fatal(err)
+				}
+				contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if contents != expected {
+					t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+				}
+				_, srcSegments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, dstSegments, err := c.LargeObjectGetSegments(ctx, CONTAINER, OBJECT2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(srcSegments) != len(dstSegments) || len(srcSegments) == 0 {
+					t.Fatalf("Segment count wrong, expected %d, got %d", len(srcSegments), len(dstSegments))
+				}
+				shared := srcSegments[0].Name == dstSegments[0].Name
+				if shared == deep {
+					t.Errorf("Segments shared = %v for deep = %v", shared, deep)
+				}
+				if deep {
+					err = c.LargeObjectDelete(ctx, CONTAINER, OBJECT2)
+				} else {
+					err = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
 		}
-		return out
-	}, []segmentTest{
-		{
-			writes:        []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
-			expectedSegs:  []string{"012345", "678"},
-			expectedValue: "012345678",
-		},
-		{
-			writes:        []string{"012345", "012345"},
-			expectedSegs:  []string{"012345", "012345"},
-			expectedValue: "012345012345",
-		},
-		{
-			writes:        []string{"0123456", "0123456"},
-			expectedSegs:  []string{"012345", "6", "012345", "6"},
-			expectedValue: "01234560123456",
-		},
-		{
-			writes:        []string{"0123456", "0123456"},
-			seeks:         []int{-4, 0},
-			expectedSegs:  []string{"012012", "3456"},
-			expectedValue: "0120123456",
-		},
-		{
-			writes:        []string{"0123456", "0123456", "abcde"},
-			seeks:         []int{0, -11, 0},
-			expectedSegs:  []string{"012abc", "d", "e12345", "6"},
-			expectedValue: "012abcde123456",
-		},
-		{
-			writes:        []string{"0123456", "ab"},
-			seeks:         []int{-4, 0},
-			expectedSegs:  []string{"012ab5", "6"},
-			expectedValue: "012ab56",
-		},
-	})
+	}
+
+	ctx := context.Background()
+	c, rollback := makeConnectionWithObject(t)
+	defer rollback()
+	err := c.LargeObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, false)
+	if err != swift.NotLargeObject {
+		t.Errorf("Expecting NotLargeObject got %v", err)
+	}
 }
 
-func TestSLOCreate(t *testing.T) {
+func TestSLOLargeObjectCopyMixedContainers(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithSegmentsContainer(t)
 	defer rollback()
 
-	opts := swift.LargeObjectOpts{
-		Container:   CONTAINER,
-		ObjectName:  OBJECT,
-		ContentType: "image/jpeg",
+	// A manifest with segments in two containers
+	segments := []struct {
+		container, name, contents, md5 string
+	}{
+		{SEGMENTS_CONTAINER, "mixed/1", CONTENTS, CONTENT_MD5},
+		{CONTAINER, "mixed/2", CONTENTS2, CONTENT2_MD5},
 	}
-	out, err := c.StaticLargeObjectCreate(ctx, &opts)
-	if err != nil {
-		if err == swift.SLONotSupported {
-			t.Skip("SLO not supported")
-			return
+	var manifest []map[string]interface{}
+	for _, segment := range segments {
+		err := c.ObjectPutString(ctx, segment.container, segment.name, segment.contents, "")
+		if err != nil {
+			t.Fatal(err)
 		}
+		manifest = append(manifest, map[string]interface{}{
+			"path":       segment.container + "/" + segment.name,
+			"etag":       segment.md5,
+			"size_bytes": len(segment.contents),
+		})
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = c.Call(ctx, c.StorageUrl, swift.RequestOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+		Operation:  "PUT",
+		Parameters: url.Values{"multipart-manifest": {"put"}},
+		Body:       bytes.NewReader(content),
+		ErrorMap:   swift.ObjectErrorMap,
+		NoResponse: true,
+	})
+	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		err = c.StaticLargeObjectDelete(ctx, CONTAINER, OBJECT)
-		if err != nil {
-			t.Fatal(err)
+		// LargeObjectDelete only deletes segments from one container
+		copied, _ := c.ObjectManifest(ctx, CONTAINER, OBJECT2)
+		for _, segment := range copied {
+			parts := strings.SplitN(segment.Path, "/", 2)
+			_ = c.ObjectDelete(ctx, parts[0], parts[1])
+		}
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT2)
+		_ = c.ObjectDelete(ctx, CONTAINER, OBJECT)
+		for _, segment := range segments {
+			_ = c.ObjectDelete(ctx, segment.container, segment.name)
 		}
 	}()
 
-	buf := &bytes.Buffer{}
-	multi := io.MultiWriter(buf, out)
-	for i := 0; i < 2; i++ {
-		_, err = fmt.Fprintf(multi, "%d %s\n", i, CONTENTS)
-		if err != nil {
-			t.Fatal(err)
-		}
-	}
-	err = out.CloseWithContext(ctx)
+	err = c.LargeObjectCopy(ctx, CONTAINER, OBJECT, CONTAINER, OBJECT2, true)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	expected := buf.String()
-	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT)
+	contents, err := c.ObjectGetString(ctx, CONTAINER, OBJECT2)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	if contents != expected {
-		t.Errorf("Contents wrong, expected %q, got: %q", expected, contents)
+	if contents != CONTENTS+CONTENTS2 {
+		t.Errorf("Bad contents %q", contents)
 	}
-	info, _, err := c.Object(ctx, CONTAINER, OBJECT)
+	copied, err := c.ObjectManifest(ctx, CONTAINER, OBJECT2)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if info.ObjectType != swift.StaticLargeObjectType {
-		t.Errorf("Wrong ObjectType, expected %d, got: %d", swift.StaticLargeObjectType, info.ObjectType)
+	if len(copied) != len(segments) {
+		t.Fatalf("Expecting %d segments got %d", len(segments), len(copied))
 	}
-	if info.Bytes != int64(len(expected)) {
-		t.Errorf("Wrong Bytes size, expected %d, got: %d", len(expected), info.Bytes)
+	for i, segment := range segments {
+		if !strings.HasPrefix(copied[i].Path, segment.container+"/") || copied[i].Path == segment.container+"/"+segment.name {
+			t.Errorf("Segment %d not copied within %q: %q", i, segment.container, copied[i].Path)
+		}
 	}
 }
 
@@ -3228,6 +7751,43 @@ func testSegmentation(t *testing.T, c *swift.Connection, createObj func() swift.
 	}
 }
 
+func TestProtectSegmentContainers(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithSegmentsContainer(t)
+	defer rollback()
+	c.ProtectSegmentContainers = true
+
+	err := c.ObjectPutString(ctx, SEGMENTS_CONTAINER, OBJECT, CONTENTS, "")
+	if err != swift.SegmentContainerForbidden {
+		t.Fatal("Expecting SegmentContainerForbidden", err)
+	}
+	_, _, err = c.Object(ctx, SEGMENTS_CONTAINER, OBJECT)
+	if err != swift.SegmentContainerForbidden {
+		t.Fatal("Expecting SegmentContainerForbidden", err)
+	}
+
+	// Large object operations must still be able to use the segments container
+	out, err := c.DynamicLargeObjectCreate(ctx, &swift.LargeObjectOpts{
+		Container:  CONTAINER,
+		ObjectName: OBJECT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fmt.Fprint(out, CONTENTS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = out.CloseWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.DynamicLargeObjectDelete(ctx, CONTAINER, OBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestContainerDelete(t *testing.T) {
 	ctx := context.Background()
 	c, rollback := makeConnectionWithContainer(t)
@@ -3263,3 +7823,49 @@ func TestUnAuthenticate(t *testing.T) {
 		t.Fatal("Not authenticated")
 	}
 }
+
+func TestTempUrlKeySelfTest(t *testing.T) {
+	ctx := context.Background()
+	c, rollback := makeConnectionWithContainer(t)
+	defer rollback()
+
+	err := c.AccountTempUrlKeySet(ctx, SECRET_KEY, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := c.TempUrlKeySelfTest(ctx, CONTAINER, "", SECRET_KEY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("Expecting current key to work")
+	}
+
+	key, err := swift.GenerateTempUrlKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err = c.TempUrlKeySelfTest(ctx, CONTAINER, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Error("Expecting new key to work")
+	}
+
+	accountKey, accountKey2, err := c.TempUrlKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountKey != SECRET_KEY || accountKey2 != "" {
+		t.Errorf("Keys not restored, got %q, %q", accountKey, accountKey2)
+	}
+	names, err := c.ObjectNamesAll(ctx, CONTAINER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("Probe object not deleted: %v", names)
+	}
+}
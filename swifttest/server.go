@@ -14,15 +14,20 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"path"
 	"reflect"
@@ -47,6 +52,8 @@ type SwiftServer struct {
 	// aligned on both ARM and x86-32.
 	// See https://golang.org/pkg/sync/atomic/#pkg-note-BUG for more details.
 	reqId int64
+	// number of successful authentications
+	authCount int64
 	sync.RWMutex
 	Listener net.Listener
 	AuthURL  string
@@ -54,6 +61,15 @@ type SwiftServer struct {
 	Accounts map[string]*account
 	Sessions map[string]*session
 	override map[string]HandlerOverrideFunc
+	failures map[string]*failure
+	// objects newer than this are left out of container listings
+	listDelay time.Duration
+}
+
+// failure describes the errors to return for a path set by SetFailure
+type failure struct {
+	times  int // number of requests left to fail
+	status int // HTTP status to fail them with
 }
 
 // The Folder type represents a container stored in an account
@@ -72,6 +88,10 @@ type Key struct {
 	// surrounded with double-quotes.
 	ETag        string `json:"hash"`
 	ContentType string `json:"content_type"`
+	// SymlinkPath is the target of a symlink
+	SymlinkPath string `json:"symlink_path,omitempty"`
+	// SLOEtag is the etag of a static large object as a HEAD returns it
+	SLOEtag string `json:"slo_etag,omitempty"`
 	// Owner        Owner
 }
 
@@ -191,10 +211,23 @@ func fatalf(code int, codeStr string, errf string, a ...interface{}) {
 }
 
 func (m metadata) setMetadata(a *action, resource string) {
+	if after := a.req.Header.Get("X-Delete-After"); after != "" && resource == "object" {
+		secs, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			fatalf(400, "BadRequest", "Non-integer X-Delete-After")
+		}
+		m.meta.Set("X-Delete-At", strconv.FormatInt(time.Now().Unix()+secs, 10))
+	}
+	//nolint:staticcheck // strings.Title is broken in a way this test code doesn't care about
+	metaPrefix := "X-" + strings.Title(resource) + "-Meta-"
 	for key, values := range a.req.Header {
 		key = http.CanonicalHeaderKey(key)
-		//nolint:staticcheck // strings.Title is broken in a way this test code doesn't care about
-		if metaHeaders[key] || strings.HasPrefix(key, "X-"+strings.Title(resource)+"-Meta-") {
+		// X-Remove-Container-Meta-Foo removes X-Container-Meta-Foo
+		if resource != "object" && strings.HasPrefix(key, "X-Remove-"+metaPrefix[2:]) {
+			m.meta.Del("X-" + key[len("X-Remove-"):])
+			continue
+		}
+		if metaHeaders[key] || strings.HasPrefix(key, metaPrefix) {
 			if values[0] != "" || resource == "object" {
 				m.meta[key] = values
 			} else {
@@ -265,6 +298,36 @@ func (c *container) list(delimiter string, marker string, prefix string, parent
 	return
 }
 
+// listingParams holds the paging parameters of a listing
+type listingParams struct {
+	marker    string
+	endMarker string
+	reverse   bool
+	limit     int
+}
+
+func parseListingParams(a *action) (p listingParams) {
+	p.marker = a.req.Form.Get("marker")
+	p.endMarker = a.req.Form.Get("end_marker")
+	p.reverse, _ = strconv.ParseBool(a.req.Form.Get("reverse"))
+	p.limit, _ = strconv.Atoi(a.req.Form.Get("limit"))
+	return p
+}
+
+// include returns whether name comes after the marker and before the
+// end_marker in the order of the listing.
+func (p listingParams) include(name string) bool {
+	if p.reverse {
+		return (p.marker == "" || name < p.marker) && (p.endMarker == "" || name > p.endMarker)
+	}
+	return name > p.marker && (p.endMarker == "" || name < p.endMarker)
+}
+
+// full returns whether a listing of n items has reached the limit
+func (p listingParams) full(n int) bool {
+	return p.limit > 0 && n >= p.limit
+}
+
 // GET on a container lists the objects in the container.
 func (r containerResource) get(a *action) interface{} {
 	if r.container == nil {
@@ -274,7 +337,7 @@ func (r containerResource) get(a *action) interface{} {
 	r.container.RLock()
 
 	delimiter := a.req.Form.Get("delimiter")
-	marker := a.req.Form.Get("marker")
+	params := parseListingParams(a)
 	prefix := a.req.Form.Get("prefix")
 	format := a.req.URL.Query().Get("format")
 	parent := a.req.Form.Get("path")
@@ -289,7 +352,40 @@ func (r containerResource) get(a *action) interface{} {
 	}
 	r.container.RUnlock()
 
-	objects := r.container.list(delimiter, marker, prefix, parent)
+	a.srv.RLock()
+	listDelay := a.srv.listDelay
+	a.srv.RUnlock()
+	visibleBefore := time.Now().Add(-listDelay)
+
+	var objects []interface{}
+	items := r.container.list(delimiter, "", prefix, parent)
+	for i := range items {
+		if params.full(len(objects)) {
+			break
+		}
+		item := items[i]
+		if params.reverse {
+			item = items[len(items)-1-i]
+		}
+		name := ""
+		switch item := item.(type) {
+		case *object:
+			if listDelay > 0 {
+				item.RLock()
+				hidden := item.mtime.After(visibleBefore)
+				item.RUnlock()
+				if hidden {
+					continue
+				}
+			}
+			name = item.name
+		case Subdir:
+			name = item.Subdir
+		}
+		if params.include(name) {
+			objects = append(objects, item)
+		}
+	}
 
 	if format == "json" {
 		a.w.Header().Set("Content-Type", "application/json")
@@ -362,6 +458,13 @@ func (r containerResource) put(a *action) interface{} {
 			},
 		}
 		r.container.setMetadata(a, "container")
+		policy := a.req.Header.Get("X-Storage-Policy")
+		if policy == "" {
+			policy = storagePolicies[0]
+		} else if !validStoragePolicy(policy) {
+			fatalf(400, "BadRequest", "Invalid X-Storage-Policy %q", policy)
+		}
+		r.container.meta.Set("X-Storage-Policy", policy)
 
 		a.user.Lock()
 		a.user.Containers[r.name] = r.container
@@ -574,19 +677,200 @@ func (obj *object) Key() Key {
 		Size:         int64(len(obj.data)),
 		ETag:         fmt.Sprintf("%x", obj.checksum),
 		ContentType:  obj.content_type,
+		SymlinkPath:  obj.meta.Get("X-Symlink-Target"),
+		SLOEtag:      obj.sloEtag(),
+	}
+}
+
+// sloEtag returns the etag of obj computed from its manifest if it is
+// a static large object like Swift puts in listings, or "" if not.
+func (obj *object) sloEtag() string {
+	if obj.meta.Get("X-Static-Large-Object") != "True" {
+		return ""
+	}
+	var segmentList []segment
+	if err := json.Unmarshal(obj.data, &segmentList); err != nil {
+		return ""
+	}
+	sum := md5.New()
+	for _, segment := range segmentList {
+		sum.Write([]byte(segment.Hash))
 	}
+	return hex.EncodeToString(sum.Sum(nil))
 }
 
 var metaHeaders = map[string]bool{
-	"Content-Type":          true,
-	"Content-Encoding":      true,
-	"Content-Disposition":   true,
-	"X-Object-Manifest":     true,
-	"X-Static-Large-Object": true,
+	"Content-Type":             true,
+	"Content-Encoding":         true,
+	"Content-Disposition":      true,
+	"Content-Language":         true,
+	"Cache-Control":            true,
+	"Expires":                  true,
+	"X-Object-Manifest":        true,
+	"X-Static-Large-Object":    true,
+	"X-Delete-At":              true,
+	"X-Symlink-Target":         true,
+	"X-Symlink-Target-Account": true,
+	"X-Symlink-Target-Etag":    true,
+	"X-Container-Read":         true,
+	"X-Container-Write":        true,
+	"X-History-Location":       true,
+	"X-Versions-Enabled":       true,
+}
+
+// postHeaders are the metaHeaders an object POST replaces.
+var postHeaders = map[string]bool{
+	"Cache-Control":       true,
+	"Content-Disposition": true,
+	"Content-Encoding":    true,
+	"Content-Language":    true,
+	"Expires":             true,
+	"X-Object-Manifest":   true,
+}
+
+// storagePolicies are the names of the storage policies the server
+// has, the first being the default.
+var storagePolicies = []string{"Policy-0", "gold"}
+
+// validStoragePolicy returns true if policy names one of the
+// storagePolicies, ignoring case as Swift does.
+func validStoragePolicy(policy string) bool {
+	for _, name := range storagePolicies {
+		if strings.EqualFold(name, policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// symloopMax is the maximum number of symlinks followed on a GET or HEAD.
+const symloopMax = 2
+
+// symlinkTarget returns the object named by an X-Symlink-Target
+// header, or nil if it doesn't exist.
+func (a *action) symlinkTarget(target string) *object {
+	target, err := url.PathUnescape(target)
+	if err != nil {
+		return nil
+	}
+	components := strings.SplitN(target, "/", 2)
+	if len(components) != 2 {
+		return nil
+	}
+	a.user.RLock()
+	c := a.user.Containers[components[0]]
+	a.user.RUnlock()
+	if c == nil {
+		return nil
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.objects[components[1]]
+}
+
+// deleteObject deletes the object at path "container/object" if it
+// exists.
+func (a *action) deleteObject(path string) {
+	components := strings.SplitN(path, "/", 2)
+	if len(components) != 2 {
+		return
+	}
+	a.user.RLock()
+	c := a.user.Containers[components[0]]
+	a.user.RUnlock()
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	obj := c.objects[components[1]]
+	if obj == nil {
+		return
+	}
+	obj.RLock()
+	size := int64(len(obj.data))
+	obj.RUnlock()
+	c.bytes -= size
+	delete(c.objects, components[1])
+	atomic.AddInt64(&a.user.BytesUsed, -size)
+	atomic.AddInt64(&a.user.Objects, -1)
+}
+
+// resolveSymlink follows obj if it is a symlink and returns the object
+// it ultimately points to.
+func (a *action) resolveSymlink(obj *object) *object {
+	for i := 0; ; i++ {
+		obj.RLock()
+		target := obj.meta.Get("X-Symlink-Target")
+		obj.RUnlock()
+		if target == "" {
+			return obj
+		}
+		if i >= symloopMax {
+			fatalf(409, "Conflict", "Too many levels of symbolic links")
+		}
+		if obj = a.symlinkTarget(target); obj == nil {
+			fatalf(404, "Not Found", "The resource could not be found.")
+		}
+	}
 }
 
 var rangeRegexp = regexp.MustCompile("(bytes=)?([0-9]*)-([0-9]*)")
 
+// parseRanges parses all the ranges in a Range header into pairs of
+// first and last byte offsets within an object of size bytes.
+func parseRanges(header string, size int) (ranges [][2]int) {
+	header = strings.TrimPrefix(header, "bytes=")
+	if header == "" {
+		return nil
+	}
+	for _, spec := range strings.Split(header, ",") {
+		m := rangeRegexp.FindStringSubmatch(strings.TrimSpace(spec))
+		if m == nil {
+			continue
+		}
+		start, end := 0, size-1
+		if m[2] != "" {
+			start, _ = strconv.Atoi(m[2])
+		}
+		if m[3] != "" {
+			end, _ = strconv.Atoi(m[3])
+		}
+		if end >= size {
+			end = size - 1
+		}
+		if start > end {
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// writeByteRanges writes a multipart/byteranges response containing
+// the ranges of obj.
+func writeByteRanges(w http.ResponseWriter, obj *object, ranges [][2]int) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusPartialContent)
+	for _, r := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {obj.content_type},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", r[0], r[1], len(obj.data))},
+		})
+		if err == nil {
+			_, err = part.Write(obj.data[r[0] : r[1]+1])
+		}
+		if err != nil {
+			log.Printf("error writing data: %v", err)
+			return
+		}
+	}
+	if err := mw.Close(); err != nil {
+		log.Printf("error writing data: %v", err)
+	}
+}
+
 // GET on an object gets the contents of the object.
 func (objr objectResource) get(a *action) interface{} {
 	var (
@@ -594,11 +878,16 @@ func (objr objectResource) get(a *action) interface{} {
 		reader io.Reader
 		start  int
 		end    int = -1
+		// Content-Range if returning part of a regular object
+		partial string
 	)
 	obj := objr.object
 	if obj == nil {
 		fatalf(404, "Not Found", "The resource could not be found.")
 	}
+	if a.req.URL.Query().Get("symlink") != "get" {
+		obj = a.resolveSymlink(obj)
+	}
 
 	obj.RLock()
 	defer obj.RUnlock()
@@ -663,17 +952,27 @@ func (objr objectResource) get(a *action) interface{} {
 		size := 0
 		sum := md5.New()
 		for _, segment := range segmentList {
+			// Like Swift the size and etag come from the manifest
+			// so a HEAD doesn't notice missing segments
+			length := int(segment.Bytes)
+			size += length
+			sum.Write([]byte(segment.Hash))
+			if start >= cursor+length {
+				continue
+			}
 			components := strings.SplitN(segment.Name[1:], "/", 2)
 			a.user.RLock()
 			segContainer := a.user.Containers[components[0]]
 			a.user.RUnlock()
-			objectName := components[1]
-			segObject := segContainer.objects[objectName]
-			length := len(segObject.data)
-			size += length
-			sum.Write([]byte(hex.EncodeToString(segObject.checksum)))
-			if start >= cursor+length {
-				continue
+			var segObject *object
+			if segContainer != nil {
+				segObject = segContainer.objects[components[1]]
+			}
+			if segObject == nil {
+				if a.req.Method == "HEAD" {
+					continue
+				}
+				fatalf(409, "Conflict", "Segment %q not found", segment.Name)
 			}
 			segments = append(segments, bytes.NewReader(segObject.data[max(0, start-cursor):]))
 			cursor += length
@@ -684,11 +983,20 @@ func (objr objectResource) get(a *action) interface{} {
 		}
 		reader = io.LimitReader(io.MultiReader(segments...), int64(end-start+1))
 	} else {
-		if end == -1 {
+		if ranges := parseRanges(a.req.Header.Get("Range"), len(obj.data)); len(ranges) > 1 && a.req.Method != "HEAD" {
+			h.Set("ETag", hex.EncodeToString(obj.checksum))
+			h.Set("Last-Modified", obj.mtime.Format(http.TimeFormat))
+			writeByteRanges(a.w, obj, ranges)
+			return nil
+		}
+		if end == -1 || end >= len(obj.data) {
 			end = len(obj.data) - 1
 		}
 		etag = obj.checksum
 		reader = bytes.NewReader(obj.data[start : end+1])
+		if a.req.Header.Get("Range") != "" {
+			partial = fmt.Sprintf("bytes %d-%d/%d", start, end, len(obj.data))
+		}
 	}
 
 	etagHex := hex.EncodeToString(etag)
@@ -706,6 +1014,11 @@ func (objr objectResource) get(a *action) interface{} {
 		return nil
 	}
 
+	if partial != "" {
+		h.Set("Content-Range", partial)
+		a.w.WriteHeader(http.StatusPartialContent)
+	}
+
 	// TODO avoid holding the lock when writing data.
 	_, err := io.Copy(a.w, reader)
 	if err != nil {
@@ -725,6 +1038,21 @@ func (objr objectResource) put(a *action) interface{} {
 			fatalf(400, "InvalidDigest", "The ETag you specified was invalid")
 		}
 	}
+	if a.req.Header.Get("If-None-Match") == "*" && objr.object != nil {
+		fatalf(412, "PreconditionFailed", "The object already exists")
+	}
+	if etag := a.req.Header.Get("X-Symlink-Target-Etag"); etag != "" {
+		target := a.symlinkTarget(a.req.Header.Get("X-Symlink-Target"))
+		if target == nil {
+			fatalf(409, "Conflict", "X-Symlink-Target does not exist")
+		}
+		target.RLock()
+		targetEtag := hex.EncodeToString(target.checksum)
+		target.RUnlock()
+		if etag != targetEtag {
+			fatalf(409, "Conflict", "Object Etag does not match X-Symlink-Target-Etag")
+		}
+	}
 	sum := md5.New()
 	// TODO avoid holding lock while reading data.
 	data, err := io.ReadAll(io.TeeReader(a.req.Body, sum))
@@ -739,6 +1067,49 @@ func (objr objectResource) put(a *action) interface{} {
 		fatalf(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
 	}
 
+	if a.req.URL.Query().Get("multipart-manifest") == "put" {
+		a.req.Header.Set("X-Static-Large-Object", "True")
+
+		var segments []segment
+		err := json.Unmarshal(data, &segments)
+		if err != nil {
+			fatalf(400, "BadParameters", "Unmarshal failed.")
+		}
+		for i := range segments {
+			// Check the segments exist and match the manifest then
+			// record them as Swift does
+			segObject := a.symlinkTarget(segments[i].Path)
+			if segObject == nil {
+				fatalf(400, "BadRequest", "Segment %q not found", segments[i].Path)
+			}
+			segObject.RLock()
+			etag := hex.EncodeToString(segObject.checksum)
+			size := int64(len(segObject.data))
+			contentType := segObject.content_type
+			mtime := segObject.mtime
+			segObject.RUnlock()
+			if segments[i].Etag != "" && segments[i].Etag != etag {
+				fatalf(400, "BadRequest", "Etag mismatch for segment %q", segments[i].Path)
+			}
+			if segments[i].Size != 0 && segments[i].Size != size {
+				fatalf(400, "BadRequest", "Size mismatch for segment %q", segments[i].Path)
+			}
+			segments[i].Name = "/" + segments[i].Path
+			segments[i].Path = ""
+			segments[i].Hash = etag
+			segments[i].Etag = ""
+			segments[i].Bytes = size
+			segments[i].Size = 0
+			segments[i].ContentType = contentType
+			segments[i].LastModified = mtime.Format("2006-01-02T15:04:05.000000")
+		}
+
+		data, _ = json.Marshal(segments)
+		sum = md5.New()
+		sum.Write(data)
+		gotHash = sum.Sum(nil)
+	}
+
 	// TODO is this correct, or should we erase all previous metadata?
 	obj := objr.object
 	if obj == nil {
@@ -762,30 +1133,6 @@ func (objr objectResource) put(a *action) interface{} {
 		}
 	}
 
-	if a.req.URL.Query().Get("multipart-manifest") == "put" {
-		// TODO: check the content of the SLO
-		a.req.Header.Set("X-Static-Large-Object", "True")
-
-		var segments []segment
-		err := json.Unmarshal(data, &segments)
-		if err != nil {
-			fatalf(400, "BadParameters", "Unmarshal failed.")
-		}
-		for i := range segments {
-			segments[i].Name = "/" + segments[i].Path
-			segments[i].Path = ""
-			segments[i].Hash = segments[i].Etag
-			segments[i].Etag = ""
-			segments[i].Bytes = segments[i].Size
-			segments[i].Size = 0
-		}
-
-		data, _ = json.Marshal(segments)
-		sum = md5.New()
-		sum.Write(data)
-		gotHash = sum.Sum(nil)
-	}
-
 	// PUT request has been successful - save data and metadata
 	obj.setMetadata(a, "object")
 	obj.content_type = content_type
@@ -801,6 +1148,7 @@ func (objr objectResource) put(a *action) interface{} {
 
 	h := a.w.Header()
 	h.Set("ETag", hex.EncodeToString(obj.checksum))
+	a.w.WriteHeader(http.StatusCreated)
 
 	return nil
 }
@@ -810,6 +1158,23 @@ func (objr objectResource) delete(a *action) interface{} {
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
 
+	// Delete the segments of an SLO too if asked
+	if a.req.URL.Query().Get("multipart-manifest") == "delete" {
+		objr.object.RLock()
+		isSLO := objr.object.meta.Get("X-Static-Large-Object") == "True"
+		manifest := objr.object.data
+		objr.object.RUnlock()
+		if isSLO {
+			var segments []segment
+			if err := json.Unmarshal(manifest, &segments); err != nil {
+				fatalf(500, "ServerError", "Bad manifest")
+			}
+			for _, segment := range segments {
+				a.deleteObject(segment.Name[1:])
+			}
+		}
+	}
+
 	objr.container.Lock()
 	defer objr.container.Unlock()
 
@@ -826,10 +1191,20 @@ func (objr objectResource) delete(a *action) interface{} {
 }
 
 func (objr objectResource) post(a *action) interface{} {
+	if objr.object == nil {
+		fatalf(404, "NoSuchKey", "The specified key does not exist.")
+	}
 	objr.object.Lock()
 	defer objr.object.Unlock()
 
 	obj := objr.object
+	// A POST replaces all the user metadata of an object along
+	// with these headers
+	for key := range obj.meta {
+		if strings.HasPrefix(key, "X-Object-Meta-") || postHeaders[key] {
+			delete(obj.meta, key)
+		}
+	}
 	obj.setMetadata(a, "object")
 	return nil
 }
@@ -853,8 +1228,17 @@ func (objr objectResource) copy(a *action) interface{} {
 		objr2 objectResource
 	)
 
-	destURL, _ := url.Parse("/v1/AUTH_" + TEST_ACCOUNT + "/" + destination)
+	destAccount := a.req.Header.Get("Destination-Account")
+	if destAccount == "" {
+		destAccount = "AUTH_" + TEST_ACCOUNT
+	}
+	destURL, _ := url.Parse("/v1/" + destAccount + "/" + destination)
 	r := a.srv.resourceForURL(destURL)
+	// resourceForURL checked the destination account exists
+	destAccountName, _, _, _ := a.srv.parseURL(destURL)
+	a.srv.RLock()
+	user := a.srv.Accounts[destAccountName]
+	a.srv.RUnlock()
 	switch t := r.(type) {
 	case objectResource:
 		objr2 = t
@@ -865,11 +1249,11 @@ func (objr objectResource) copy(a *action) interface{} {
 					meta: make(http.Header),
 				},
 			}
-			atomic.AddInt64(&a.user.Objects, 1)
+			atomic.AddInt64(&user.Objects, 1)
 		} else {
 			obj2 = objr2.object
 			atomic.AddInt64(&objr2.container.bytes, -int64(len(obj2.data)))
-			atomic.AddInt64(&a.user.BytesUsed, -int64(len(obj2.data)))
+			atomic.AddInt64(&user.BytesUsed, -int64(len(obj2.data)))
 		}
 	default:
 		fatalf(400, "Bad Request", "Destination must point to a valid object path")
@@ -885,9 +1269,16 @@ func (objr objectResource) copy(a *action) interface{} {
 	obj2.checksum = obj.checksum
 	obj2.mtime = time.Now()
 
+	// The copy replaces any existing metadata on the destination
+	meta := make(http.Header)
 	for key, values := range obj.metadata.meta {
-		obj2.metadata.meta[key] = values
+		// Swift doesn't copy the expiry time
+		if key == "X-Delete-At" {
+			continue
+		}
+		meta[key] = values
 	}
+	obj2.metadata.meta = meta
 	obj2.setMetadata(a, "object")
 
 	objr2.container.Lock()
@@ -895,7 +1286,7 @@ func (objr objectResource) copy(a *action) interface{} {
 	objr2.container.bytes += int64(len(obj.data))
 	objr2.container.Unlock()
 
-	atomic.AddInt64(&a.user.BytesUsed, int64(len(obj.data)))
+	atomic.AddInt64(&user.BytesUsed, int64(len(obj.data)))
 
 	return nil
 }
@@ -907,6 +1298,11 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 		fatalf(400, "BadParameters", "Parse form failed.")
 	}
 
+	if status := s.takeFailure(req.URL.Path); status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
 	if fn := s.override[req.URL.Path]; fn != nil {
 		originalRW := w
 		recorder := httptest.NewRecorder()
@@ -963,6 +1359,7 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 				s.Sessions[id] = &session{
 					username: username,
 				}
+				atomic.AddInt64(&s.authCount, 1)
 				return
 			}
 		}
@@ -972,16 +1369,39 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	if req.URL.String() == "/info" {
 		jsonMarshal(w, &map[string]interface{}{
 			"swift": map[string]interface{}{
-				"version": "1.2",
+				"version":                   "1.2",
+				"max_file_size":             5368709122,
+				"max_container_name_length": 256,
+				"max_object_name_length":    1024,
+				"container_listing_limit":   10000,
+				"account_listing_limit":     10000,
+				"policies": []map[string]interface{}{
+					{"name": storagePolicies[0], "aliases": storagePolicies[0], "default": true},
+					{"name": storagePolicies[1], "aliases": storagePolicies[1]},
+				},
 			},
 			"tempurl": map[string]interface{}{
-				"methods": []string{"GET", "HEAD", "PUT"},
+				"methods":         []string{"GET", "HEAD", "PUT"},
+				"allowed_digests": []string{"sha1", "sha256", "sha512"},
 			},
 			"slo": map[string]interface{}{
 				"max_manifest_segments": 1000,
 				"max_manifest_size":     2097152,
 				"min_segment_size":      1,
 			},
+			"symlink": map[string]interface{}{
+				"symloop_max":  symloopMax,
+				"static_links": true,
+			},
+			"object_versioning": map[string]interface{}{},
+			"bulk_delete": map[string]interface{}{
+				"max_deletes_per_request": 10000,
+				"max_failed_deletes":      1000,
+			},
+			"bulk_upload": map[string]interface{}{
+				"max_containers_per_extraction": 10000,
+				"max_failed_extractions":        1000,
+			},
 		})
 		return
 	}
@@ -992,37 +1412,103 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	signature := req.URL.Query().Get("temp_url_sig")
 	expires := req.URL.Query().Get("temp_url_expires")
 	if key == "" && signature != "" && expires != "" {
-		accountName, _, _, _ := s.parseURL(req.URL)
-		secretKey := ""
+		// Expired or malformed expiry times are refused
+		expiresTime, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() >= expiresTime {
+			panic(notAuthorized())
+		}
+		accountName, containerName, _, _ := s.parseURL(req.URL)
+		var secretKeys []string
+		addKeys := func(meta http.Header, prefix string) {
+			for _, name := range []string{prefix + "Temp-Url-Key", prefix + "Temp-Url-Key-2"} {
+				if secretKey := meta.Get(name); secretKey != "" {
+					secretKeys = append(secretKeys, secretKey)
+				}
+			}
+		}
 		s.RLock()
 		if account, ok := s.Accounts[accountName]; ok {
-			secretKey = account.meta.Get("X-Account-Meta-Temp-Url-Key")
+			addKeys(account.meta, "X-Account-Meta-")
+			account.ContainersLock.RLock()
+			if container, ok := account.Containers[containerName]; ok {
+				addKeys(container.meta, "X-Container-Meta-")
+			}
+			account.ContainersLock.RUnlock()
 		}
 		s.RUnlock()
 
-		get_hmac := func(method string) string {
-			mac := hmac.New(sha1.New, []byte(secretKey))
-			body := fmt.Sprintf("%s\n%s\n%s", method, expires, req.URL.Path)
+		// Signatures are either hex SHA-1 or "digest:base64"
+		newHash, encode := sha1.New, hex.EncodeToString
+		if i := strings.IndexByte(signature, ':'); i >= 0 {
+			switch signature[:i] {
+			case "sha256":
+				newHash = sha256.New
+			case "sha512":
+				newHash = sha512.New
+			default:
+				panic(notAuthorized())
+			}
+			prefix := signature[:i+1]
+			encode = func(sum []byte) string {
+				return prefix + base64.URLEncoding.EncodeToString(sum)
+			}
+		}
+
+		// A prefix signature covers every object under the prefix
+		signedPath := req.URL.Path
+		if _, ok := req.URL.Query()["temp_url_prefix"]; ok {
+			_, container, object, _ := s.parseURL(req.URL)
+			objectPrefix := req.URL.Query().Get("temp_url_prefix")
+			if !strings.HasPrefix(object, objectPrefix) {
+				panic(notAuthorized())
+			}
+			signedPath = "prefix:/v1/AUTH_" + accountName + "/" + container + "/" + objectPrefix
+		}
+
+		// An IP signature only works from the addresses given
+		ipLine := ""
+		if allowed := req.URL.Query().Get("temp_url_ip"); allowed != "" {
+			if !ipAllowed(req.RemoteAddr, allowed) {
+				panic(notAuthorized())
+			}
+			ipLine = "ip=" + allowed + "\n"
+		}
+
+		get_hmac := func(secretKey string, method string) string {
+			mac := hmac.New(newHash, []byte(secretKey))
+			body := fmt.Sprintf("%s%s\n%s\n%s", ipLine, method, expires, signedPath)
 			mac.Write([]byte(body))
-			return hex.EncodeToString(mac.Sum(nil))
+			return encode(mac.Sum(nil))
 		}
 
+		// The signature may be made with any of the keys
+		methods := []string{req.Method}
 		if req.Method == "HEAD" {
-			if signature != get_hmac("GET") && signature != get_hmac("POST") && signature != get_hmac("PUT") {
-				panic(notAuthorized())
+			methods = []string{"GET", "POST", "PUT"}
+		}
+		signed := false
+		for _, secretKey := range secretKeys {
+			for _, method := range methods {
+				if signature == get_hmac(secretKey, method) {
+					signed = true
+				}
 			}
-		} else if signature != get_hmac(req.Method) {
+		}
+		if !signed {
 			panic(notAuthorized())
 		}
 	} else {
 		s.RLock()
-		session, ok := s.Sessions[key[7:]]
+		_, ok := s.Sessions[key[7:]]
 		if !ok {
 			s.RUnlock()
 			panic(notAuthorized())
 		}
 
-		a.user = s.Accounts[session.username]
+		// Any user may use any account, which resourceForURL
+		// checked exists
+		accountName, _, _, _ := s.parseURL(req.URL)
+		a.user = s.Accounts[accountName]
 		s.RUnlock()
 	}
 
@@ -1063,6 +1549,23 @@ func (s *SwiftServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// ipAllowed returns true if the host in remoteAddr is the IP address
+// or in the CIDR range allowed
+func ipAllowed(remoteAddr string, allowed string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if _, network, err := net.ParseCIDR(allowed); err == nil {
+		return network.Contains(ip)
+	}
+	return ip.Equal(net.ParseIP(allowed))
+}
+
 func (s *SwiftServer) SetOverride(path string, fn HandlerOverrideFunc) {
 	s.override[path] = fn
 }
@@ -1071,6 +1574,80 @@ func (s *SwiftServer) UnsetOverride(path string) {
 	delete(s.override, path)
 }
 
+// SetFailure makes the next times requests to path fail with the HTTP
+// status given, eg 429, 500 or 503, before it starts working again.
+//
+// This is for testing how clients retry.  Setting times to 0 removes
+// any failures still to come.
+func (s *SwiftServer) SetFailure(path string, times int, status int) {
+	s.Lock()
+	defer s.Unlock()
+	if times <= 0 {
+		delete(s.failures, path)
+		return
+	}
+	s.failures[path] = &failure{times: times, status: status}
+}
+
+// SetListConsistencyDelay leaves objects out of container listings
+// until they are d old to simulate the eventual consistency of Swift's
+// listings.  HEAD and GET of the objects themselves are unaffected.
+//
+// Set d to 0 to turn this off.
+func (s *SwiftServer) SetListConsistencyDelay(d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.listDelay = d
+}
+
+// ExpireSessions invalidates all the auth tokens handed out so far so
+// requests using them fail with 401 until the client authenticates
+// again.
+func (s *SwiftServer) ExpireSessions() {
+	s.Lock()
+	defer s.Unlock()
+	s.Sessions = make(map[string]*session)
+}
+
+// AddAccount adds an empty account called name if it doesn't exist
+// already.  Any authenticated user can use it as "AUTH_"+name, eg as
+// the destination of a copy.
+func (s *SwiftServer) AddAccount(name string) {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.Accounts[name]; ok {
+		return
+	}
+	s.Accounts[name] = &account{
+		password: name,
+		metadata: metadata{
+			meta: make(http.Header),
+		},
+		Containers: make(map[string]*container),
+	}
+}
+
+// AuthCount returns the number of successful authentications so far.
+func (s *SwiftServer) AuthCount() int64 {
+	return atomic.LoadInt64(&s.authCount)
+}
+
+// takeFailure returns the status to fail a request to path with, or 0
+// if it shouldn't fail.
+func (s *SwiftServer) takeFailure(path string) int {
+	s.Lock()
+	defer s.Unlock()
+	f := s.failures[path]
+	if f == nil {
+		return 0
+	}
+	f.times--
+	if f.times <= 0 {
+		delete(s.failures, path)
+	}
+	return f.status
+}
+
 func jsonMarshal(w io.Writer, x interface{}) {
 	if err := json.NewEncoder(w).Encode(x); err != nil {
 		panic(fmt.Errorf("error marshalling %#v: %v", x, err))
@@ -1154,8 +1731,9 @@ type rootResource struct{}
 
 func (rootResource) put(a *action) interface{} { return notAllowed() }
 func (rootResource) get(a *action) interface{} {
-	marker := a.req.Form.Get("marker")
+	params := parseListingParams(a)
 	prefix := a.req.Form.Get("prefix")
+	delimiter := a.req.Form.Get("delimiter")
 	format := a.req.URL.Query().Get("format")
 
 	h := a.w.Header()
@@ -1182,20 +1760,43 @@ func (rootResource) get(a *action) interface{} {
 		}
 	}
 	sort.Sort(tmp)
+	if params.reverse {
+		sort.Sort(sort.Reverse(tmp))
+	}
 
-	resp := make([]Folder, 0)
+	resp := make([]interface{}, 0)
+	n := 0
+	lastSubdir := ""
 	for _, container := range tmp {
-		if container.name <= marker {
+		if params.full(n) {
+			break
+		}
+		// Containers with the delimiter after the prefix are rolled
+		// up into a single subdir
+		name, subdir := container.name, ""
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				subdir = name[:len(prefix)+i+len(delimiter)]
+				name = subdir
+			}
+		}
+		if !params.include(name) || (subdir != "" && subdir == lastSubdir) {
 			continue
 		}
+		lastSubdir = subdir
+		n++
 		if format == "json" {
+			if subdir != "" {
+				resp = append(resp, Subdir{Subdir: subdir})
+				continue
+			}
 			resp = append(resp, Folder{
 				Count: int64(len(container.objects)),
 				Bytes: container.bytes,
 				Name:  container.name,
 			})
 		} else {
-			_, err := a.w.Write([]byte(container.name + "\n"))
+			_, err := a.w.Write([]byte(name + "\n"))
 			if err != nil {
 				fatalf(500, "WriteFailed", "Write failed.")
 			}
@@ -1223,20 +1824,41 @@ func (r rootResource) delete(a *action) interface{} {
 			fatalf(400, "Bad Request", "read error")
 		}
 		var nb, notFound int
+		errors := [][]string{}
 		for _, obj := range strings.Fields(string(data)) {
-			parts := strings.SplitN(obj, "/", 3)
-			if len(parts) < 3 {
-				fatalf(403, "Operation forbidden", "Bulk delete is not supported for containers")
+			// The leading / is optional and the names are escaped
+			if unescaped, err := url.PathUnescape(obj); err == nil {
+				obj = unescaped
 			}
+			parts := strings.SplitN("/"+strings.TrimPrefix(obj, "/"), "/", 3)
+			a.user.RLock()
 			b := containerResource{
 				name:      parts[1],
 				container: a.user.Containers[parts[1]],
 			}
+			a.user.RUnlock()
 			if b.container == nil {
 				notFound++
 				continue
 			}
 
+			// A path with just a container deletes it if it is empty
+			if len(parts) < 3 || parts[2] == "" {
+				b.container.RLock()
+				empty := len(b.container.objects) == 0
+				b.container.RUnlock()
+				if !empty {
+					errors = append(errors, []string{"/" + b.name, "409 Conflict"})
+					continue
+				}
+				a.user.Lock()
+				delete(a.user.Containers, b.name)
+				a.user.swiftaccount.Containers--
+				a.user.Unlock()
+				nb++
+				continue
+			}
+
 			objr := objectResource{
 				name:      parts[2],
 				container: b.container,
@@ -1263,21 +1885,28 @@ func (r rootResource) delete(a *action) interface{} {
 			nb++
 		}
 
+		status := "200 OK"
+		if len(errors) > 0 {
+			status = "400 Bad Request"
+		}
 		accept := a.req.Header.Get("Accept")
 		if strings.HasPrefix(accept, "application/json") {
 			a.w.Header().Set("Content-Type", "application/json")
 			resp := map[string]interface{}{
 				"Number Deleted":   nb,
 				"Number Not Found": notFound,
-				"Errors":           []string{},
-				"Response Status":  "200 OK",
+				"Errors":           errors,
+				"Response Status":  status,
 				"Response Body":    "",
 			}
 			jsonMarshal(a.w, resp)
 			return nil
 		}
 
-		resp := fmt.Sprintf("Number Deleted: %d\nNumber Not Found: %d\nErrors: \nResponse Status: 200 OK\n", nb, notFound)
+		resp := fmt.Sprintf("Number Deleted: %d\nNumber Not Found: %d\nErrors: \nResponse Status: %s\n", nb, notFound, status)
+		for _, e := range errors {
+			resp += e[0] + ", " + e[1] + "\n"
+		}
 		_, err = a.w.Write([]byte(resp))
 		if err != nil {
 			fatalf(500, "WriteFailed", "Write failed.")
@@ -1306,6 +1935,7 @@ func NewSwiftServer(address string) (*SwiftServer, error) {
 		Accounts: make(map[string]*account),
 		Sessions: make(map[string]*session),
 		override: make(map[string]HandlerOverrideFunc),
+		failures: make(map[string]*failure),
 	}
 
 	server.Accounts[TEST_ACCOUNT] = &account{
@@ -1,21 +1,25 @@
 package swift
 
 import (
+	"context"
 	"io"
 	"time"
 )
 
-// An io.ReadCloser which obeys an idle timeout
+// An io.ReadCloser which obeys an idle timeout and a context
 type timeoutReader struct {
+	ctx     context.Context
 	reader  io.ReadCloser
 	timeout time.Duration
 	cancel  func()
 }
 
-// Returns a wrapper around the reader which obeys an idle
-// timeout. The cancel function is called if the timeout happens
-func newTimeoutReader(reader io.ReadCloser, timeout time.Duration, cancel func()) *timeoutReader {
+// Returns a wrapper around the reader which obeys an idle timeout
+// and stops when ctx is done. The cancel function is called if either
+// happens so the read in progress is aborted.
+func newTimeoutReader(ctx context.Context, reader io.ReadCloser, timeout time.Duration, cancel func()) *timeoutReader {
 	return &timeoutReader{
+		ctx:     ctx,
 		reader:  reader,
 		timeout: timeout,
 		cancel:  cancel,
@@ -24,8 +28,12 @@ func newTimeoutReader(reader io.ReadCloser, timeout time.Duration, cancel func()
 
 // Read reads up to len(p) bytes into p
 //
-// Waits at most for timeout for the read to complete otherwise returns a timeout
+// Waits at most for timeout for the read to complete otherwise returns
+// a timeout. If the context is done first its error is returned.
 func (t *timeoutReader) Read(p []byte) (int, error) {
+	if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
 	// FIXME limit the amount of data read in one chunk so as to not exceed the timeout?
 	// Do the read in the background
 	type result struct {
@@ -46,6 +54,9 @@ func (t *timeoutReader) Read(p []byte) (int, error) {
 	case <-timer.C:
 		t.cancel()
 		return 0, TimeoutError
+	case <-t.ctx.Done():
+		t.cancel()
+		return 0, t.ctx.Err()
 	}
 }
 
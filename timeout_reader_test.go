@@ -3,6 +3,7 @@
 package swift
 
 import (
+	"context"
 	"io"
 	"sync"
 	"testing"
@@ -52,7 +53,7 @@ func TestTimeoutReaderNoTimeout(t *testing.T) {
 	cancel := func() {
 		cancelled = true
 	}
-	tr := newTimeoutReader(test, 100*time.Millisecond, cancel)
+	tr := newTimeoutReader(context.Background(), test, 100*time.Millisecond, cancel)
 	b, err := io.ReadAll(tr)
 	if err != nil || string(b) != "AAA" {
 		t.Fatalf("Bad read %s %s", err, b)
@@ -79,7 +80,7 @@ func TestTimeoutReaderTimeout(t *testing.T) {
 	cancel := func() {
 		cancelled = true
 	}
-	tr := newTimeoutReader(test, 10*time.Millisecond, cancel)
+	tr := newTimeoutReader(context.Background(), test, 10*time.Millisecond, cancel)
 	_, err := io.ReadAll(tr)
 	if err != TimeoutError {
 		t.Fatal("Expecting TimeoutError, got", err)
@@ -104,3 +105,36 @@ func TestTimeoutReaderTimeout(t *testing.T) {
 		t.Fatal("Should be closed")
 	}
 }
+
+func TestTimeoutReaderContextCancel(t *testing.T) {
+	// Return those bytes slowly so the context is cancelled mid read
+	test := newTestReader(3, 100*time.Millisecond)
+	cancelled := false
+	cancel := func() {
+		cancelled = true
+	}
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	tr := newTimeoutReader(ctx, test, time.Second, cancel)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ctxCancel()
+	}()
+	start := time.Now()
+	_, err := io.ReadAll(tr)
+	if err != context.Canceled {
+		t.Fatal("Expecting context.Canceled, got", err)
+	}
+	if time.Since(start) >= 100*time.Millisecond {
+		t.Fatal("Read didn't return promptly on cancel")
+	}
+	if !cancelled {
+		t.Fatal("Not cancelled when should have been")
+	}
+
+	// Reads after the context is done fail straight away
+	_, err = tr.Read(make([]byte, 1))
+	if err != context.Canceled {
+		t.Fatal("Expecting context.Canceled, got", err)
+	}
+	_ = tr.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 )
 
+// watchdogChunkSize is the default Connection.WatchdogChunkSize
 var watchdogChunkSize = 1 << 20 // 1 MiB
 
 // An io.Reader which resets a watchdog timer whenever data is read
@@ -16,12 +17,14 @@ type watchdogReader struct {
 }
 
 // Returns a new reader which will kick the watchdog timer whenever data is read
-func newWatchdogReader(reader io.Reader, timeout time.Duration, timer *time.Timer) *watchdogReader {
+//
+// Reads are split into chunks of at most chunkSize bytes
+func newWatchdogReader(reader io.Reader, timeout time.Duration, timer *time.Timer, chunkSize int) *watchdogReader {
 	return &watchdogReader{
 		timeout:   timeout,
 		reader:    reader,
 		timer:     timer,
-		chunkSize: watchdogChunkSize,
+		chunkSize: chunkSize,
 	}
 }
 
@@ -14,7 +14,7 @@ import (
 func testWatchdogReaderTimeout(t *testing.T, initialTimeout, watchdogTimeout time.Duration, expectedTimeout bool) {
 	test := newTestReader(3, 10*time.Millisecond)
 	timer, firedChan := setupTimer(initialTimeout)
-	wr := newWatchdogReader(test, watchdogTimeout, timer)
+	wr := newWatchdogReader(test, watchdogTimeout, timer, watchdogChunkSize)
 	b, err := io.ReadAll(wr)
 	if err != nil || string(b) != "AAA" {
 		t.Fatalf("Bad read %s %s", err, b)
@@ -96,7 +96,7 @@ func TestWatchdogReaderOnSlowNetwork(t *testing.T) {
 	}
 
 	timer, firedChan := setupTimer(100 * time.Millisecond)
-	wr := newWatchdogReader(reader, 190*time.Millisecond, timer)
+	wr := newWatchdogReader(reader, 190*time.Millisecond, timer, watchdogChunkSize)
 
 	//use io.ReadFull instead of io.ReadAll here because ReadAll already does
 	//some chunking that would keep this testcase from failing
@@ -132,3 +132,31 @@ func TestWatchdogReaderValidity(t *testing.T) {
 		t.Fatalf("Bad read: %#v != %#v", string(b), string(byteString))
 	}
 }
+
+// maxReader records the largest Read it was asked for
+type maxReader struct {
+	reader io.Reader
+	max    int
+}
+
+func (r *maxReader) Read(p []byte) (n int, err error) {
+	if len(p) > r.max {
+		r.max = len(p)
+	}
+	return r.reader.Read(p)
+}
+
+// This test verifies that the chunk size passed to newWatchdogReader
+// limits the size of each read.
+func TestWatchdogReaderChunkSize(t *testing.T) {
+	reader := &maxReader{reader: bytes.NewReader(make([]byte, 100))}
+	wr := newWatchdogReader(reader, 5*time.Minute, time.NewTimer(5*time.Minute), 7)
+	b := make([]byte, 100)
+	n, err := io.ReadFull(wr, b)
+	if err != nil || n != len(b) {
+		t.Fatalf("Read error: %s", err)
+	}
+	if reader.max != 7 {
+		t.Fatalf("Expecting max read of 7 but got %d", reader.max)
+	}
+}